@@ -22,117 +22,74 @@ func Tune(fpath, sys, pol string, freqHz int64, bwHz int, srBd uint) (fe fronten
 	if err != nil {
 		return
 	}
+	var p frontend.Params
 	switch sys {
-	case "t", "t2":
-		ds := dvb.SysDVBT
-		if sys == "t2" {
-			ds = dvb.SysDVBT2
-		}
-		if err = fe.SetDeliverySystem(ds); err != nil {
-			return
-		}
-		if err = fe.SetModulation(dvb.QAMAuto); err != nil {
-			return
-		}
-		if err = fe.SetFrequency(uint32(freqHz)); err != nil {
-			return
-		}
-		if err = fe.SetInversion(dvb.InversionAuto); err != nil {
-			return
-		}
-		if bwHz != 0 {
-			if err = fe.SetBandwidth(uint32(bwHz)); err != nil {
-				return
-			}
-		}
-		if err = fe.SetCodeRateHP(dvb.FECAuto); err != nil {
-			return
-		}
-		if err = fe.SetCodeRateLP(dvb.FECAuto); err != nil {
-			return
-		}
-		if err = fe.SetTxMode(dvb.TxModeAuto); err != nil {
-			return
-		}
-		if err = fe.SetGuard(dvb.GuardAuto); err != nil {
-			return
-		}
-		if err = fe.SetHierarchy(dvb.HierarchyNone); err != nil {
-			return
-		}
-	case "s", "s2":
-		if sys == "s" {
-			if err = fe.SetDeliverySystem(dvb.SysDVBS); err != nil {
-				return
-			}
-			if err = fe.SetModulation(dvb.QPSK); err != nil {
-				return
-			}
-		} else {
-			if err = fe.SetDeliverySystem(dvb.SysDVBS2); err != nil {
-				return
-			}
-			if err = fe.SetModulation(dvb.PSK8); err != nil {
-				return
-			}
-			if err = fe.SetRolloff(dvb.RolloffAuto); err != nil {
-				return
-			}
-			if err = fe.SetPilot(dvb.PilotAuto); err != nil {
-				return
-			}
-		}
-		if err = fe.SetSymbolRate(uint32(srBd)); err != nil {
-			return
-		}
-		if err = fe.SetInnerFEC(dvb.FECAuto); err != nil {
-			return
-		}
-		if err = fe.SetInversion(dvb.InversionAuto); err != nil {
-			return
-		}
+	case "t":
+		p = &frontend.DVBTParams{
+			Freq:       uint32(freqHz),
+			Bandwidth:  uint32(bwHz),
+			Inversion:  dvb.InversionAuto,
+			Modulation: dvb.QAMAuto,
+			CodeRateHP: dvb.FECAuto,
+			CodeRateLP: dvb.FECAuto,
+			TxMode:     dvb.TxModeAuto,
+			Guard:      dvb.GuardAuto,
+			Hierarchy:  dvb.HierarchyNone,
+		}
+	case "t2":
+		p = &frontend.DVBT2Params{
+			Freq:       uint32(freqHz),
+			Bandwidth:  uint32(bwHz),
+			Inversion:  dvb.InversionAuto,
+			Modulation: dvb.QAMAuto,
+			CodeRate:   dvb.FECAuto,
+			TxMode:     dvb.TxModeAuto,
+			Guard:      dvb.GuardAuto,
+			StreamId:   frontend.NoStreamId,
+		}
+	case "s":
 		ifreq, tone, volt := frontend.SecParam(freqHz, polar)
-		if err = fe.SetFrequency(ifreq); err != nil {
-			return
-		}
-		if err = fe.SetTone(tone); err != nil {
-			return
-		}
-		if err = fe.SetVoltage(volt); err != nil {
-			return
+		p = &frontend.DVBSParams{
+			Freq:       ifreq,
+			SymbolRate: uint32(srBd),
+			Inversion:  dvb.InversionAuto,
+			InnerFEC:   dvb.FECAuto,
+			Tone:       tone,
+			Voltage:    volt,
+		}
+	case "s2":
+		ifreq, tone, volt := frontend.SecParam(freqHz, polar)
+		p = &frontend.DVBS2Params{
+			Freq:       ifreq,
+			SymbolRate: uint32(srBd),
+			Inversion:  dvb.InversionAuto,
+			Modulation: dvb.PSK8,
+			InnerFEC:   dvb.FECAuto,
+			Pilot:      dvb.PilotAuto,
+			Rolloff:    dvb.RolloffAuto,
+			Tone:       tone,
+			Voltage:    volt,
 		}
 	case "ca", "cb", "cc":
+		annex := dvb.SysDVBCAnnexA
 		switch sys {
-		case "ca":
-			err = fe.SetDeliverySystem(dvb.SysDVBCAnnexA)
 		case "cb":
-			err = fe.SetDeliverySystem(dvb.SysDVBCAnnexB)
+			annex = dvb.SysDVBCAnnexB
 		case "cc":
-			err = fe.SetDeliverySystem(dvb.SysDVBCAnnexC)
-		}
-		if err != nil {
-			return
-		}
-		if err = fe.SetModulation(dvb.QAMAuto); err != nil {
-			return
-		}
-		if err = fe.SetFrequency(uint32(freqHz)); err != nil {
-			return
-		}
-		if err = fe.SetInversion(dvb.InversionAuto); err != nil {
-			return
-		}
-		if err = fe.SetSymbolRate(uint32(srBd)); err != nil {
-			return
+			annex = dvb.SysDVBCAnnexC
 		}
-		if err = fe.SetInnerFEC(dvb.FECAuto); err != nil {
-			return
+		p = &frontend.DVBCParams{
+			Annex:      annex,
+			Freq:       uint32(freqHz),
+			SymbolRate: uint32(srBd),
+			Inversion:  dvb.InversionAuto,
+			Modulation: dvb.QAMAuto,
 		}
 	default:
 		err = errors.New("unknown delivery system: " + sys)
 		return
 	}
-	err = fe.Tune()
+	err = fe.Apply(p)
 	return
 }
 
@@ -0,0 +1,219 @@
+// Package adapter enumerates Linux DVB adapters and their devices.
+package adapter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ziutek/dvb"
+	"github.com/ziutek/dvb/linuxdvb/frontend"
+)
+
+// DefaultRoot is a directory that contains adapterN subdirectories.
+const DefaultRoot = "/dev/dvb"
+
+// DevType is a type of DVB device.
+type DevType int
+
+const (
+	Frontend DevType = iota
+	Demux
+	DVR
+	CA
+	Net
+)
+
+var devNames = [...]string{"frontend", "demux", "dvr", "ca", "net"}
+
+func (t DevType) String() string {
+	if uint(t) < uint(len(devNames)) {
+		return devNames[t]
+	}
+	return "unknown"
+}
+
+// FrontendInfo describes frontend device.
+type FrontendInfo struct {
+	Num             int
+	Name            string
+	DeliverySystems []dvb.DeliverySystem
+
+	// Err is an error returned by Prober. Name and DeliverySystems are
+	// invalid if Err != nil (eg. frontend is used by other process).
+	Err error
+}
+
+// Supports returns true if frontend supports delivery system ds.
+func (fi *FrontendInfo) Supports(ds dvb.DeliverySystem) bool {
+	for _, s := range fi.DeliverySystems {
+		if s == ds {
+			return true
+		}
+	}
+	return false
+}
+
+// Adapter describes DVB adapter.
+type Adapter struct {
+	Num  int
+	Path string // path to adapterN directory
+
+	// Numbers of devices of each type, sorted in increasing order.
+	Devices [len(devNames)][]int
+
+	Frontends []FrontendInfo
+}
+
+// DevPath returns path to n-th device of type t.
+func (a *Adapter) DevPath(t DevType, n int) string {
+	return filepath.Join(a.Path, t.String()+strconv.Itoa(n))
+}
+
+// Has returns true if adapter has n-th device of type t.
+func (a *Adapter) Has(t DevType, n int) bool {
+	for _, k := range a.Devices[t] {
+		if k == n {
+			return true
+		}
+	}
+	return false
+}
+
+// DemuxFor returns number of demux device that receives TS from frontend fe.
+// It is demux with the same number as fe or, if there is no such demux,
+// demux0 shared by all frontends of multi-frontend adapter.
+func (a *Adapter) DemuxFor(fe int) (n int, ok bool) {
+	if a.Has(Demux, fe) {
+		return fe, true
+	}
+	return 0, a.Has(Demux, 0)
+}
+
+// Prober obtains information about frontend device.
+type Prober func(path string) (name string, dss []dvb.DeliverySystem, err error)
+
+// ProbeFrontend opens frontend read-only and reads its name and supported
+// delivery systems. It is default Prober used by Scan.
+func ProbeFrontend(path string) (name string, dss []dvb.DeliverySystem, err error) {
+	d, err := frontend.OpenRO(path)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	info, err := frontend.API3{Device: d}.Info()
+	if err != nil {
+		return
+	}
+	n := info.Name[:]
+	if k := bytes.IndexByte(n, 0); k != -1 {
+		n = n[:k]
+	}
+	name = string(n)
+	dss, err = d.EnumDeliverySystems()
+	return
+}
+
+// Scanner scans directory tree for DVB adapters.
+type Scanner struct {
+	Root  string // DefaultRoot if empty
+	Probe Prober // ProbeFrontend if nil
+}
+
+// Scan returns adapters found in s.Root, sorted by number. It returns empty
+// list (without error) if s.Root doesn't exist.
+func (s *Scanner) Scan() ([]*Adapter, error) {
+	root := s.Root
+	if root == "" {
+		root = DefaultRoot
+	}
+	probe := s.Probe
+	if probe == nil {
+		probe = ProbeFrontend
+	}
+	fis, err := ioutil.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, err
+	}
+	var as []*Adapter
+	for _, fi := range fis {
+		num, ok := parseName(fi.Name(), "adapter")
+		if !ok || !fi.IsDir() {
+			continue
+		}
+		a, err := scanAdapter(filepath.Join(root, fi.Name()), num, probe)
+		if err != nil {
+			return nil, err
+		}
+		as = append(as, a)
+	}
+	sort.Slice(as, func(i, j int) bool { return as[i].Num < as[j].Num })
+	return as, nil
+}
+
+// Scan scans DefaultRoot using ProbeFrontend.
+func Scan() ([]*Adapter, error) {
+	return new(Scanner).Scan()
+}
+
+func scanAdapter(path string, num int, probe Prober) (*Adapter, error) {
+	fis, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	a := &Adapter{Num: num, Path: path}
+	for _, fi := range fis {
+		for t, prefix := range devNames {
+			if n, ok := parseName(fi.Name(), prefix); ok {
+				a.Devices[t] = append(a.Devices[t], n)
+				break
+			}
+		}
+	}
+	for _, nums := range a.Devices {
+		sort.Ints(nums)
+	}
+	for _, n := range a.Devices[Frontend] {
+		fi := FrontendInfo{Num: n}
+		fi.Name, fi.DeliverySystems, fi.Err = probe(a.DevPath(Frontend, n))
+		a.Frontends = append(a.Frontends, fi)
+	}
+	return a, nil
+}
+
+// parseName parses device name in form prefixN.
+func parseName(name, prefix string) (int, bool) {
+	if !strings.HasPrefix(name, prefix) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(name[len(prefix):], 10, 16)
+	if err != nil {
+		return 0, false
+	}
+	return int(n), true
+}
+
+// Find returns first adapter and frontend from as that supports delivery
+// system ds and has demux and DVR devices (see DemuxFor). It returns nil if
+// there is no such frontend.
+func Find(as []*Adapter, ds dvb.DeliverySystem) (*Adapter, *FrontendInfo) {
+	for _, a := range as {
+		for i := range a.Frontends {
+			fi := &a.Frontends[i]
+			if fi.Err != nil || !fi.Supports(ds) {
+				continue
+			}
+			if n, ok := a.DemuxFor(fi.Num); ok && a.Has(DVR, n) {
+				return a, fi
+			}
+		}
+	}
+	return nil, nil
+}
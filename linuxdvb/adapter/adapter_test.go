@@ -0,0 +1,83 @@
+package adapter
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ziutek/dvb"
+)
+
+func TestScan(t *testing.T) {
+	root, err := ioutil.TempDir("", "dvb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	tree := map[string][]string{
+		"adapter1":  {"frontend0", "demux0", "dvr0", "ca0", "net0"},
+		"adapter0":  {"frontend1", "frontend0", "demux0", "dvr0", "net0"},
+		"adapter2":  {"frontend0", "frontend1", "demux1", "dvr1"},
+		"adapterX":  {"frontend0"},
+		"something": nil,
+	}
+	for dir, devs := range tree {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, dev := range devs {
+			err := ioutil.WriteFile(filepath.Join(root, dir, dev), nil, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	probe := func(path string) (string, []dvb.DeliverySystem, error) {
+		switch path {
+		case filepath.Join(root, "adapter0", "frontend0"):
+			return "busy", nil, errors.New("device busy")
+		case filepath.Join(root, "adapter0", "frontend1"):
+			return "DVB-S tuner", []dvb.DeliverySystem{dvb.SysDVBS, dvb.SysDVBS2}, nil
+		}
+		return "DVB-T tuner", []dvb.DeliverySystem{dvb.SysDVBT, dvb.SysDVBT2}, nil
+	}
+	as, err := (&Scanner{Root: root, Probe: probe}).Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(as) != 3 || as[0].Num != 0 || as[1].Num != 1 {
+		t.Fatalf("bad adapters: %+v", as)
+	}
+	a := as[0]
+	if len(a.Frontends) != 2 || a.Frontends[0].Num != 0 ||
+		a.Frontends[0].Err == nil || a.Frontends[1].Name != "DVB-S tuner" {
+		t.Fatalf("bad frontends: %+v", a.Frontends)
+	}
+	if len(a.Devices[CA]) != 0 || !a.Has(Net, 0) || len(as[1].Devices[CA]) != 1 {
+		t.Fatalf("bad devices: %+v", a.Devices)
+	}
+	if a.DevPath(DVR, 0) != filepath.Join(root, "adapter0", "dvr0") {
+		t.Fatal("bad path:", a.DevPath(DVR, 0))
+	}
+	// frontend1 of adapter0 has no demux1/dvr1 so it shares demux0/dvr0.
+	if a, fi := Find(as, dvb.SysDVBS2); a != as[0] || fi.Num != 1 {
+		t.Fatal("can't find DVB-S2 frontend that shares demux0")
+	}
+	if n, ok := as[0].DemuxFor(1); !ok || n != 0 {
+		t.Fatal("bad demux for frontend1:", n, ok)
+	}
+	if a, fi := Find(as, dvb.SysDVBT2); a != as[1] || fi.Num != 0 {
+		t.Fatal("can't find DVB-T2 frontend")
+	}
+	if n, ok := as[2].DemuxFor(0); ok {
+		t.Fatal("frontend0 of adapter2 has no demux:", n)
+	}
+	if n, ok := as[2].DemuxFor(1); !ok || n != 1 {
+		t.Fatal("bad demux for frontend1 of adapter2:", n, ok)
+	}
+	if as, err := (&Scanner{Root: filepath.Join(root, "none")}).Scan(); err != nil || len(as) != 0 {
+		t.Fatal("nonexistent root:", as, err)
+	}
+}
@@ -0,0 +1,147 @@
+// Package ca provides interface to Linux DVB CA device (Common Interface) and
+// host side implementation of EN 50221 transport, session and application
+// layers.
+package ca
+
+import (
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+type SlotType uint32
+
+const (
+	SlotCI     SlotType = 1   // CI high level interface
+	SlotCILink SlotType = 2   // CI link layer level interface
+	SlotCIPhys SlotType = 4   // CI physical layer level interface
+	SlotDescr  SlotType = 8   // built-in descrambler
+	SlotSC     SlotType = 128 // simple smart card interface
+)
+
+type SlotFlags uint32
+
+const (
+	ModulePresent SlotFlags = 1
+	ModuleReady   SlotFlags = 2
+)
+
+type DescrType uint32
+
+const (
+	DescrECD DescrType = 1
+	DescrNDS DescrType = 2
+	DescrDSS DescrType = 4
+)
+
+// Caps describes capabilities of CA device.
+type Caps struct {
+	SlotNum   uint32    // total number of CA card and module slots
+	SlotType  SlotType  // OR of all supported types
+	DescrNum  uint32    // total number of descrambler slots (keys)
+	DescrType DescrType // OR of all supported types
+}
+
+// SlotInfo describes state of CA slot.
+type SlotInfo struct {
+	Num   int32
+	Type  SlotType
+	Flags SlotFlags
+}
+
+// Device represents Linux DVB CA device.
+type Device struct {
+	file *os.File
+}
+
+// Open opens CA device in non-blocking mode, so it is handled by Go runtime
+// poller and ReadTPDU can be interrupted by Close or read deadline.
+func Open(path string) (d Device, err error) {
+	for {
+		var fd int
+		fd, err = syscall.Open(
+			path, syscall.O_RDWR|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0,
+		)
+		if err == nil {
+			d.file = os.NewFile(uintptr(fd), path)
+			return
+		}
+		if err != syscall.EINTR {
+			err = &os.PathError{Op: "open", Path: path, Err: err}
+			return
+		}
+	}
+}
+
+func (d Device) Close() error {
+	return d.file.Close()
+}
+
+func (d Device) ioctl(req uintptr, p unsafe.Pointer) error {
+	rc, err := d.file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var e syscall.Errno
+	err = rc.Control(func(fd uintptr) {
+		_, _, e = syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(p))
+	})
+	if err != nil {
+		return err
+	}
+	if e != 0 {
+		return e
+	}
+	return nil
+}
+
+// Reset resets all CA slots.
+func (d Device) Reset() error {
+	return d.ioctl(_CA_RESET, nil)
+}
+
+// Caps returns capabilities of CA device.
+func (d Device) Caps() (Caps, error) {
+	var c Caps
+	err := d.ioctl(_CA_GET_CAP, unsafe.Pointer(&c))
+	return c, err
+}
+
+// SlotInfo returns information about slot n.
+func (d Device) SlotInfo(n int) (SlotInfo, error) {
+	si := SlotInfo{Num: int32(n)}
+	err := d.ioctl(_CA_GET_SLOT_INFO, unsafe.Pointer(&si))
+	return si, err
+}
+
+// WriteTPDU writes TPDU to module in slot using link connection lcid.
+// Device implements Link interface.
+func (d Device) WriteTPDU(slot, lcid byte, tpdu []byte) error {
+	buf := make([]byte, 2+len(tpdu))
+	buf[0] = slot
+	buf[1] = lcid
+	copy(buf[2:], tpdu)
+	_, err := d.file.Write(buf)
+	return err
+}
+
+// ReadTPDU reads TPDU received from any slot into buf.
+func (d Device) ReadTPDU(buf []byte) (n int, slot, lcid byte, err error) {
+	n, err = d.file.Read(buf)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if n < 2 {
+		return 0, 0, 0, ErrTPDU
+	}
+	slot, lcid = buf[0], buf[1]
+	n = copy(buf, buf[2:n])
+	return
+}
+
+// SetReadDeadline sets the deadline for future ReadTPDU calls and any
+// currently-blocked ReadTPDU call.
+func (d Device) SetReadDeadline(t time.Time) error {
+	return d.file.SetReadDeadline(t)
+}
@@ -0,0 +1,84 @@
+package ca
+
+import (
+	"github.com/ziutek/dvb"
+	"github.com/ziutek/dvb/ts/psi"
+)
+
+var ErrPMT = dvb.TemporaryError("ca: damaged PMT")
+
+// ListManagement is a value of ca_pmt_list_management field.
+type ListManagement byte
+
+const (
+	ListMore ListManagement = iota
+	ListFirst
+	ListLast
+	ListOnly
+	ListAdd
+	ListUpdate
+)
+
+// CmdId is a value of ca_pmt_cmd_id field.
+type CmdId byte
+
+const (
+	CmdOKDescrambling CmdId = 1
+	CmdOKMMI          CmdId = 2
+	CmdQuery          CmdId = 3
+	CmdNotSelected    CmdId = 4
+)
+
+// appendCADescriptors appends CA_PMT info loop (with ca_pmt_cmd_id if there
+// is at least one CA descriptor) created from CA descriptors in dl.
+func appendCADescriptors(b []byte, dl psi.DescriptorList, cmd CmdId) ([]byte, error) {
+	n := len(b)
+	b = append(b, 0xf0, 0x00, byte(cmd))
+	for len(dl) != 0 {
+		var d psi.Descriptor
+		d, dl = dl.Pop()
+		if d == nil {
+			return nil, ErrPMT
+		}
+		if d.Tag() == psi.CATag {
+			b = append(b, d...)
+		}
+	}
+	l := len(b) - n - 2
+	if l == 1 {
+		// No CA descriptors, so no ca_pmt_cmd_id.
+		b, l = b[:n+2], 0
+	}
+	b[n] |= byte(l>>8) & 0x0f
+	b[n+1] = byte(l)
+	return b, nil
+}
+
+// MakeCAPMT returns body of ca_pmt APDU created from pmt. Only CA descriptors
+// are copied from pmt.
+func MakeCAPMT(pmt psi.PMT, lm ListManagement, cmd CmdId) ([]byte, error) {
+	prog := pmt.ProgId()
+	vc := byte(0xc0) | byte(pmt.Version()<<1)
+	if pmt.Current() {
+		vc |= 1
+	}
+	b := []byte{byte(lm), byte(prog >> 8), byte(prog), vc}
+	b, err := appendCADescriptors(b, pmt.ProgramDescriptors(), cmd)
+	if err != nil {
+		return nil, err
+	}
+	il := pmt.ESInfo()
+	for len(il) != 0 {
+		var i psi.ESInfo
+		i, il = il.Pop()
+		if i == nil {
+			return nil, ErrPMT
+		}
+		pid := i.Pid()
+		b = append(b, byte(i.Type()), 0xe0|byte(pid>>8), byte(pid))
+		if b, err = appendCADescriptors(b, i.Descriptors(), cmd); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
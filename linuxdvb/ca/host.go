@@ -0,0 +1,365 @@
+package ca
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ziutek/dvb"
+	"github.com/ziutek/dvb/ts/psi"
+)
+
+var ErrNoSession = errors.New("ca: no session to required resource")
+
+// ResourceId identifies EN 50221 resource.
+type ResourceId uint32
+
+const (
+	ResourceManager ResourceId = 0x00010041
+	ApplicationInfo ResourceId = 0x00020041
+	CASupport       ResourceId = 0x00030041
+	DateTime        ResourceId = 0x00240041
+)
+
+// class returns resource class and type without version.
+func (r ResourceId) class() ResourceId {
+	return r &^ 0x3f
+}
+
+// Session layer tags (EN 50221 7.2.7)
+const (
+	sSessionNumber         = 0x90
+	sOpenSessionRequest    = 0x91
+	sOpenSessionResponse   = 0x92
+	sCloseSessionRequest   = 0x95
+	sCloseSessionResponse  = 0x96
+	sessionOK              = 0x00
+	sessionNoResource      = 0xf0
+	sessionResourceVersion = 0xf2
+)
+
+// APDU tags (EN 50221 8.8.1)
+const (
+	aProfileEnq    = 0x9f8010
+	aProfile       = 0x9f8011
+	aProfileChange = 0x9f8012
+	aAppInfoEnq    = 0x9f8020
+	aAppInfo       = 0x9f8021
+	aCAInfoEnq     = 0x9f8030
+	aCAInfo        = 0x9f8031
+	aCAPMT         = 0x9f8032
+	aCAPMTReply    = 0x9f8033
+	aDateTimeEnq   = 0x9f8440
+	aDateTime      = 0x9f8441
+)
+
+var hostResources = []ResourceId{
+	ResourceManager, ApplicationInfo, CASupport, DateTime,
+}
+
+// AppInfo contains data received from Application Information resource.
+type AppInfo struct {
+	Type         byte // 1: conditional access, 2: electronic programme guide
+	Manufacturer uint16
+	Code         uint16
+	Menu         string
+}
+
+// Host implements host side of EN 50221 session layer and Resource Manager,
+// Application Information, Conditional Access Support and Date-Time resources
+// for one module. Host methods can be called concurrently.
+type Host struct {
+	mu       sync.Mutex
+	t        *Transport
+	sessions map[uint16]ResourceId
+	next     uint16
+
+	appInfo *AppInfo
+	caIds   []psi.CAS
+	caSnb   uint16
+
+	dtSnb      uint16
+	dtInterval time.Duration
+	dtNext     time.Time
+}
+
+// NewHost returns Host that communicates with module in slot over l.
+func NewHost(l Link, slot byte) *Host {
+	return &Host{
+		t:        NewTransport(l, slot, slot+1),
+		sessions: make(map[uint16]ResourceId),
+		next:     1,
+	}
+}
+
+// Init creates transport connection to module.
+func (h *Host) Init() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.t.Create()
+}
+
+func (h *Host) sendSPDU(tag byte, body, apdu []byte) error {
+	b := make([]byte, 0, 2+len(body)+len(apdu))
+	b = append(b, tag, byte(len(body)))
+	b = append(b, body...)
+	b = append(b, apdu...)
+	return h.t.Send(b)
+}
+
+func (h *Host) sendAPDU(snb uint16, tag uint32, data []byte) error {
+	apdu := make([]byte, 0, 7+len(data))
+	apdu = append(apdu, byte(tag>>16), byte(tag>>8), byte(tag))
+	apdu = appendLen(apdu, len(data))
+	apdu = append(apdu, data...)
+	return h.sendSPDU(sSessionNumber, []byte{byte(snb >> 8), byte(snb)}, apdu)
+}
+
+// Poll receives data from module and handles it. It also sends periodic
+// date_time objects if module requested them. Poll should be called
+// periodically (eg. every 100 ms). Use Run to do it in loop.
+func (h *Host) Poll() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for {
+		spdu, err := h.t.Receive()
+		if err != nil {
+			return err
+		}
+		if spdu == nil {
+			break
+		}
+		if err := h.handleSPDU(spdu); err != nil {
+			return err
+		}
+	}
+	if h.dtSnb != 0 && h.dtInterval > 0 && !time.Now().Before(h.dtNext) {
+		return h.sendDateTime()
+	}
+	return nil
+}
+
+// Run calls Poll every interval until ctx is done or Poll returns error that
+// isn't of dvb.TemporaryError type.
+func (h *Host) Run(ctx context.Context, interval time.Duration) error {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		if err := h.Poll(); err != nil {
+			if _, ok := err.(dvb.TemporaryError); !ok {
+				return err
+			}
+		}
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (h *Host) handleSPDU(b []byte) error {
+	if len(b) < 2 || len(b) < 2+int(b[1]) {
+		return ErrSPDU
+	}
+	l := 2 + int(b[1])
+	tag, body, apdu := b[0], b[2:l], b[l:]
+	switch tag {
+	case sOpenSessionRequest:
+		if len(body) != 4 {
+			return ErrSPDU
+		}
+		return h.openSession(ResourceId(decodeU32(body)))
+	case sCloseSessionRequest:
+		if len(body) != 2 {
+			return ErrSPDU
+		}
+		snb := decodeU16(body)
+		status := byte(sessionOK)
+		if !h.closeSession(snb) {
+			status = sessionNoResource
+		}
+		return h.sendSPDU(
+			sCloseSessionResponse, []byte{status, body[0], body[1]}, nil,
+		)
+	case sSessionNumber:
+		if len(body) != 2 {
+			return ErrSPDU
+		}
+		snb := decodeU16(body)
+		if _, ok := h.sessions[snb]; !ok {
+			return ErrSPDU
+		}
+		return h.handleAPDU(snb, apdu)
+	}
+	return nil // Ignore not supported SPDUs.
+}
+
+func (h *Host) openSession(rid ResourceId) error {
+	status := byte(sessionNoResource)
+	var snb uint16
+	for _, r := range hostResources {
+		if r.class() != rid.class() {
+			continue
+		}
+		if rid > r {
+			status = sessionResourceVersion
+			break
+		}
+		status = sessionOK
+		snb = h.next
+		h.next++
+		if h.next == 0 {
+			h.next = 1
+		}
+		h.sessions[snb] = r
+		break
+	}
+	err := h.sendSPDU(sOpenSessionResponse, []byte{
+		status,
+		byte(rid >> 24), byte(rid >> 16), byte(rid >> 8), byte(rid),
+		byte(snb >> 8), byte(snb),
+	}, nil)
+	if err != nil || status != sessionOK {
+		return err
+	}
+	switch h.sessions[snb] {
+	case ResourceManager:
+		return h.sendAPDU(snb, aProfileEnq, nil)
+	case ApplicationInfo:
+		return h.sendAPDU(snb, aAppInfoEnq, nil)
+	case CASupport:
+		h.caSnb = snb
+		return h.sendAPDU(snb, aCAInfoEnq, nil)
+	case DateTime:
+		h.dtSnb = snb
+	}
+	return nil
+}
+
+func (h *Host) closeSession(snb uint16) bool {
+	if _, ok := h.sessions[snb]; !ok {
+		return false
+	}
+	delete(h.sessions, snb)
+	switch snb {
+	case h.caSnb:
+		h.caSnb = 0
+		h.caIds = nil
+	case h.dtSnb:
+		h.dtSnb = 0
+		h.dtInterval = 0
+	}
+	return true
+}
+
+func (h *Host) handleAPDU(snb uint16, b []byte) error {
+	if len(b) < 4 {
+		return ErrAPDU
+	}
+	tag := uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+	l, m := decodeLen(b[3:])
+	if m == 0 || len(b) < 3+m+l {
+		return ErrAPDU
+	}
+	data := b[3+m : 3+m+l]
+	switch tag {
+	case aProfileEnq:
+		p := make([]byte, 0, 4*len(hostResources))
+		for _, r := range hostResources {
+			p = append(p, byte(r>>24), byte(r>>16), byte(r>>8), byte(r))
+		}
+		return h.sendAPDU(snb, aProfile, p)
+	case aProfile:
+		return h.sendAPDU(snb, aProfileChange, nil)
+	case aProfileChange:
+		return h.sendAPDU(snb, aProfileEnq, nil)
+	case aAppInfo:
+		if len(data) < 6 || len(data) < 6+int(data[5]) {
+			return ErrAPDU
+		}
+		h.appInfo = &AppInfo{
+			Type:         data[0],
+			Manufacturer: decodeU16(data[1:3]),
+			Code:         decodeU16(data[3:5]),
+			Menu:         psi.DecodeText(data[6 : 6+data[5]]),
+		}
+	case aCAInfo:
+		ids := make([]psi.CAS, len(data)/2)
+		for i := range ids {
+			ids[i] = psi.CAS(decodeU16(data[2*i:]))
+		}
+		h.caIds = ids
+	case aDateTimeEnq:
+		h.dtInterval = 0
+		if len(data) > 0 {
+			h.dtInterval = time.Duration(data[0]) * time.Second
+		}
+		return h.sendDateTime()
+	}
+	return nil
+}
+
+func bcd(d int) byte {
+	return byte(d/10<<4 | d%10)
+}
+
+func (h *Host) sendDateTime() error {
+	now := time.Now()
+	h.dtNext = now.Add(h.dtInterval)
+	t := now.UTC()
+	mjd := t.Unix()/86400 + 40587
+	return h.sendAPDU(h.dtSnb, aDateTime, []byte{
+		byte(mjd >> 8), byte(mjd),
+		bcd(t.Hour()), bcd(t.Minute()), bcd(t.Second()),
+	})
+}
+
+// AppInfo returns application information received from module.
+func (h *Host) AppInfo() (AppInfo, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.appInfo == nil {
+		return AppInfo{}, false
+	}
+	return *h.appInfo, true
+}
+
+// CASystemIds returns list of CA system ids supported by module or nil if
+// module didn't sent ca_info yet.
+func (h *Host) CASystemIds() []psi.CAS {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]psi.CAS(nil), h.caIds...)
+}
+
+// Ready reports whether module opened CA Support session and sent list of
+// supported CA systems, so SendCAPMT can be used.
+func (h *Host) Ready() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.caSnb != 0 && h.caIds != nil
+}
+
+// SendCAPMT sends ca_pmt created from pmt to module.
+func (h *Host) SendCAPMT(pmt psi.PMT, lm ListManagement, cmd CmdId) error {
+	data, err := MakeCAPMT(pmt, lm, cmd)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.caSnb == 0 {
+		return ErrNoSession
+	}
+	return h.sendAPDU(h.caSnb, aCAPMT, data)
+}
+
+func decodeU16(b []byte) uint16 {
+	return uint16(b[0])<<8 | uint16(b[1])
+}
+
+func decodeU32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
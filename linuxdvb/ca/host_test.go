@@ -0,0 +1,181 @@
+package ca
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ziutek/dvb/ts/psi"
+)
+
+// cam simulates CI module on the link layer.
+type cam struct {
+	t        *testing.T
+	out      [][]byte // SPDUs waiting for T_RCV
+	resp     [][]byte // R_TPDUs waiting for ReadTPDU
+	rx       []byte
+	profile  []byte
+	caPMT    []byte
+	dateTime []byte
+}
+
+func newCAM(t *testing.T) *cam {
+	c := &cam{t: t}
+	c.openSession(ResourceManager)
+	return c
+}
+
+func (c *cam) openSession(rid ResourceId) {
+	c.out = append(c.out, []byte{
+		sOpenSessionRequest, 4,
+		byte(rid >> 24), byte(rid >> 16), byte(rid >> 8), byte(rid),
+	})
+}
+
+func (c *cam) sendAPDU(snb uint16, tag uint32, data []byte) {
+	b := []byte{sSessionNumber, 2, byte(snb >> 8), byte(snb)}
+	b = append(b, byte(tag>>16), byte(tag>>8), byte(tag))
+	b = appendLen(b, len(data))
+	c.out = append(c.out, append(b, data...))
+}
+
+func (c *cam) handle(spdu []byte) {
+	switch spdu[0] {
+	case sOpenSessionResponse:
+		if spdu[2] != sessionOK {
+			c.t.Fatalf("open session status: %#x", spdu[2])
+		}
+		if ResourceId(decodeU32(spdu[3:7])) == DateTime {
+			c.sendAPDU(decodeU16(spdu[7:9]), aDateTimeEnq, []byte{0})
+		}
+	case sSessionNumber:
+		snb := decodeU16(spdu[2:4])
+		apdu := spdu[4:]
+		tag := uint32(apdu[0])<<16 | uint32(apdu[1])<<8 | uint32(apdu[2])
+		l, m := decodeLen(apdu[3:])
+		data := apdu[3+m : 3+m+l]
+		switch tag {
+		case aProfileEnq:
+			c.sendAPDU(snb, aProfile, nil)
+		case aProfileChange:
+			c.sendAPDU(snb, aProfileEnq, nil)
+		case aProfile:
+			c.profile = data
+			c.openSession(ApplicationInfo)
+			c.openSession(CASupport)
+			c.openSession(DateTime)
+		case aAppInfoEnq:
+			c.sendAPDU(snb, aAppInfo, append(
+				[]byte{1, 0x12, 0x34, 0x56, 0x78, 8}, "Test CAM"...,
+			))
+		case aCAInfoEnq:
+			c.sendAPDU(snb, aCAInfo, []byte{0x0b, 0x00, 0x01, 0x00})
+		case aCAPMT:
+			c.caPMT = data
+		case aDateTime:
+			c.dateTime = data
+		default:
+			c.t.Fatalf("unexpected APDU: %#x", tag)
+		}
+	default:
+		c.t.Fatalf("unexpected SPDU: %#x", spdu[0])
+	}
+}
+
+func (c *cam) WriteTPDU(slot, lcid byte, tpdu []byte) error {
+	l, m := decodeLen(tpdu[1:])
+	tcid := tpdu[1+m]
+	data := tpdu[2+m : 1+m+l]
+	var r []byte
+	switch tpdu[0] {
+	case tCreateTC:
+		r = []byte{tCTCReply, 1, tcid}
+	case tDataLast, tDataMore:
+		c.rx = append(c.rx, data...)
+		if tpdu[0] == tDataLast && len(c.rx) > 0 {
+			c.handle(c.rx)
+			c.rx = nil
+		}
+	case tRCV:
+		spdu := c.out[0]
+		c.out = c.out[1:]
+		r = appendLen([]byte{tDataLast}, 1+len(spdu))
+		r = append(append(r, tcid), spdu...)
+	default:
+		c.t.Fatalf("unexpected TPDU: %#x", tpdu[0])
+	}
+	sb := byte(0)
+	if len(c.out) > 0 {
+		sb = sbDataAvail
+	}
+	c.resp = append(c.resp, append(r, tSB, 2, tcid, sb))
+	return nil
+}
+
+func (c *cam) ReadTPDU(buf []byte) (n int, slot, lcid byte, err error) {
+	r := c.resp[0]
+	c.resp = c.resp[1:]
+	return copy(buf, r), 0, 1, nil
+}
+
+func TestHost(t *testing.T) {
+	c := newCAM(t)
+	h := NewHost(c, 0)
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10 && !(h.Ready() && c.dateTime != nil); i++ {
+		if err := h.Poll(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(c.profile) != 4*len(hostResources) {
+		t.Fatalf("bad host profile: %x", c.profile)
+	}
+	ai, ok := h.AppInfo()
+	if !ok || ai.Manufacturer != 0x1234 || ai.Code != 0x5678 ||
+		ai.Menu != "Test CAM" {
+		t.Fatalf("bad application info: %+v", ai)
+	}
+	ids := h.CASystemIds()
+	if len(ids) != 2 || ids[0] != 0x0b00 || ids[1] != 0x0100 {
+		t.Fatalf("bad CA system ids: %v", ids)
+	}
+	if len(c.dateTime) != 5 {
+		t.Fatalf("bad date_time: %x", c.dateTime)
+	}
+
+	s := psi.MakeEmptySection(psi.ISOSectionMaxLen, true)
+	s.SetTableId(2)
+	s.SetTableIdExt(0x0102)
+	s.SetVersion(5)
+	s.SetCurrent(true)
+	s.SetNumber(0)
+	s.SetLastNumber(0)
+	data := []byte{
+		0xe1, 0x00, 0xf0, 0x06,
+		0x09, 0x04, 0x0b, 0x00, 0xe1, 0x23,
+		0x02, 0xe1, 0x01, 0xf0, 0x0c,
+		0x0a, 0x04, 'p', 'o', 'l', 0x00,
+		0x09, 0x04, 0x0b, 0x00, 0xe1, 0x24,
+		0x04, 0xe1, 0x02, 0xf0, 0x00,
+	}
+	copy(s.Alloc(len(data), 0), data)
+	s.MakeCRC()
+	pmt, err := psi.AsPMT(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.SendCAPMT(pmt, ListOnly, CmdOKDescrambling); err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{
+		0x03, 0x01, 0x02, 0xcb, 0xf0, 0x07, 0x01,
+		0x09, 0x04, 0x0b, 0x00, 0xe1, 0x23,
+		0x02, 0xe1, 0x01, 0xf0, 0x07, 0x01,
+		0x09, 0x04, 0x0b, 0x00, 0xe1, 0x24,
+		0x04, 0xe1, 0x02, 0xf0, 0x00,
+	}
+	if !bytes.Equal(c.caPMT, expected) {
+		t.Fatalf("bad ca_pmt:\n%x\nexpected:\n%x", c.caPMT, expected)
+	}
+}
@@ -0,0 +1,19 @@
+// +build linux,cgo
+
+package ca
+
+/*
+#include <sys/ioctl.h>
+#include <linux/dvb/ca.h>
+*/
+import "C"
+
+const (
+	_CA_RESET          = C.CA_RESET
+	_CA_GET_CAP        = C.CA_GET_CAP
+	_CA_GET_SLOT_INFO  = C.CA_GET_SLOT_INFO
+	_CA_GET_DESCR_INFO = C.CA_GET_DESCR_INFO
+	_CA_GET_MSG        = C.CA_GET_MSG
+	_CA_SEND_MSG       = C.CA_SEND_MSG
+	_CA_SET_DESCR      = C.CA_SET_DESCR
+)
@@ -0,0 +1,15 @@
+// +build !cgo,linux,amd64
+
+package ca
+
+// Generated by ../gensyscall/main.c
+
+const (
+	_CA_RESET          = 0x00006f80
+	_CA_GET_CAP        = 0x80106f81
+	_CA_GET_SLOT_INFO  = 0x800c6f82
+	_CA_GET_DESCR_INFO = 0x80086f83
+	_CA_GET_MSG        = 0x810c6f84
+	_CA_SEND_MSG       = 0x410c6f85
+	_CA_SET_DESCR      = 0x40106f86
+)
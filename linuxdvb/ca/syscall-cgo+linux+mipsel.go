@@ -0,0 +1,15 @@
+// +build !cgo,linux,mipsel
+
+package ca
+
+// Generated by ../gensyscall/main.c
+
+const (
+	_CA_RESET          = 0x20006f80
+	_CA_GET_CAP        = 0x40106f81
+	_CA_GET_SLOT_INFO  = 0x400c6f82
+	_CA_GET_DESCR_INFO = 0x40086f83
+	_CA_GET_MSG        = 0x410c6f84
+	_CA_SEND_MSG       = 0x810c6f85
+	_CA_SET_DESCR      = 0x80106f86
+)
@@ -0,0 +1,219 @@
+package ca
+
+import (
+	"errors"
+
+	"github.com/ziutek/dvb"
+)
+
+var (
+	ErrTPDU      = dvb.TemporaryError("ca: malformed TPDU")
+	ErrSPDU      = dvb.TemporaryError("ca: malformed SPDU")
+	ErrAPDU      = dvb.TemporaryError("ca: malformed APDU")
+	ErrTransport = errors.New("ca: unexpected transport layer response")
+)
+
+// Link is an interface to CI link layer. It transfers TPDUs between host and
+// modules. Device implements Link, tests can use simulated module.
+type Link interface {
+	// WriteTPDU writes TPDU to module in slot using link connection lcid.
+	WriteTPDU(slot, lcid byte, tpdu []byte) error
+
+	// ReadTPDU reads TPDU received from module into buf.
+	ReadTPDU(buf []byte) (n int, slot, lcid byte, err error)
+}
+
+// Transport layer tags (EN 50221 A.4.1.13)
+const (
+	tSB          = 0x80
+	tRCV         = 0x81
+	tCreateTC    = 0x82
+	tCTCReply    = 0x83
+	tDeleteTC    = 0x84
+	tDTCReply    = 0x85
+	tRequestTC   = 0x86
+	tNewTC       = 0x87
+	tTCError     = 0x88
+	tDataLast    = 0xa0
+	tDataMore    = 0xa1
+	sbDataAvail  = 0x80
+	maxTPDULen   = 4096
+	maxTPDUData  = 255
+	maxSPDUChunk = maxTPDUData - 1 // minus t_c_id
+)
+
+// appendLen appends ASN.1 BER encoded length_field to b.
+func appendLen(b []byte, n int) []byte {
+	switch {
+	case n < 0x80:
+		return append(b, byte(n))
+	case n <= 0xff:
+		return append(b, 0x81, byte(n))
+	case n <= 0xffff:
+		return append(b, 0x82, byte(n>>8), byte(n))
+	}
+	return append(b, 0x83, byte(n>>16), byte(n>>8), byte(n))
+}
+
+// decodeLen decodes ASN.1 BER length_field. It returns decoded length and
+// size of length_field or m == 0 if b doesn't contain valid length_field.
+func decodeLen(b []byte) (n, m int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	if b[0]&0x80 == 0 {
+		return int(b[0]), 1
+	}
+	m = int(b[0]&0x7f) + 1
+	if m == 1 || m > 4 || len(b) < m {
+		return 0, 0
+	}
+	for _, c := range b[1:m] {
+		n = n<<8 | int(c)
+	}
+	return n, m
+}
+
+// Transport represents host side of EN 50221 transport connection.
+type Transport struct {
+	link      Link
+	slot      byte
+	tcid      byte
+	buf       []byte
+	dataAvail bool
+}
+
+// NewTransport returns transport connection tcid to module in slot.
+func NewTransport(l Link, slot, tcid byte) *Transport {
+	return &Transport{
+		link: l,
+		slot: slot,
+		tcid: tcid,
+		buf:  make([]byte, maxTPDULen),
+	}
+}
+
+// DataAvail reports whether module signaled in last status byte that it has
+// data to send.
+func (t *Transport) DataAvail() bool {
+	return t.dataAvail
+}
+
+// exchange sends C_TPDU and reads R_TPDU. It returns tag and data of data
+// object of R_TPDU (or 0 if R_TPDU contains only status).
+func (t *Transport) exchange(tag byte, data []byte) (rtag byte, rdata []byte, err error) {
+	c := make([]byte, 0, 6+len(data))
+	c = append(c, tag)
+	c = appendLen(c, 1+len(data))
+	c = append(c, t.tcid)
+	c = append(c, data...)
+	if err = t.link.WriteTPDU(t.slot, t.tcid, c); err != nil {
+		return
+	}
+	n, _, _, err := t.link.ReadTPDU(t.buf)
+	if err != nil {
+		return
+	}
+	r := t.buf[:n]
+	sb := false
+	for len(r) > 0 {
+		tag := r[0]
+		l, m := decodeLen(r[1:])
+		if m == 0 || l < 1 || len(r) < 1+m+l {
+			return 0, nil, ErrTPDU
+		}
+		obj := r[1+m : 1+m+l]
+		r = r[1+m+l:]
+		if obj[0] != t.tcid {
+			return 0, nil, ErrTPDU
+		}
+		if tag == tSB {
+			if l != 2 {
+				return 0, nil, ErrTPDU
+			}
+			t.dataAvail = obj[1]&sbDataAvail != 0
+			sb = true
+			continue
+		}
+		rtag, rdata = tag, obj[1:]
+	}
+	if !sb {
+		return 0, nil, ErrTPDU
+	}
+	return
+}
+
+// Create creates transport connection.
+func (t *Transport) Create() error {
+	tag, _, err := t.exchange(tCreateTC, nil)
+	if err != nil {
+		return err
+	}
+	if tag != tCTCReply {
+		return ErrTransport
+	}
+	return nil
+}
+
+// Delete deletes transport connection.
+func (t *Transport) Delete() error {
+	tag, _, err := t.exchange(tDeleteTC, nil)
+	if err != nil {
+		return err
+	}
+	if tag != tDTCReply {
+		return ErrTransport
+	}
+	return nil
+}
+
+// Send sends SPDU to module.
+func (t *Transport) Send(spdu []byte) error {
+	for {
+		tag := byte(tDataLast)
+		chunk := spdu
+		if len(chunk) > maxSPDUChunk {
+			tag = tDataMore
+			chunk = chunk[:maxSPDUChunk]
+		}
+		rtag, _, err := t.exchange(tag, chunk)
+		if err != nil {
+			return err
+		}
+		if rtag != 0 {
+			return ErrTransport
+		}
+		spdu = spdu[len(chunk):]
+		if tag == tDataLast {
+			return nil
+		}
+	}
+}
+
+// Receive polls module and returns SPDU received from it or nil if module has
+// nothing to send.
+func (t *Transport) Receive() ([]byte, error) {
+	if !t.dataAvail {
+		if _, _, err := t.exchange(tDataLast, nil); err != nil {
+			return nil, err
+		}
+		if !t.dataAvail {
+			return nil, nil
+		}
+	}
+	var spdu []byte
+	for {
+		tag, data, err := t.exchange(tRCV, nil)
+		if err != nil {
+			return nil, err
+		}
+		switch tag {
+		case tDataMore:
+			spdu = append(spdu, data...)
+		case tDataLast:
+			return append(spdu, data...), nil
+		default:
+			return nil, ErrTransport
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package demux
+
+import (
+	"context"
+
+	"github.com/ziutek/dvb/ts/psi"
+)
+
+type ctxSectionReader struct {
+	ctx context.Context
+	f   SectionFilter
+}
+
+func (r ctxSectionReader) ReadSection(s psi.Section) error {
+	return r.f.ReadSectionContext(r.ctx, s)
+}
+
+// CollectTables opens section filter for current tables with tableId carried
+// in pid and sends complete tables (every version once) to out. It returns
+// when ctx is done or an unrecoverable error occurs. Use psi.PAT(t),
+// psi.SDT(t), psi.NIT(t), ... to convert received tables.
+func (d Device) CollectTables(ctx context.Context, pid int16, tableId byte, out chan<- psi.Table) error {
+	var p Pattern
+	p.SetTableId(tableId)
+	p.SetCurrent(true)
+	f, err := d.NewSectionReader(pid, p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	c := psi.NewTableCollector(ctxSectionReader{ctx, f}, tableId, true)
+	return c.Run(ctx, out)
+}
@@ -2,8 +2,10 @@
 package demux
 
 import (
+	"context"
 	"os"
-	"syscall"
+	"sync"
+	"time"
 	"unsafe"
 )
 
@@ -86,14 +88,6 @@ type StreamFilter struct {
 	*Filter
 }
 
-func (f StreamFilter) AddPid(pid int16) error {
-	return f.addPid(pid)
-}
-
-func (f StreamFilter) DelPid(pid int16) error {
-	return f.delPid(pid)
-}
-
 // Parameters for SectionFilter
 
 type Pattern struct {
@@ -119,27 +113,70 @@ type Device string
 
 // Returns a handler to elementary stream filter.
 func (d Device) NewStreamFilter(p *StreamFilterParam) (StreamFilter, error) {
-	f, err := newFilter(d, _DMX_SET_PES_FILTER, unsafe.Pointer(p), p.Out == OutTSTap)
+	f, err := newFilter(d, _DMX_SET_PES_FILTER, unsafe.Pointer(p))
 	return StreamFilter{f}, err
 }
 
 // Returns a handler to section filter.
 func (d Device) NewSectionFilter(p *SectionFilterParam) (SectionFilter, error) {
-	f, err := newFilter(d, _DMX_SET_FILTER, unsafe.Pointer(p), false)
+	f, err := newFilter(d, _DMX_SET_FILTER, unsafe.Pointer(p))
 	return SectionFilter{f}, err
 }
 
+// DVR represents Linux DVB DVR device. Use OpenDVR to obtain DVR that
+// supports read deadlines and ReadContext. DVR should be closed using its
+// Close method.
 type DVR struct {
 	*os.File
 }
 
-func (dvr DVR) SetBufferSize(n int) error {
-	_, _, e := syscall.Syscall(
-		syscall.SYS_IOCTL, uintptr(dvr.File.Fd()),
-		_DMX_SET_BUFFER_SIZE, uintptr(n),
-	)
-	if e != 0 {
-		return e
+// dvrReaders maps files of DVR devices to their poll readers, so DVR can be
+// used as a value and its zero value remains usable.
+var dvrReaders sync.Map
+
+func (dvr DVR) reader() *pollReader {
+	if r, ok := dvrReaders.Load(dvr.File); ok {
+		return r.(*pollReader)
 	}
-	return nil
+	r, _ := dvrReaders.LoadOrStore(dvr.File, newPollReader(dvr.File))
+	return r.(*pollReader)
+}
+
+// OpenDVR opens DVR device in non-blocking mode, so it is handled by Go
+// runtime poller.
+func OpenDVR(path string) (DVR, error) {
+	f, err := openNonblock(path, os.O_RDONLY)
+	if err != nil {
+		return DVR{}, err
+	}
+	return DVR{f}, nil
+}
+
+func (dvr DVR) Read(buf []byte) (int, error) {
+	return dvr.reader().Read(buf)
+}
+
+// ReadContext works like Read but returns ctx.Err() if ctx is done before any
+// data is available. Concurrent ReadContext calls are serialized.
+func (dvr DVR) ReadContext(ctx context.Context, buf []byte) (int, error) {
+	return dvr.reader().ReadContext(ctx, buf)
+}
+
+// SetReadDeadline sets the deadline for future Read calls and any
+// currently-blocked Read call. A zero value for t means Read will not time
+// out.
+func (dvr DVR) SetReadDeadline(t time.Time) error {
+	return dvr.reader().SetReadDeadline(t)
+}
+
+func (dvr DVR) Close() error {
+	if r, ok := dvrReaders.Load(dvr.File); ok {
+		dvrReaders.Delete(dvr.File)
+		return r.(*pollReader).Close()
+	}
+	return dvr.File.Close()
+}
+
+func (dvr DVR) SetBufferSize(n int) error {
+	return ioctl(dvr.File, _DMX_SET_BUFFER_SIZE, uintptr(n))
 }
@@ -1,270 +1,318 @@
 package demux
 
 import (
+	"context"
+	"io"
 	"os"
 	"sync"
 	"syscall"
+	"time"
 	"unsafe"
 )
 
-type fdset syscall.FdSet
-
-func (s *fdset) Sys() *syscall.FdSet {
-	return (*syscall.FdSet)(s)
-}
-
-func (s *fdset) Set(fd uintptr) {
-	bits := 8 * unsafe.Sizeof(s.Bits[0])
-	if fd >= bits*uintptr(len(s.Bits)) {
-		panic("fdset: fd out of range")
+// openNonblock opens file in non-blocking mode so it is handled by Go runtime
+// poller (blocking Read parks goroutine, deadlines work).
+func openNonblock(path string, flag int) (*os.File, error) {
+	for {
+		fd, err := syscall.Open(
+			path, flag|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0,
+		)
+		if err == nil {
+			return os.NewFile(uintptr(fd), path), nil
+		}
+		if err != syscall.EINTR {
+			return nil, &os.PathError{Op: "open", Path: path, Err: err}
+		}
 	}
-	n := fd / bits
-	m := fd % bits
-	s.Bits[n] |= 1 << m
 }
 
-func (s *fdset) IsSet(fd uintptr) bool {
-	bits := 8 * unsafe.Sizeof(s.Bits[0])
-	if fd >= bits*uintptr(len(s.Bits)) {
-		panic("fdset: fd out of range")
+func control(f *os.File, fn func(fd uintptr) syscall.Errno) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var e syscall.Errno
+	err = rc.Control(func(fd uintptr) {
+		e = fn(fd)
+	})
+	if err != nil {
+		return err
+	}
+	if e != 0 {
+		return e
 	}
-	n := fd / bits
-	m := fd % bits
-	return s.Bits[n]&(1<<m) != 0
+	return nil
 }
 
-type syn struct {
-	pr, pw *os.File
-	m      sync.Mutex
+// ioctl calls ioctl on f without switching f to blocking mode (as f.Fd()
+// does).
+func ioctl(f *os.File, req, arg uintptr) error {
+	return control(f, func(fd uintptr) syscall.Errno {
+		_, _, e := syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg)
+		return e
+	})
 }
 
-func (s *syn) pread() error {
-	var b [1]byte
-	_, err := s.pr.Read(b[:])
-	return err
+// ioctlPtr works like ioctl but passes pointer to data as ioctl argument.
+func ioctlPtr(f *os.File, req uintptr, p unsafe.Pointer) error {
+	return control(f, func(fd uintptr) syscall.Errno {
+		_, _, e := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(p))
+		return e
+	})
 }
 
-var nl = []byte{'\n'}
+var aLongTimeAgo = time.Unix(1, 0)
 
-func (s *syn) pwrite() error {
-	_, err := s.pw.Write(nl)
-	return err
+// pollReader reads from file handled by Go runtime poller. Its blocked Read
+// calls can be woken up by filter commands and by contexts passed to
+// ReadContext without disturbing read deadline set by SetReadDeadline.
+type pollReader struct {
+	file *os.File
+
+	mu       sync.Mutex
+	deadline time.Time // deadline set by SetReadDeadline
+	readers  int       // number of Read calls in progress
+	woken    bool      // file has past deadline set by wakeUp
+	kicked   bool      // next read returns immediately
+
+	once    sync.Once
+	watch   chan (<-chan struct{}) // ctx.Done of current ReadContext
+	ack     chan struct{}
+	closing chan struct{}
 }
 
-func newSyn() (*syn, error) {
-	s := new(syn)
-	var err error
-	s.pr, s.pw, err = os.Pipe()
-	if err != nil {
-		return nil, err
+func newPollReader(f *os.File) *pollReader {
+	return &pollReader{
+		file:    f,
+		watch:   make(chan (<-chan struct{})),
+		ack:     make(chan struct{}),
+		closing: make(chan struct{}),
 	}
-	return s, nil
 }
 
-func (s *syn) Close() {
-	s.pw.Close()
-	s.pr.Close()
+// wakeUp interrupts all Read calls in progress.
+func (r *pollReader) wakeUp() {
+	r.mu.Lock()
+	if r.readers > 0 && !r.woken {
+		r.woken = true
+		r.file.SetReadDeadline(aLongTimeAgo)
+	}
+	r.mu.Unlock()
 }
 
-// WaitRead returns true if f can be readed without blocking or false if not or
-// error.
-func (s *syn) WaitRead(f *os.File) (bool, error) {
-	pfd := s.pr.Fd()
-	ffd := f.Fd()
-	nfd := 1
-	if pfd < ffd {
-		nfd += int(ffd)
-	} else {
-		nfd += int(pfd)
+// interrupt works like wakeUp but if there is no Read in progress the next
+// one returns immediately.
+func (r *pollReader) interrupt() {
+	r.mu.Lock()
+	if r.readers == 0 {
+		r.kicked = true
 	}
-	s.m.Lock()
+	r.mu.Unlock()
+	r.wakeUp()
+}
+
+// read reads from r.file. It returns woken == true if read was interrupted
+// by wakeUp or interrupt or if done is closed before read starts.
+func (r *pollReader) read(buf []byte, done <-chan struct{}) (n int, err error,
+	woken bool) {
+	r.mu.Lock()
+	if r.kicked {
+		r.kicked = false
+		r.mu.Unlock()
+		return 0, nil, true
+	}
+	select {
+	case <-done:
+		// wakeUp called by watchContexts could miss this read.
+		r.mu.Unlock()
+		return 0, nil, true
+	default:
+	}
+	r.readers++
+	r.mu.Unlock()
+	n, err = r.file.Read(buf)
+	r.mu.Lock()
+	r.readers--
+	woken = r.woken
+	if woken && r.readers == 0 {
+		r.woken = false
+		r.file.SetReadDeadline(r.deadline)
+	}
+	r.mu.Unlock()
+	if woken && err != nil && os.IsTimeout(err) {
+		err = nil
+	}
+	return
+}
+
+func (r *pollReader) Read(buf []byte) (int, error) {
+	n, err, _ := r.read(buf, nil)
+	return n, err
+}
+
+// watchContexts serves all ReadContext calls, so ReadContext doesn't need to
+// start goroutine on every call.
+func (r *pollReader) watchContexts() {
 	for {
-		var r fdset
-		var n int
-		var err error
-		r.Set(ffd)
-		r.Set(pfd)
-		for {
-			n, err = syscall.Select(nfd, r.Sys(), nil, nil, nil)
-			if err != syscall.EINTR {
-				break
+		select {
+		case done := <-r.watch:
+			select {
+			case <-done:
+				r.wakeUp()
+				<-r.ack
+			case <-r.ack:
 			}
-		}
-		if err != nil {
-			return false, err
-		}
-		if n > 0 {
-			if r.IsSet(pfd) {
-				// Command waits for access f.
-				s.m.Unlock()
-				return false, nil
-			}
-			return true, nil
+		case <-r.closing:
+			return
 		}
 	}
 }
 
-func (s *syn) Done() {
-	s.m.Unlock()
+func (r *pollReader) ReadContext(ctx context.Context, buf []byte) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	done := ctx.Done()
+	if done == nil {
+		return r.Read(buf)
+	}
+	r.once.Do(func() { go r.watchContexts() })
+	select {
+	case r.watch <- done:
+	case <-done:
+		return 0, ctx.Err()
+	case <-r.closing:
+		return 0, os.ErrClosed
+	}
+	n, err, woken := r.read(buf, done)
+	r.ack <- struct{}{}
+	if woken && n == 0 && err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return n, err
 }
 
-func (s *syn) WaitCmd() error {
-	if err := s.pwrite(); err != nil {
-		return err
+func (r *pollReader) SetReadDeadline(t time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deadline = t
+	if r.woken {
+		return nil // Will be set by last interrupted Read.
 	}
-	s.m.Lock()
-	return s.pread()
+	return r.file.SetReadDeadline(t)
 }
 
-// Filter implements common functionality for all demux filters.
+func (r *pollReader) Close() error {
+	select {
+	case <-r.closing:
+	default:
+		close(r.closing)
+	}
+	return r.file.Close()
+}
+
+// Filter implements common functionality for all demux filters. Filter uses Go
+// runtime poller so commands (Start, Stop, AddPid, ...) can be issued
+// concurrently with Read. Every command wakes up blocked Read that returns
+// zero bytes and nil error. Blocked Read can be also interrupted by Close or by
+// read deadline.
 type Filter struct {
 	data *os.File
-	s    *syn
+	r    *pollReader
 }
 
-func newFilter(d Device, typ uintptr, p unsafe.Pointer, dvr bool) (*Filter, error) {
-	f, err := os.Open(string(d))
+func newFilter(d Device, typ uintptr, p unsafe.Pointer) (*Filter, error) {
+	f, err := openNonblock(string(d), os.O_RDONLY)
 	if err != nil {
 		return nil, err
 	}
-	if _, _, e := syscall.Syscall(
-		syscall.SYS_IOCTL,
-		uintptr(f.Fd()),
-		typ,
-		uintptr(p),
-	); e != 0 {
-		return nil, e
-	}
-	if dvr {
-		return &Filter{data: f}, nil
-	}
-	s, err := newSyn()
-	if err != nil {
+	if err := ioctlPtr(f, typ, p); err != nil {
+		f.Close()
 		return nil, err
 	}
-	return &Filter{data: f, s: s}, nil
+	return &Filter{data: f, r: newPollReader(f)}, nil
 }
 
 func (f *Filter) Close() error {
-	if f.s != nil {
-		f.s.Close()
-	}
-	return f.data.Close()
+	return f.r.Close()
 }
 
 func (f *Filter) Read(buf []byte) (int, error) {
-	if f.s != nil {
-		if ok, err := f.s.WaitRead(f.data); !ok {
-			return 0, err
-		}
-		defer f.s.Done()
-	}
-	return f.data.Read(buf)
+	return f.r.Read(buf)
 }
 
-func (f *Filter) start() error {
-	_, _, e := syscall.Syscall(
-		syscall.SYS_IOCTL, uintptr(f.data.Fd()), _DMX_START, 0,
-	)
-	if e != 0 {
-		return e
-	}
-	return nil
+// ReadContext works like Read but returns ctx.Err() if ctx is done before
+// any data is available. Concurrent ReadContext calls are serialized.
+func (f *Filter) ReadContext(ctx context.Context, buf []byte) (int, error) {
+	return f.r.ReadContext(ctx, buf)
 }
 
-func (f *Filter) stop() error {
-	_, _, e := syscall.Syscall(
-		syscall.SYS_IOCTL, uintptr(f.data.Fd()), _DMX_STOP, 0,
+// readNonblock reads available data without waiting for more. It returns
+// syscall.EAGAIN error (wrapped in *os.PathError) if there is no data to
+// read.
+func (f *Filter) readNonblock(buf []byte) (int, error) {
+	rc, err := f.data.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var (
+		n int
+		e error
 	)
-	if e != 0 {
-		return e
+	err = rc.Read(func(fd uintptr) bool {
+		for {
+			n, e = syscall.Read(int(fd), buf)
+			if e != syscall.EINTR {
+				return true // never wait for data
+			}
+		}
+	})
+	if err != nil {
+		return 0, err
 	}
-	return nil
+	if e != nil {
+		return 0, &os.PathError{Op: "read", Path: f.data.Name(), Err: e}
+	}
+	if n == 0 && len(buf) > 0 {
+		return 0, io.EOF
+	}
+	return n, nil
 }
 
-func (f *Filter) setBufferSize(n int) error {
-	_, _, e := syscall.Syscall(
-		syscall.SYS_IOCTL, uintptr(f.data.Fd()),
-		_DMX_SET_BUFFER_SIZE, uintptr(n),
-	)
-	if e != 0 {
-		return e
-	}
-	return nil
+// interrupt wakes up blocked Read or makes next Read return immediately.
+func (f *Filter) interrupt() {
+	f.r.interrupt()
 }
 
-func (f *Filter) addPid(pid int16) error {
-	_, _, e := syscall.Syscall(
-		syscall.SYS_IOCTL,
-		uintptr(f.data.Fd()),
-		_DMX_ADD_PID,
-		uintptr(unsafe.Pointer(&pid)),
-	)
-	if e != 0 {
-		return e
-	}
-	return nil
+// SetReadDeadline sets the deadline for future Read calls and any
+// currently-blocked Read call. A zero value for t means Read will not time
+// out.
+func (f *Filter) SetReadDeadline(t time.Time) error {
+	return f.r.SetReadDeadline(t)
 }
 
-func (f *Filter) delPid(pid int16) error {
-	_, _, e := syscall.Syscall(
-		syscall.SYS_IOCTL,
-		uintptr(f.data.Fd()),
-		_DMX_REMOVE_PID,
-		uintptr(unsafe.Pointer(&pid)),
-	)
-	if e != 0 {
-		return e
-	}
-	return nil
+// cmd runs ioctl and wakes up blocked Read.
+func (f *Filter) cmd(err error) error {
+	f.r.wakeUp()
+	return err
 }
 
 func (f *Filter) Start() error {
-	if f.s != nil {
-		if err := f.s.WaitCmd(); err != nil {
-			return err
-		}
-		defer f.s.Done()
-	}
-	return f.start()
+	return f.cmd(ioctl(f.data, _DMX_START, 0))
 }
 
 func (f *Filter) Stop() error {
-	if f.s != nil {
-		if err := f.s.WaitCmd(); err != nil {
-			return err
-		}
-		defer f.s.Done()
-	}
-	return f.stop()
+	return f.cmd(ioctl(f.data, _DMX_STOP, 0))
 }
 
 func (f *Filter) SetBufferSize(n int) error {
-	if f.s != nil {
-		if err := f.s.WaitCmd(); err != nil {
-			return err
-		}
-		defer f.s.Done()
-	}
-	return f.setBufferSize(n)
+	return f.cmd(ioctl(f.data, _DMX_SET_BUFFER_SIZE, uintptr(n)))
 }
 
 func (f *Filter) AddPid(pid int16) error {
-	if f.s != nil {
-		if err := f.s.WaitCmd(); err != nil {
-			return err
-		}
-		defer f.s.Done()
-	}
-	return f.addPid(pid)
+	return f.cmd(ioctlPtr(f.data, _DMX_ADD_PID, unsafe.Pointer(&pid)))
 }
 
 func (f *Filter) DelPid(pid int16) error {
-	if f.s != nil {
-		if err := f.s.WaitCmd(); err != nil {
-			return err
-		}
-		defer f.s.Done()
-	}
-	return f.delPid(pid)
+	return f.cmd(ioctlPtr(f.data, _DMX_REMOVE_PID, unsafe.Pointer(&pid)))
 }
@@ -0,0 +1,136 @@
+package demux
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func newPipeReader(t *testing.T) (*pollReader, *os.File) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newPollReader(pr), pw
+}
+
+func TestPollReaderWakeUp(t *testing.T) {
+	r, w := newPipeReader(t)
+	defer w.Close()
+	defer r.Close()
+	deadline := time.Now().Add(time.Hour)
+	if err := r.SetReadDeadline(deadline); err != nil {
+		t.Fatal(err)
+	}
+	type result struct {
+		n   int
+		err error
+	}
+	c := make(chan result)
+	go func() {
+		n, err := r.Read(make([]byte, 8))
+		c <- result{n, err}
+	}()
+	for {
+		r.mu.Lock()
+		readers := r.readers
+		r.mu.Unlock()
+		if readers != 0 {
+			break
+		}
+		runtime.Gosched()
+	}
+	r.wakeUp()
+	if res := <-c; res.n != 0 || res.err != nil {
+		t.Fatalf("woken Read: %d %v", res.n, res.err)
+	}
+	// User deadline should be restored, so data can be read.
+	w.Write([]byte{1, 2, 3})
+	if n, err := r.Read(make([]byte, 8)); n != 3 || err != nil {
+		t.Fatalf("Read: %d %v", n, err)
+	}
+	if r.deadline != deadline || r.woken {
+		t.Fatal("deadline not restored")
+	}
+}
+
+func TestPollReaderContext(t *testing.T) {
+	r, w := newPipeReader(t)
+	defer w.Close()
+	defer r.Close()
+	buf := make([]byte, 8)
+	// Warm up watcher goroutine.
+	ctx, cancel := context.WithCancel(context.Background())
+	w.Write([]byte{1})
+	if n, err := r.ReadContext(ctx, buf); n != 1 || err != nil {
+		t.Fatalf("ReadContext: %d %v", n, err)
+	}
+	ng := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		w.Write([]byte{1})
+		if n, err := r.ReadContext(ctx, buf); n != 1 || err != nil {
+			t.Fatalf("ReadContext: %d %v", n, err)
+		}
+	}
+	if n := runtime.NumGoroutine(); n > ng {
+		t.Errorf("goroutines: %d > %d", n, ng)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if _, err := r.ReadContext(ctx, buf); err != context.Canceled {
+		t.Fatalf("canceled ReadContext: %v", err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := r.ReadContext(ctx, buf); err != context.DeadlineExceeded {
+		t.Fatalf("ReadContext with deadline: %v", err)
+	}
+	// Read deadline set by user isn't affected by ReadContext.
+	r.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	if _, err := r.Read(buf); !os.IsTimeout(err) {
+		t.Fatalf("Read after deadline: %v", err)
+	}
+}
+
+func TestPollReaderDoneBeforeRead(t *testing.T) {
+	r, w := newPipeReader(t)
+	defer w.Close()
+	defer r.Close()
+	done := make(chan struct{})
+	close(done)
+	// Context canceled after ReadContext registered it but before read
+	// started must not block.
+	n, err, woken := r.read(make([]byte, 8), done)
+	if n != 0 || err != nil || !woken {
+		t.Fatalf("read: %d %v %t", n, err, woken)
+	}
+}
+
+func TestDVRZeroValue(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pw.Close()
+	dvr := DVR{File: pr}
+	pw.Write([]byte{1, 2})
+	buf := make([]byte, 8)
+	if n, err := dvr.Read(buf); n != 2 || err != nil {
+		t.Fatalf("Read: %d %v", n, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := dvr.ReadContext(ctx, buf); err != context.DeadlineExceeded {
+		t.Fatalf("ReadContext: %v", err)
+	}
+	if err := dvr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dvrReaders.Load(pr); ok {
+		t.Fatal("poll reader not removed on Close")
+	}
+}
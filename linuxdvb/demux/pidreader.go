@@ -0,0 +1,282 @@
+package demux
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/ziutek/dvb"
+	"github.com/ziutek/dvb/ts"
+)
+
+// FullTS is a PID that selects all packets of transport stream.
+const FullTS = 0x2000
+
+var ErrBadPid = errors.New("bad PID")
+
+// PidReader reads TS packets of a set of PIDs. It uses one kernel filter with
+// all PIDs of the set as long as the demux accepts new PIDs. If kernel
+// filter can't be created or extended it switches to full transport stream
+// (PID 0x2000) filtered in software. PIDs can be added and removed
+// concurrently with ReadPkt without losing packets of remaining PIDs. After
+// switching to software filtering PidReader never returns to kernel
+// filtering.
+type PidReader struct {
+	newFilter func(pid int16) (tsFilter, error)
+
+	mu   sync.Mutex
+	pids [FullTS + 1]bool
+	f    tsFilter // filter read by ReadPkt
+	next tsFilter // full TS filter that will replace f
+	soft bool
+
+	// Packets returned from old filter after full TS filter was opened. They
+	// can be received by both filters. Used only by ReadPkt.
+	overlap map[int16][]ts.ArrayPkt
+}
+
+// tsFilter is kernel filter used by PidReader.
+type tsFilter interface {
+	io.ReadCloser
+	AddPid(pid int16) error
+	DelPid(pid int16) error
+	readNonblock(buf []byte) (int, error)
+	interrupt()
+}
+
+// NewPidReader returns reader of packets that belong to pids.
+func (d Device) NewPidReader(pids ...int16) (*PidReader, error) {
+	return newPidReader(d.newTSFilter, pids)
+}
+
+func newPidReader(newFilter func(pid int16) (tsFilter, error),
+	pids []int16) (*PidReader, error) {
+	for _, pid := range pids {
+		if uint16(pid) > FullTS {
+			return nil, ErrBadPid
+		}
+	}
+	r := &PidReader{newFilter: newFilter}
+	var err error
+	if len(pids) > 0 {
+		r.f, err = newFilter(pids[0])
+		if err == nil {
+			for _, pid := range pids[1:] {
+				if err = r.f.AddPid(pid); err != nil {
+					r.f.Close()
+					break
+				}
+			}
+		}
+	}
+	if len(pids) == 0 || err != nil {
+		if r.f, err = newFilter(FullTS); err != nil {
+			return nil, err
+		}
+		r.soft = true
+	}
+	for _, pid := range pids {
+		r.pids[pid] = true
+	}
+	return r, nil
+}
+
+func (d Device) newTSFilter(pid int16) (tsFilter, error) {
+	sf, err := d.NewStreamFilter(&StreamFilterParam{
+		Pid:   pid,
+		In:    InFrontend,
+		Out:   OutTSDemuxTap,
+		Type:  Other,
+		Flags: ImmediateStart,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sf.Filter, nil
+}
+
+// Software returns true if r uses software filtering.
+func (r *PidReader) Software() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.soft
+}
+
+// Pids returns current set of PIDs.
+func (r *PidReader) Pids() []int16 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var pids []int16
+	for pid, ok := range r.pids {
+		if ok {
+			pids = append(pids, int16(pid))
+		}
+	}
+	return pids
+}
+
+// AddPid adds pid to the set. If kernel filter can't be extended AddPid opens
+// full TS filter and wakes up ReadPkt that drains old filter before it
+// switches to new one.
+func (r *PidReader) AddPid(pid int16) error {
+	if uint16(pid) > FullTS {
+		return ErrBadPid
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pids[pid] {
+		return nil
+	}
+	if !r.soft {
+		err := r.f.AddPid(pid)
+		if err != nil {
+			next, e := r.newFilter(FullTS)
+			if e != nil {
+				return err
+			}
+			r.next = next
+			r.soft = true
+			r.f.interrupt()
+		}
+	}
+	r.pids[pid] = true
+	return nil
+}
+
+// DelPid removes pid from the set.
+func (r *PidReader) DelPid(pid int16) error {
+	if uint16(pid) > FullTS {
+		return ErrBadPid
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.pids[pid] {
+		return nil
+	}
+	r.pids[pid] = false
+	if r.soft {
+		return nil
+	}
+	return r.f.DelPid(pid)
+}
+
+func (r *PidReader) accept(pkt ts.Pkt) bool {
+	pid := pkt.Pid()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pids[pid] || r.pids[FullTS]
+}
+
+// duplicate reports whether pkt read from full TS filter was already returned
+// from old filter. The first packet of every PID is searched in packets
+// returned from old filter after full TS filter was opened. If it is found,
+// all following packets returned from old filter are skipped too.
+func (r *PidReader) duplicate(pkt ts.Pkt) bool {
+	pid := pkt.Pid()
+	pkts, ok := r.overlap[pid]
+	if !ok {
+		return false
+	}
+	for i := range pkts {
+		if bytes.Equal(pkts[i][:], pkt.Bytes()) {
+			if i == len(pkts)-1 {
+				delete(r.overlap, pid)
+			} else {
+				r.overlap[pid] = pkts[i+1:]
+			}
+			return true
+		}
+	}
+	delete(r.overlap, pid) // no overlap or all duplicates skipped
+	return false
+}
+
+// switchFilter replaces drained filter by full TS filter.
+func (r *PidReader) switchFilter() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.next == nil {
+		return // closed
+	}
+	r.f.Close()
+	r.f, r.next = r.next, nil
+}
+
+// read reads from current filter. After switch to software filtering was
+// requested it reads remaining data from old filter without blocking and
+// replaces it by full TS filter when old one is drained. Filter can be
+// replaced only between packets (if pos == 0).
+func (r *PidReader) read(buf []byte, pos int) (int, error) {
+	r.mu.Lock()
+	f, next := r.f, r.next
+	r.mu.Unlock()
+	if next == nil {
+		return f.Read(buf)
+	}
+	n, err := f.readNonblock(buf)
+	if e, ok := err.(*os.PathError); ok && e.Err == syscall.EAGAIN {
+		if pos != 0 {
+			return f.Read(buf) // rest of packet
+		}
+		r.switchFilter()
+		return 0, nil
+	}
+	return n, err
+}
+
+// ReadPkt reads next packet that belongs to the set of PIDs. It returns
+// dvb.ErrOverflow if kernel buffer overflowed (some packets were lost).
+func (r *PidReader) ReadPkt(pkt ts.Pkt) error {
+	buf := pkt.Bytes()
+	for {
+		for n := 0; n < len(buf); {
+			m, err := r.read(buf[n:], n)
+			if err != nil {
+				if e, ok := err.(*os.PathError); ok && e.Err == syscall.EOVERFLOW {
+					return dvb.ErrOverflow
+				}
+				if err == io.EOF && n > 0 {
+					err = io.ErrUnexpectedEOF
+				}
+				return err
+			}
+			n += m
+		}
+		if !pkt.SyncOK() {
+			return ts.ErrSync
+		}
+		if !r.accept(pkt) {
+			continue
+		}
+		r.mu.Lock()
+		draining := r.next != nil // pkt was read from old filter
+		r.mu.Unlock()
+		if draining {
+			if r.overlap == nil {
+				r.overlap = make(map[int16][]ts.ArrayPkt)
+			}
+			var p ts.ArrayPkt
+			copy(p[:], pkt.Bytes())
+			pid := pkt.Pid()
+			r.overlap[pid] = append(r.overlap[pid], p)
+			return nil
+		}
+		if len(r.overlap) == 0 || !r.duplicate(pkt) {
+			return nil
+		}
+	}
+}
+
+// Close closes kernel filters.
+func (r *PidReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.next != nil {
+		r.next.Close()
+		r.next = nil
+	}
+	return r.f.Close()
+}
@@ -0,0 +1,224 @@
+package demux
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/ziutek/dvb/ts"
+)
+
+type fakeFilter struct {
+	pkts   chan []byte // nil wakes up Read
+	pids   int
+	limit  int // zero means no limit
+	closed bool
+}
+
+func (f *fakeFilter) send(pid int16, cc int8, payload bool) {
+	p := new(ts.ArrayPkt)
+	p.SetSync()
+	p.SetPid(pid)
+	p.SetCC(cc)
+	p.SetContainsPayload(payload)
+	p.SetContainsAF(!payload)
+	f.pkts <- p.Bytes()
+}
+
+// sendSeq sends packet with payload that contains n.
+func (f *fakeFilter) sendSeq(pid int16, n int) {
+	p := new(ts.ArrayPkt)
+	p.SetSync()
+	p.SetPid(pid)
+	p.SetCC(int8(n & 15))
+	p.SetContainsPayload(true)
+	p.Payload()[0] = byte(n)
+	f.pkts <- p.Bytes()
+}
+
+func (f *fakeFilter) Read(buf []byte) (int, error) {
+	return copy(buf, <-f.pkts), nil
+}
+
+func (f *fakeFilter) readNonblock(buf []byte) (int, error) {
+	select {
+	case b := <-f.pkts:
+		return copy(buf, b), nil
+	default:
+		return 0, &os.PathError{Op: "read", Path: "fake", Err: syscall.EAGAIN}
+	}
+}
+
+func (f *fakeFilter) interrupt() {
+	f.pkts <- nil
+}
+
+func (f *fakeFilter) AddPid(pid int16) error {
+	if f.limit > 0 && f.pids == f.limit {
+		return syscall.EINVAL
+	}
+	f.pids++
+	return nil
+}
+
+func (f *fakeFilter) DelPid(pid int16) error {
+	f.pids--
+	return nil
+}
+
+func (f *fakeFilter) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestPidReaderFallback(t *testing.T) {
+	var filters []*fakeFilter
+	newFilter := func(pid int16) (tsFilter, error) {
+		f := &fakeFilter{pkts: make(chan []byte, 16), pids: 1}
+		if pid != FullTS {
+			f.limit = 1
+		}
+		filters = append(filters, f)
+		return f, nil
+	}
+	r, err := newPidReader(newFilter, []int16{0x100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Software() {
+		t.Fatal("software filtering of one PID")
+	}
+	old := filters[0]
+	old.send(0x100, 0, true)
+	old.send(0x100, 1, true)
+	old.send(0x100, 1, false)
+	if err := r.AddPid(0x101); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Software() || len(filters) != 2 {
+		t.Fatal("no fallback to full TS filter")
+	}
+	full := filters[1]
+	full.send(0x100, 1, true)  // duplicate
+	full.send(0x100, 1, false) // duplicate without payload
+	full.send(0x102, 0, true)  // not in set
+	full.send(0x100, 2, true)
+	full.send(0x100, 2, false)
+	full.send(0x101, 5, true)
+	expected := []struct {
+		pid     int16
+		cc      int8
+		payload bool
+	}{
+		{0x100, 0, true},
+		{0x100, 1, true},
+		{0x100, 1, false},
+		{0x100, 2, true},
+		{0x100, 2, false},
+		{0x101, 5, true},
+	}
+	pkt := new(ts.ArrayPkt)
+	for i, e := range expected {
+		if err := r.ReadPkt(pkt); err != nil {
+			t.Fatal(err)
+		}
+		if pkt.Pid() != e.pid || pkt.CC() != e.cc ||
+			pkt.ContainsPayload() != e.payload {
+			t.Fatalf("%d: pid=%#x cc=%d payload=%t, expected %+v", i,
+				pkt.Pid(), pkt.CC(), pkt.ContainsPayload(), e)
+		}
+		if i == 2 && old.closed {
+			t.Fatal("old filter closed before it was drained")
+		}
+	}
+	if !old.closed {
+		t.Fatal("old filter not closed")
+	}
+	r.Close()
+	if !full.closed {
+		t.Fatal("full TS filter not closed")
+	}
+}
+
+func TestFilterReadNonblock(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pw.Close()
+	f := &Filter{data: pr, r: newPollReader(pr)}
+	defer f.Close()
+	if _, err := pw.Write(make([]byte, ts.PktLen)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 2*ts.PktLen)
+	if n, err := f.readNonblock(buf); n != ts.PktLen || err != nil {
+		t.Fatal(n, err)
+	}
+	_, err = f.readNonblock(buf)
+	if e, ok := err.(*os.PathError); !ok || e.Err != syscall.EAGAIN {
+		t.Fatal("expected EAGAIN, got", err)
+	}
+	f.interrupt()
+	if n, err := f.Read(buf); n != 0 || err != nil {
+		t.Fatal("interrupted Read:", n, err)
+	}
+}
+
+func TestPidReaderLongOverlap(t *testing.T) {
+	var filters []*fakeFilter
+	newFilter := func(pid int16) (tsFilter, error) {
+		f := &fakeFilter{pkts: make(chan []byte, 256), pids: 1}
+		if pid != FullTS {
+			f.limit = 2
+		}
+		filters = append(filters, f)
+		return f, nil
+	}
+	r, err := newPidReader(newFilter, []int16{0x100, 0x102})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := filters[0]
+	for i := 0; i < 40; i++ {
+		old.sendSeq(0x100, i)
+	}
+	old.sendSeq(0x102, 0)
+	old.sendSeq(0x102, 1)
+	if err := r.AddPid(0x101); err != nil {
+		t.Fatal(err)
+	}
+	full := filters[1]
+	// Packets 10-39 of PID 0x100 were received by both filters. Packets of
+	// PID 0x102 weren't.
+	for i := 10; i < 50; i++ {
+		full.sendSeq(0x100, i)
+		if i%10 == 0 {
+			full.sendSeq(0x101, i/10)
+			full.sendSeq(0x102, 1+i/10)
+		}
+	}
+	var pkts [0x103][]int
+	pkt := new(ts.ArrayPkt)
+	for i := 0; i < 50+4+6; i++ {
+		if err := r.ReadPkt(pkt); err != nil {
+			t.Fatal(err)
+		}
+		pid := pkt.Pid()
+		pkts[pid] = append(pkts[pid], int(pkt.Payload()[0]))
+	}
+	check := func(pid int16, first, last int) {
+		ns := pkts[pid]
+		if len(ns) != last-first+1 {
+			t.Fatalf("PID %#x: %v", pid, ns)
+		}
+		for i, n := range ns {
+			if n != first+i {
+				t.Fatalf("PID %#x: %v", pid, ns)
+			}
+		}
+	}
+	check(0x100, 0, 49)
+	check(0x101, 1, 4)
+	check(0x102, 0, 5)
+}
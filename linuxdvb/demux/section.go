@@ -0,0 +1,125 @@
+package demux
+
+import (
+	"context"
+	"os"
+	"syscall"
+
+	"github.com/ziutek/dvb"
+	"github.com/ziutek/dvb/ts/psi"
+)
+
+// Pattern bytes correspond to section bytes with section_length field skipped:
+// byte 0 is table_id, bytes 1-2 are table_id_extension, byte 3 contains
+// version_number and current_next_indicator, byte 4 is section_number, byte 5
+// is last_section_number. If some bit is set in Mask the corresponding bit in
+// section must be equal to the bit in Bits. If it is set in Mask and in Mode
+// it is a "not equal" bit: at least one of "not equal" bits in section must
+// differ from the bit in Bits.
+
+func (p *Pattern) set(i int, bits, mask byte, neq bool) {
+	p.Bits[i] = p.Bits[i]&^mask | bits&mask
+	p.Mask[i] |= mask
+	if neq {
+		p.Mode[i] |= mask
+	} else {
+		p.Mode[i] &^= mask
+	}
+}
+
+// Reset clears pattern, so it matches any section.
+func (p *Pattern) Reset() {
+	*p = Pattern{}
+}
+
+// SetTableId sets pattern to match sections with table_id == id.
+func (p *Pattern) SetTableId(id byte) {
+	p.set(0, id, 0xff, false)
+}
+
+// SetTableIdExt sets pattern to match sections with table_id_extension == ext.
+func (p *Pattern) SetTableIdExt(ext uint16) {
+	p.set(1, byte(ext>>8), 0xff, false)
+	p.set(2, byte(ext), 0xff, false)
+}
+
+// SetVersion sets pattern to match sections with version_number == v.
+func (p *Pattern) SetVersion(v int8) {
+	p.set(3, byte(v<<1), 0x3e, false)
+}
+
+// SetVersionNot sets pattern to match sections with version_number != v. Use
+// it to wait for new version of table. There can be only one "not equal"
+// condition in pattern (Linux demux checks that any of "not equal" bits
+// differs).
+func (p *Pattern) SetVersionNot(v int8) {
+	p.set(3, byte(v<<1), 0x3e, true)
+}
+
+// SetCurrent sets pattern to match sections with current_next_indicator == c.
+func (p *Pattern) SetCurrent(c bool) {
+	var b byte
+	if c {
+		b = 1
+	}
+	p.set(3, b, 0x01, false)
+}
+
+// SetNumber sets pattern to match sections with section_number == n.
+func (p *Pattern) SetNumber(n byte) {
+	p.set(4, n, 0xff, false)
+}
+
+// SectionFilterParam returns parameters of section filter that uses p to
+// filter sections from pid. Returned parameters set CheckCRC and
+// ImmediateStart flags.
+func (p Pattern) SectionFilterParam(pid int16) *SectionFilterParam {
+	return &SectionFilterParam{
+		Pid:     pid,
+		Pattern: p,
+		Flags:   CheckCRC | ImmediateStart,
+	}
+}
+
+// NewSectionReader returns section filter configured to filter sections that
+// match p from pid. Returned filter is started.
+func (d Device) NewSectionReader(pid int16, p Pattern) (SectionFilter, error) {
+	return d.NewSectionFilter(p.SectionFilterParam(pid))
+}
+
+// ReadSection reads one section into s. len(s) should be equal to
+// psi.SectionMaxLen or psi.ISOSectionMaxLen. ReadSection checks section length
+// and CRC (if section has generic syntax). It returns error of
+// dvb.TemporaryError type if read section is bad or some sections were lost.
+// SectionFilter implements psi.SectionReader interface.
+func (f SectionFilter) ReadSection(s psi.Section) error {
+	n, err := f.Read(s)
+	return checkSection(s, n, err)
+}
+
+// ReadSectionContext works like ReadSection but returns ctx.Err() if ctx is
+// done before any section is available.
+func (f SectionFilter) ReadSectionContext(ctx context.Context, s psi.Section) error {
+	n, err := f.ReadContext(ctx, s)
+	return checkSection(s, n, err)
+}
+
+func checkSection(s psi.Section, n int, err error) error {
+	if err != nil {
+		if e, ok := err.(*os.PathError); ok && e.Err == syscall.EOVERFLOW {
+			return dvb.ErrOverflow
+		}
+		return err
+	}
+	if n < 3 {
+		return psi.ErrSectionData
+	}
+	l := s.Len()
+	if l == -1 || l != n {
+		return psi.ErrSectionLength
+	}
+	if s.GenericSyntax() && !s.CheckCRC() {
+		return psi.ErrSectionCRC
+	}
+	return nil
+}
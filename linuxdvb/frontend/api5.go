@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"github.com/ziutek/dvb"
@@ -86,6 +87,8 @@ const (
 	dtvDVBT2PLPId                  cmd = 43
 	dtvEnumDelSys                  cmd = 44
 
+	dtvStreamId = dtvISDBSTSId // Replaces ISDB-S TS ID and DVB-T2 PLP ID.
+
 	dtvStatSignalStrength    cmd = 62
 	dtvStatCNR               cmd = 63
 	dtvStatPreErrorBitCount  cmd = 64
@@ -94,6 +97,8 @@ const (
 	dtvStatPostTotalBitCount cmd = 67
 	dtvStatErrorBlockCount   cmd = 68
 	dtvStatTotalBlockCount   cmd = 69
+
+	dtvScramblingSequenceIndex cmd = 70
 )
 
 type property struct {
@@ -110,6 +115,16 @@ type property struct {
 	result int32
 }
 
+// buffer returns content of the buffer part of p.
+func (p *property) buffer() []byte {
+	buf := (*[32]byte)(unsafe.Pointer(&p.data))
+	n := p.bufLen
+	if n > uint32(len(buf)) {
+		n = uint32(len(buf))
+	}
+	return buf[:n]
+}
+
 type properties struct {
 	num   uint32
 	props *property
@@ -431,6 +446,162 @@ func (d Device) SetHierarchy(h dvb.Hierarchy) error {
 
 }
 
+// NoStreamId disables stream filtering in multistream signals.
+const NoStreamId = ^uint32(0)
+
+// StreamId returns id of selected stream: DVB-T2 PLP, DVB-S2 ISI or ISDB-S
+// TS ID.
+func (d Device) StreamId() (uint32, error) {
+	id, e := d.get(dtvStreamId)
+	if e != 0 {
+		return 0, Error{"get", "stream id", e}
+	}
+	return id, nil
+}
+
+// SetStreamId selects stream in multistream signal: DVB-T2 PLP, DVB-S2 ISI or
+// ISDB-S TS ID. Use NoStreamId to disable stream filtering.
+func (d Device) SetStreamId(id uint32) error {
+	e := d.set(dtvStreamId, id)
+	if e != 0 {
+		return Error{"set", "stream id", e}
+	}
+	return nil
+}
+
+// PLSMode describes how DVB-S2 physical layer scrambling code is specified.
+type PLSMode uint32
+
+const (
+	PLSGold PLSMode = iota
+	PLSRoot
+)
+
+var plsModeNames = []string{
+	"gold",
+	"root",
+}
+
+func (m PLSMode) String() string {
+	if m > PLSRoot {
+		return "unknown"
+	}
+	return plsModeNames[m]
+}
+
+const plsMaxCode = 1<<18 - 1
+
+// PLSGoldCode converts DVB-S2 physical layer scrambling code to gold code
+// (scrambling sequence index). It returns false if code can't be converted.
+func PLSGoldCode(mode PLSMode, code uint32) (uint32, bool) {
+	switch mode {
+	case PLSGold:
+		return code, code < plsMaxCode
+	case PLSRoot:
+		x := uint32(1)
+		for g := uint32(0); g < plsMaxCode; g++ {
+			if x == code {
+				return g, true
+			}
+			x = ((x^(x>>7))&1)<<17 | x>>1
+		}
+	}
+	return 0, false
+}
+
+// ScramblingSequenceIndex returns DVB-S2 physical layer scrambling sequence
+// index (gold code).
+func (d Device) ScramblingSequenceIndex() (uint32, error) {
+	g, e := d.get(dtvScramblingSequenceIndex)
+	if e != 0 {
+		return 0, Error{"get", "scrambling sequence index", e}
+	}
+	return g, nil
+}
+
+// SetPLS sets DVB-S2 physical layer scrambling code.
+func (d Device) SetPLS(mode PLSMode, code uint32) error {
+	g, ok := PLSGoldCode(mode, code)
+	if !ok {
+		return Error{"set", "scrambling sequence index", ErrBadValue}
+	}
+	e := d.set(dtvScramblingSequenceIndex, g)
+	if e != 0 {
+		return Error{"set", "scrambling sequence index", e}
+	}
+	return nil
+}
+
+// EnumDeliverySystems returns list of delivery systems supported by frontend.
+func (d Device) EnumDeliverySystems() ([]dvb.DeliverySystem, error) {
+	p := property{cmd: dtvEnumDelSys}
+	ps := properties{1, &p}
+	_, _, e := syscall.Syscall(
+		syscall.SYS_IOCTL,
+		d.Fd(),
+		_FE_GET_PROPERTY,
+		uintptr(unsafe.Pointer(&ps)),
+	)
+	if e != 0 {
+		return nil, Error{"get", "delivery systems", e}
+	}
+	buf := p.buffer()
+	dss := make([]dvb.DeliverySystem, len(buf))
+	for i, b := range buf {
+		dss[i] = dvb.DeliverySystem(b)
+	}
+	return dss, nil
+}
+
+// ProbePLPs is a heuristic probe for PLPs of DVB-T2 signal described by p.
+// Linux DVB API doesn't expose L1-post signalling so ProbePLPs tunes to every
+// PLP id < n in turn and returns ids for which frontend obtains lock in
+// timeout and reports the requested PLP id as selected. Many demodulators lock
+// regardless of PLP id and don't report the actually selected PLP. For them
+// ProbePLPs returns all probed ids so its result should be verified by reading
+// the transport stream (eg. PAT). ProbePLPs can take up to n*timeout. Before
+// return ProbePLPs restores p.StreamId and tunes frontend using p.
+func (d Device) ProbePLPs(p *DVBT2Params, n int, timeout time.Duration) ([]uint32, error) {
+	var plps []uint32
+	q := *p
+	for id := 0; id < n; id++ {
+		q.StreamId = uint32(id)
+		if err := d.Apply(&q); err != nil {
+			return nil, err
+		}
+		lock, err := waitLock(API3{d}, timeout)
+		if err != nil {
+			return nil, err
+		}
+		if !lock {
+			continue
+		}
+		if sid, err := d.StreamId(); err == nil && sid != q.StreamId {
+			continue // Driver reports other PLP.
+		}
+		plps = append(plps, q.StreamId)
+	}
+	return plps, d.Apply(p)
+}
+
+// waitLock polls frontend status until it reports lock or timeout elapses.
+func waitLock(fe API3, timeout time.Duration) (bool, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		s, err := fe.Status()
+		if err != nil {
+			return false, err
+		}
+		if s&HasLock != 0 {
+			return true, nil
+		}
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
 type Scale byte
 
 const (
@@ -0,0 +1,375 @@
+package frontend
+
+import (
+	"github.com/ziutek/dvb"
+)
+
+// ISDBTLayers is a bit mask of ISDB-T hierarchical layers.
+type ISDBTLayers uint32
+
+const (
+	LayerA ISDBTLayers = 1 << iota
+	LayerB
+	LayerC
+
+	AllLayers = LayerA | LayerB | LayerC
+)
+
+func (l ISDBTLayers) String() string {
+	s := []byte("---")
+	for i := uint(0); i < 3; i++ {
+		if l&(1<<i) != 0 {
+			s[i] = 'A' + byte(i)
+		}
+	}
+	return string(s)
+}
+
+// ISDBTAuto can be used as value of int fields of ISDB-T parameters that can
+// be detected by frontend.
+const ISDBTAuto = -1
+
+// ISDBTLayer contains parameters of one ISDB-T hierarchical layer.
+type ISDBTLayer struct {
+	FEC              dvb.CodeRate
+	Modulation       dvb.Modulation // QPSK, DQPSK, QAM16, QAM64 or QAMAuto
+	SegmentCount     int            // 0-13 or ISDBTAuto
+	TimeInterleaving int            // 0, 1, 2, 4 or ISDBTAuto
+}
+
+func (l *ISDBTLayer) validate(c Caps) error {
+	var segs, ti error
+	if l.SegmentCount < ISDBTAuto || l.SegmentCount > 13 {
+		segs = badValue("layer segment count")
+	}
+	switch l.TimeInterleaving {
+	case ISDBTAuto, 0, 1, 2, 4:
+	default:
+		ti = badValue("layer time interleaving")
+	}
+	var modulation error
+	if l.Modulation != dvb.DQPSK {
+		modulation = checkModulation(c, l.Modulation,
+			dvb.QPSK, dvb.QAM16, dvb.QAM64, dvb.QAMAuto)
+	}
+	return firstErr(
+		checkFEC(c, "layer fec", l.FEC,
+			dvb.FEC12, dvb.FEC23, dvb.FEC34, dvb.FEC56, dvb.FEC78, dvb.FECAuto),
+		modulation,
+		segs,
+		ti,
+	)
+}
+
+// isdbtLayerCmds contains FEC, modulation, segment count and time interleaving
+// commands for A, B, C layers.
+var isdbtLayerCmds = [3][4]cmd{
+	{
+		dtvISDBTLayeraFEC, dtvISDBTLayeraModulation,
+		dtvISDBTLayeraSegmentCount, dtvISDBTLayeraTimeInterleaving,
+	},
+	{
+		dtvISDBTLayerbFEC, dtvISDBTLayerbModulation,
+		dtvISDBTLayerbSegmentCount, dtvISDBTLayerbTimeInterleaving,
+	},
+	{
+		dtvISDBTLayercFEC, dtvISDBTLayercModulation,
+		dtvISDBTLayercSegmentCount, dtvISDBTLayercTimeInterleaving,
+	},
+}
+
+func (l *ISDBTLayer) appendProps(ps []property, n int) []property {
+	c := &isdbtLayerCmds[n]
+	return append(
+		ps,
+		prop(c[0], uint32(l.FEC)),
+		prop(c[1], uint32(l.Modulation)),
+		prop(c[2], uint32(l.SegmentCount)),
+		prop(c[3], uint32(l.TimeInterleaving)),
+	)
+}
+
+func (l *ISDBTLayer) loadProps(ps []property, n int) {
+	c := &isdbtLayerCmds[n]
+	l.FEC = dvb.CodeRate(propValue(ps, c[0]))
+	l.Modulation = dvb.Modulation(propValue(ps, c[1]))
+	l.SegmentCount = int(int32(propValue(ps, c[2])))
+	l.TimeInterleaving = int(int32(propValue(ps, c[3])))
+}
+
+// ISDBTLayerParams contains ISDB-T (ARIB STD-B31, ABNT NBR 15601)
+// transmission parameters usually signaled in TMCC.
+type ISDBTLayerParams struct {
+	PartialReception  int  // 0, 1 or ISDBTAuto
+	SoundBroadcasting bool // ISDB-Tsb
+	SBSubchannelId    int  // 0-41 (ISDB-Tsb only)
+	SBSegmentIdx      int  // 0-12 (ISDB-Tsb only)
+	SBSegmentCount    int  // 1-13 (ISDB-Tsb only)
+	LayerEnabled      ISDBTLayers
+	Layers            [3]ISDBTLayer // A, B, C
+}
+
+// Validate checks p against capabilities of frontend (see Info.Caps).
+func (p *ISDBTLayerParams) Validate(c Caps) error {
+	switch p.PartialReception {
+	case ISDBTAuto, 0, 1:
+	default:
+		return badValue("partial reception")
+	}
+	if p.SoundBroadcasting {
+		if uint(p.SBSubchannelId) > 41 {
+			return badValue("subchannel id")
+		}
+		if uint(p.SBSegmentIdx) > 12 {
+			return badValue("segment index")
+		}
+		if p.SBSegmentCount < 1 || p.SBSegmentCount > 13 {
+			return badValue("segment count")
+		}
+	}
+	if p.LayerEnabled == 0 || p.LayerEnabled&^AllLayers != 0 {
+		return badValue("enabled layers")
+	}
+	segs := 0
+	for i := range p.Layers {
+		if p.LayerEnabled&(1<<uint(i)) == 0 {
+			continue
+		}
+		l := &p.Layers[i]
+		if err := l.validate(c); err != nil {
+			return err
+		}
+		if l.SegmentCount > 0 {
+			segs += l.SegmentCount
+		}
+	}
+	if segs > 13 {
+		return badValue("layer segment count")
+	}
+	return nil
+}
+
+func b2u(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (p *ISDBTLayerParams) appendProps(ps []property) []property {
+	ps = append(
+		ps,
+		prop(dtvISDBTPartialReception, uint32(p.PartialReception)),
+		prop(dtvISDBTSoundBroadcasting, b2u(p.SoundBroadcasting)),
+		prop(dtvISDBTSBSubchannelId, uint32(p.SBSubchannelId)),
+		prop(dtvISDBTSBSegmentIdx, uint32(p.SBSegmentIdx)),
+		prop(dtvISDBTSBSegmentCount, uint32(p.SBSegmentCount)),
+		prop(dtvISDBTLayerEnabled, uint32(p.LayerEnabled)),
+	)
+	for i := range p.Layers {
+		ps = p.Layers[i].appendProps(ps, i)
+	}
+	return ps
+}
+
+func (p *ISDBTLayerParams) loadProps(ps []property) {
+	p.PartialReception = int(int32(propValue(ps, dtvISDBTPartialReception)))
+	p.SoundBroadcasting = propValue(ps, dtvISDBTSoundBroadcasting) != 0
+	p.SBSubchannelId = int(propValue(ps, dtvISDBTSBSubchannelId))
+	p.SBSegmentIdx = int(propValue(ps, dtvISDBTSBSegmentIdx))
+	p.SBSegmentCount = int(propValue(ps, dtvISDBTSBSegmentCount))
+	p.LayerEnabled = ISDBTLayers(propValue(ps, dtvISDBTLayerEnabled))
+	for i := range p.Layers {
+		p.Layers[i].loadProps(ps, i)
+	}
+}
+
+// ISDBTLayerParams reads ISDB-T transmission parameters from frontend.
+func (d Device) ISDBTLayerParams() (*ISDBTLayerParams, error) {
+	p := new(ISDBTLayerParams)
+	ps := p.appendProps(nil)
+	if e := d.ioctlProps(_FE_GET_PROPERTY, ps); e != 0 {
+		return nil, Error{"get", "ISDB-T layer params", e}
+	}
+	p.loadProps(ps)
+	return p, nil
+}
+
+// SetISDBTLayerParams sets ISDB-T transmission parameters. It doesn't start
+// tuning.
+func (d Device) SetISDBTLayerParams(p *ISDBTLayerParams) error {
+	if e := d.ioctlProps(_FE_SET_PROPERTY, p.appendProps(nil)); e != 0 {
+		return Error{"set", "ISDB-T layer params", e}
+	}
+	return nil
+}
+
+// ISDBTLayer returns parameters of n-th (0: A, 1: B, 2: C) ISDB-T layer.
+func (d Device) ISDBTLayer(n int) (l ISDBTLayer, err error) {
+	ps := l.appendProps(nil, n)
+	if e := d.ioctlProps(_FE_GET_PROPERTY, ps); e != 0 {
+		err = Error{"get", "ISDB-T layer", e}
+		return
+	}
+	l.loadProps(ps, n)
+	return
+}
+
+// SetISDBTLayer sets parameters of n-th (0: A, 1: B, 2: C) ISDB-T layer.
+func (d Device) SetISDBTLayer(n int, l ISDBTLayer) error {
+	if e := d.ioctlProps(_FE_SET_PROPERTY, l.appendProps(nil, n)); e != 0 {
+		return Error{"set", "ISDB-T layer", e}
+	}
+	return nil
+}
+
+func (d Device) ISDBTPartialReception() (int, error) {
+	v, e := d.get(dtvISDBTPartialReception)
+	if e != 0 {
+		return 0, Error{"get", "partial reception", e}
+	}
+	return int(int32(v)), nil
+}
+
+func (d Device) SetISDBTPartialReception(v int) error {
+	e := d.set(dtvISDBTPartialReception, uint32(v))
+	if e != 0 {
+		return Error{"set", "partial reception", e}
+	}
+	return nil
+}
+
+func (d Device) ISDBTSoundBroadcasting() (bool, error) {
+	v, e := d.get(dtvISDBTSoundBroadcasting)
+	if e != 0 {
+		return false, Error{"get", "sound broadcasting", e}
+	}
+	return v != 0, nil
+}
+
+func (d Device) SetISDBTSoundBroadcasting(b bool) error {
+	e := d.set(dtvISDBTSoundBroadcasting, b2u(b))
+	if e != 0 {
+		return Error{"set", "sound broadcasting", e}
+	}
+	return nil
+}
+
+func (d Device) ISDBTSBSubchannelId() (int, error) {
+	v, e := d.get(dtvISDBTSBSubchannelId)
+	if e != 0 {
+		return 0, Error{"get", "subchannel id", e}
+	}
+	return int(v), nil
+}
+
+func (d Device) SetISDBTSBSubchannelId(id int) error {
+	e := d.set(dtvISDBTSBSubchannelId, uint32(id))
+	if e != 0 {
+		return Error{"set", "subchannel id", e}
+	}
+	return nil
+}
+
+func (d Device) ISDBTSBSegmentIdx() (int, error) {
+	v, e := d.get(dtvISDBTSBSegmentIdx)
+	if e != 0 {
+		return 0, Error{"get", "segment index", e}
+	}
+	return int(v), nil
+}
+
+func (d Device) SetISDBTSBSegmentIdx(idx int) error {
+	e := d.set(dtvISDBTSBSegmentIdx, uint32(idx))
+	if e != 0 {
+		return Error{"set", "segment index", e}
+	}
+	return nil
+}
+
+func (d Device) ISDBTSBSegmentCount() (int, error) {
+	v, e := d.get(dtvISDBTSBSegmentCount)
+	if e != 0 {
+		return 0, Error{"get", "segment count", e}
+	}
+	return int(v), nil
+}
+
+func (d Device) SetISDBTSBSegmentCount(n int) error {
+	e := d.set(dtvISDBTSBSegmentCount, uint32(n))
+	if e != 0 {
+		return Error{"set", "segment count", e}
+	}
+	return nil
+}
+
+func (d Device) ISDBTLayerEnabled() (ISDBTLayers, error) {
+	v, e := d.get(dtvISDBTLayerEnabled)
+	if e != 0 {
+		return 0, Error{"get", "layer enabled", e}
+	}
+	return ISDBTLayers(v), nil
+}
+
+func (d Device) SetISDBTLayerEnabled(l ISDBTLayers) error {
+	e := d.set(dtvISDBTLayerEnabled, uint32(l))
+	if e != 0 {
+		return Error{"set", "layer enabled", e}
+	}
+	return nil
+}
+
+// ISDBSTSId returns id of selected ISDB-S transport stream.
+func (d Device) ISDBSTSId() (uint16, error) {
+	id, e := d.get(dtvISDBSTSId)
+	if e != 0 {
+		return 0, Error{"get", "ISDB-S TS id", e}
+	}
+	return uint16(id), nil
+}
+
+// SetISDBSTSId selects ISDB-S transport stream.
+func (d Device) SetISDBSTSId(id uint16) error {
+	e := d.set(dtvISDBSTSId, uint32(id))
+	if e != 0 {
+		return Error{"set", "ISDB-S TS id", e}
+	}
+	return nil
+}
+
+// ISDBSParams contains ISDB-S tuning parameters. Use SecParam to obtain Freq,
+// Tone and Voltage for given transponder frequency and polarization.
+type ISDBSParams struct {
+	Freq    uint32 // intermediate frequency in kHz
+	TSId    uint16 // transport stream id
+	Tone    Tone
+	Voltage Voltage
+}
+
+func (p *ISDBSParams) DeliverySystem() dvb.DeliverySystem {
+	return dvb.SysISDBS
+}
+
+func (p *ISDBSParams) Validate(c Caps) error {
+	return firstErr(
+		checkFreq(p.Freq),
+		checkSEC(p.Tone, p.Voltage),
+	)
+}
+
+func (p *ISDBSParams) appendProps(ps []property) []property {
+	return append(
+		ps,
+		prop(dtvFrequency, p.Freq),
+		prop(dtvISDBSTSId, uint32(p.TSId)),
+		prop(dtvTone, uint32(p.Tone)),
+		prop(dtvVoltage, uint32(p.Voltage)),
+	)
+}
+
+func (p *ISDBSParams) loadProps(ps []property) {
+	p.Freq = propValue(ps, dtvFrequency)
+	p.TSId = uint16(propValue(ps, dtvISDBSTSId))
+	p.Tone = Tone(propValue(ps, dtvTone))
+	p.Voltage = Voltage(propValue(ps, dtvVoltage))
+}
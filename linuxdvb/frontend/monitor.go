@@ -0,0 +1,142 @@
+package frontend
+
+import (
+	"context"
+	"time"
+
+	"github.com/ziutek/dvb"
+)
+
+// MonitorEvent describes frontend state observed by Monitor.
+type MonitorEvent struct {
+	Time    time.Time
+	Status  Status // current frontend status
+	Changed Status // status bits changed since previous status event
+	Stat    *Stat  // non-nil for periodic statistics snapshots
+	Retuned bool   // frontend was retuned after lock loss
+	Err     error  // non-nil if monitoring failed (it is always last event)
+}
+
+// Monitor watches frontend status in separate goroutine and reports status
+// transitions and periodic statistics snapshots.
+type Monitor struct {
+	Dev Device
+
+	// PollInterval is maximum time between status checks. Status changes
+	// reported by frontend as events are detected immediately. Default 100ms.
+	PollInterval time.Duration
+
+	// StatInterval is period of Stat snapshots. Zero disables statistics.
+	StatInterval time.Duration
+
+	// RetuneAfter enables automatic retuning if frontend has no lock for
+	// RetuneAfter. Zero disables retuning.
+	RetuneAfter time.Duration
+
+	// Params are used to retune frontend. If nil Monitor uses Device.Tune
+	// to restart tuning with current parameters.
+	Params Params
+}
+
+// Start starts monitoring. It returns channel of events that is closed after
+// ctx is canceled or after an error event.
+func (m *Monitor) Start(ctx context.Context) <-chan MonitorEvent {
+	c := make(chan MonitorEvent, 4)
+	go m.run(ctx, c)
+	return c
+}
+
+func send(ctx context.Context, c chan<- MonitorEvent, ev MonitorEvent) bool {
+	select {
+	case c <- ev:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// monitorDevice is the part of frontend API used by Monitor.
+type monitorDevice interface {
+	WaitEvent(ev *Event, deadline time.Time) (bool, error)
+	Status() (Status, error)
+	Stat() (*Stat, error)
+	Apply(p Params) error
+	Tune() error
+}
+
+func (m *Monitor) retune(fe monitorDevice) error {
+	if m.Params != nil {
+		return fe.Apply(m.Params)
+	}
+	return fe.Tune()
+}
+
+func (m *Monitor) run(ctx context.Context, c chan<- MonitorEvent) {
+	m.watch(ctx, c, API3{m.Dev})
+}
+
+func (m *Monitor) watch(ctx context.Context, c chan<- MonitorEvent, fe monitorDevice) {
+	defer close(c)
+	poll := m.PollInterval
+	if poll <= 0 {
+		poll = 100 * time.Millisecond
+	}
+	now := time.Now()
+	lostAt := now // Counts also time to first lock.
+	nextStat := now.Add(m.StatInterval)
+	status := Status(0)
+	first := true
+	var ev Event
+	for ctx.Err() == nil {
+		timeout, werr := fe.WaitEvent(&ev, time.Now().Add(poll))
+		if werr != nil && werr != dvb.ErrOverflow {
+			send(ctx, c, MonitorEvent{Time: time.Now(), Err: werr})
+			return
+		}
+		s, err := fe.Status()
+		now = time.Now()
+		if err != nil {
+			send(ctx, c, MonitorEvent{Time: now, Err: err})
+			return
+		}
+		if !timeout && werr == nil {
+			// FE_READ_STATUS never reports Timedout and Reinit.
+			s |= ev.Status() & (Timedout | Reinit)
+		}
+		if first || s != status {
+			me := MonitorEvent{Time: now, Status: s, Changed: s ^ status}
+			if !send(ctx, c, me) {
+				return
+			}
+			status, first = s, false
+		}
+		if s&HasLock != 0 {
+			lostAt = time.Time{}
+		} else if lostAt.IsZero() {
+			lostAt = now
+		}
+		if m.RetuneAfter > 0 && !lostAt.IsZero() &&
+			now.Sub(lostAt) >= m.RetuneAfter {
+			if err := m.retune(fe); err != nil {
+				send(ctx, c, MonitorEvent{Time: now, Status: s, Err: err})
+				return
+			}
+			lostAt = now
+			me := MonitorEvent{Time: now, Status: s, Retuned: true}
+			if !send(ctx, c, me) {
+				return
+			}
+		}
+		if m.StatInterval > 0 && !now.Before(nextStat) {
+			st, err := fe.Stat()
+			if err != nil {
+				send(ctx, c, MonitorEvent{Time: now, Status: s, Err: err})
+				return
+			}
+			nextStat = now.Add(m.StatInterval)
+			if !send(ctx, c, MonitorEvent{Time: now, Status: s, Stat: st}) {
+				return
+			}
+		}
+	}
+}
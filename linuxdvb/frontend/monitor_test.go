@@ -0,0 +1,67 @@
+package frontend
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeFrontend delivers events and reports status like real frontend: its
+// FE_READ_STATUS never contains Timedout.
+type fakeFrontend struct {
+	events  []Status
+	status  Status
+	retunes int
+}
+
+func (f *fakeFrontend) WaitEvent(ev *Event, deadline time.Time) (bool, error) {
+	if len(f.events) == 0 {
+		time.Sleep(time.Millisecond)
+		return true, nil
+	}
+	ev.status, f.events = f.events[0], f.events[1:]
+	f.status = ev.status &^ (Timedout | Reinit)
+	return false, nil
+}
+
+func (f *fakeFrontend) Status() (Status, error) { return f.status, nil }
+func (f *fakeFrontend) Stat() (*Stat, error)    { return new(Stat), nil }
+func (f *fakeFrontend) Apply(p Params) error    { f.retunes++; return nil }
+func (f *fakeFrontend) Tune() error             { f.retunes++; return nil }
+
+func TestMonitorTimedout(t *testing.T) {
+	fe := &fakeFrontend{
+		events: []Status{
+			HasSignal | HasCarrier,
+			HasSignal | HasCarrier | Timedout,
+			HasSignal | HasCarrier | HasViterbi | HasSync | HasLock,
+		},
+	}
+	m := &Monitor{PollInterval: time.Millisecond}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	c := make(chan MonitorEvent, 4)
+	go m.watch(ctx, c, fe)
+	var statuses []Status
+	for ev := range c {
+		if ev.Err != nil {
+			t.Fatal(ev.Err)
+		}
+		statuses = append(statuses, ev.Status)
+		if ev.Status&HasLock != 0 {
+			cancel()
+		}
+	}
+	var timedout bool
+	for _, s := range statuses {
+		if s&Timedout != 0 {
+			timedout = true
+		}
+	}
+	if !timedout {
+		t.Errorf("no Timedout status in %v", statuses)
+	}
+	if last := statuses[len(statuses)-1]; last&HasLock == 0 {
+		t.Errorf("last status: %v", last)
+	}
+}
@@ -0,0 +1,255 @@
+package frontend
+
+import (
+	"github.com/ziutek/dvb"
+)
+
+// RelativeParam returns value v obtained from legacy API3 methods
+// (SignalStrength, SNR) as Param with relative scale.
+func RelativeParam(v int16) Param {
+	return Param{scale: ScaleRelative, value: int64(uint16(v))}
+}
+
+// first returns first param in ps that has scale s.
+func first(ps []Param, s Scale) (Param, bool) {
+	for _, p := range ps {
+		if p.scale == s {
+			return p, true
+		}
+	}
+	return Param{}, false
+}
+
+func ratio(errs, tot []Param) (float64, bool) {
+	e, ok := first(errs, ScaleCounter)
+	if !ok {
+		return 0, false
+	}
+	t, ok := first(tot, ScaleCounter)
+	if !ok || t.value <= 0 {
+		return 0, false
+	}
+	return float64(e.value) / float64(t.value), true
+}
+
+func subParams(cur, prev []Param) []Param {
+	ret := make([]Param, len(cur))
+	copy(ret, cur)
+	for i := range ret {
+		if i < len(prev) && ret[i].scale == ScaleCounter &&
+			prev[i].scale == ScaleCounter {
+			ret[i].value -= prev[i].value
+		}
+	}
+	return ret
+}
+
+// Sub returns statistics with counters equal to differences between counters
+// in s and prev. Use it to obtain error rates for period between two Stat
+// calls. Signal and CNR are copied from s.
+func (s *Stat) Sub(prev *Stat) *Stat {
+	return &Stat{
+		Signal:     s.Signal,
+		CNR:        s.CNR,
+		PreErrBit:  subParams(s.PreErrBit, prev.PreErrBit),
+		PreTotBit:  subParams(s.PreTotBit, prev.PreTotBit),
+		PostErrBit: subParams(s.PostErrBit, prev.PostErrBit),
+		PostTotBit: subParams(s.PostTotBit, prev.PostTotBit),
+		ErrBlk:     subParams(s.ErrBlk, prev.ErrBlk),
+		TotBlk:     subParams(s.TotBlk, prev.TotBlk),
+	}
+}
+
+// PreBER returns bit error rate before inner FEC (pre-Viterbi, pre-LDPC).
+func (s *Stat) PreBER() (float64, bool) {
+	return ratio(s.PreErrBit, s.PreTotBit)
+}
+
+// PostBER returns bit error rate after inner FEC (post-Viterbi, post-LDPC).
+func (s *Stat) PostBER() (float64, bool) {
+	return ratio(s.PostErrBit, s.PostTotBit)
+}
+
+// PER returns block (packet) error rate after outer FEC.
+func (s *Stat) PER() (float64, bool) {
+	return ratio(s.ErrBlk, s.TotBlk)
+}
+
+// CNRDecibel returns carrier to noise ratio in dB if frontend reports it in
+// decibel scale.
+func (s *Stat) CNRDecibel() (float64, bool) {
+	p, ok := first(s.CNR, ScaleDecibel)
+	return p.Decibel(), ok
+}
+
+type modCR struct {
+	m  dvb.Modulation
+	cr dvb.CodeRate
+}
+
+// Required C/N [dB] for quasi error free reception.
+var (
+	// EN 300 744 Annex A, Gaussian channel.
+	cnrDVBT = map[modCR]float64{
+		{dvb.QPSK, dvb.FEC12}:  3.1,
+		{dvb.QPSK, dvb.FEC23}:  4.9,
+		{dvb.QPSK, dvb.FEC34}:  5.9,
+		{dvb.QPSK, dvb.FEC56}:  6.9,
+		{dvb.QPSK, dvb.FEC78}:  7.7,
+		{dvb.QAM16, dvb.FEC12}: 8.8,
+		{dvb.QAM16, dvb.FEC23}: 11.1,
+		{dvb.QAM16, dvb.FEC34}: 12.5,
+		{dvb.QAM16, dvb.FEC56}: 13.5,
+		{dvb.QAM16, dvb.FEC78}: 13.9,
+		{dvb.QAM64, dvb.FEC12}: 14.4,
+		{dvb.QAM64, dvb.FEC23}: 16.5,
+		{dvb.QAM64, dvb.FEC34}: 18.0,
+		{dvb.QAM64, dvb.FEC56}: 19.3,
+		{dvb.QAM64, dvb.FEC78}: 20.1,
+	}
+	// NorDig Unified, DVB-T2 Gaussian channel.
+	cnrDVBT2 = map[modCR]float64{
+		{dvb.QPSK, dvb.FEC12}:   3.5,
+		{dvb.QPSK, dvb.FEC35}:   4.7,
+		{dvb.QPSK, dvb.FEC23}:   5.6,
+		{dvb.QPSK, dvb.FEC34}:   6.6,
+		{dvb.QPSK, dvb.FEC45}:   7.2,
+		{dvb.QPSK, dvb.FEC56}:   7.7,
+		{dvb.QAM16, dvb.FEC12}:  8.7,
+		{dvb.QAM16, dvb.FEC35}:  10.1,
+		{dvb.QAM16, dvb.FEC23}:  11.4,
+		{dvb.QAM16, dvb.FEC34}:  12.5,
+		{dvb.QAM16, dvb.FEC45}:  13.3,
+		{dvb.QAM16, dvb.FEC56}:  13.8,
+		{dvb.QAM64, dvb.FEC12}:  13.0,
+		{dvb.QAM64, dvb.FEC35}:  14.8,
+		{dvb.QAM64, dvb.FEC23}:  16.2,
+		{dvb.QAM64, dvb.FEC34}:  17.7,
+		{dvb.QAM64, dvb.FEC45}:  18.7,
+		{dvb.QAM64, dvb.FEC56}:  19.4,
+		{dvb.QAM256, dvb.FEC12}: 17.0,
+		{dvb.QAM256, dvb.FEC35}: 19.4,
+		{dvb.QAM256, dvb.FEC23}: 20.8,
+		{dvb.QAM256, dvb.FEC34}: 22.9,
+		{dvb.QAM256, dvb.FEC45}: 24.3,
+		{dvb.QAM256, dvb.FEC56}: 25.1,
+	}
+	// EN 300 421 Eb/No converted to Es/No.
+	cnrDVBS = map[modCR]float64{
+		{dvb.QPSK, dvb.FEC12}: 4.2,
+		{dvb.QPSK, dvb.FEC23}: 5.9,
+		{dvb.QPSK, dvb.FEC34}: 6.9,
+		{dvb.QPSK, dvb.FEC56}: 7.9,
+		{dvb.QPSK, dvb.FEC78}: 8.5,
+	}
+	// EN 302 307 Table 13, ideal Es/No, normal FECFRAME.
+	cnrDVBS2 = map[modCR]float64{
+		{dvb.QPSK, dvb.FEC12}:    1.00,
+		{dvb.QPSK, dvb.FEC35}:    2.23,
+		{dvb.QPSK, dvb.FEC23}:    3.10,
+		{dvb.QPSK, dvb.FEC34}:    4.03,
+		{dvb.QPSK, dvb.FEC45}:    4.68,
+		{dvb.QPSK, dvb.FEC56}:    5.18,
+		{dvb.QPSK, dvb.FEC89}:    6.20,
+		{dvb.QPSK, dvb.FEC910}:   6.42,
+		{dvb.PSK8, dvb.FEC35}:    5.50,
+		{dvb.PSK8, dvb.FEC23}:    6.62,
+		{dvb.PSK8, dvb.FEC34}:    7.91,
+		{dvb.PSK8, dvb.FEC56}:    9.35,
+		{dvb.PSK8, dvb.FEC89}:    10.69,
+		{dvb.PSK8, dvb.FEC910}:   10.98,
+		{dvb.APSK16, dvb.FEC23}:  8.97,
+		{dvb.APSK16, dvb.FEC34}:  10.21,
+		{dvb.APSK16, dvb.FEC45}:  11.03,
+		{dvb.APSK16, dvb.FEC56}:  11.61,
+		{dvb.APSK16, dvb.FEC89}:  12.89,
+		{dvb.APSK16, dvb.FEC910}: 13.13,
+		{dvb.APSK32, dvb.FEC34}:  12.73,
+		{dvb.APSK32, dvb.FEC45}:  13.64,
+		{dvb.APSK32, dvb.FEC56}:  14.28,
+		{dvb.APSK32, dvb.FEC89}:  15.69,
+		{dvb.APSK32, dvb.FEC910}: 16.05,
+	}
+	// NorDig Unified, DVB-C (code rate is ignored).
+	cnrDVBC = map[dvb.Modulation]float64{
+		dvb.QAM16:  20,
+		dvb.QAM32:  23,
+		dvb.QAM64:  26,
+		dvb.QAM128: 29,
+		dvb.QAM256: 32,
+	}
+)
+
+// RequiredCNR returns C/N [dB] required for quasi error free reception of
+// signal that uses modulation m and code rate cr in delivery system ds.
+func RequiredCNR(ds dvb.DeliverySystem, m dvb.Modulation, cr dvb.CodeRate) (cnr float64, ok bool) {
+	switch ds {
+	case dvb.SysDVBT:
+		cnr, ok = cnrDVBT[modCR{m, cr}]
+	case dvb.SysDVBT2:
+		cnr, ok = cnrDVBT2[modCR{m, cr}]
+	case dvb.SysDVBS:
+		cnr, ok = cnrDVBS[modCR{m, cr}]
+	case dvb.SysDVBS2:
+		cnr, ok = cnrDVBS2[modCR{m, cr}]
+	case dvb.SysDVBCAnnexA, dvb.SysDVBCAnnexB, dvb.SysDVBCAnnexC:
+		cnr, ok = cnrDVBC[m]
+	case dvb.SysATSC:
+		cnr, ok = 15.2, m == dvb.VSB8
+	}
+	return
+}
+
+// CNRMargin returns difference between measured C/N and C/N required for
+// signal that uses modulation m and code rate cr in delivery system ds.
+func (s *Stat) CNRMargin(ds dvb.DeliverySystem, m dvb.Modulation, cr dvb.CodeRate) (float64, bool) {
+	cnr, ok := s.CNRDecibel()
+	if !ok {
+		return 0, false
+	}
+	req, ok := RequiredCNR(ds, m, cr)
+	if !ok {
+		return 0, false
+	}
+	return cnr - req, true
+}
+
+// Margin [dB] that corresponds to maximum quality.
+const fullQualityMargin = 10
+
+// Quality returns signal quality in range 0-100 that can be compared between
+// different drivers. It is calculated from C/N margin if frontend reports C/N
+// in dB and m, cr are known, otherwise from relative C/N or relative signal
+// strength. Quality is limited if there are errors after inner FEC or
+// uncorrected blocks. It returns -1 if s doesn't contain enough data. Use Sub
+// to obtain s that contains error counters for last period.
+func (s *Stat) Quality(ds dvb.DeliverySystem, m dvb.Modulation, cr dvb.CodeRate) int {
+	var q float64
+	if margin, ok := s.CNRMargin(ds, m, cr); ok {
+		q = margin * 100 / fullQualityMargin
+	} else if p, ok := first(s.CNR, ScaleRelative); ok {
+		q = p.Relative()
+	} else if p, ok := first(s.Signal, ScaleRelative); ok {
+		q = p.Relative()
+	} else {
+		return -1
+	}
+	if ber, ok := s.PostBER(); ok && ber > 2e-4 {
+		// Above quasi error free limit for outer Reed-Solomon code.
+		if q > 30 {
+			q = 30
+		}
+	}
+	if per, ok := s.PER(); ok && per > 0 {
+		if q > 10 {
+			q = 10
+		}
+	}
+	switch {
+	case q < 0:
+		return 0
+	case q > 100:
+		return 100
+	}
+	return int(q + 0.5)
+}
@@ -0,0 +1,52 @@
+package frontend
+
+import (
+	"testing"
+
+	"github.com/ziutek/dvb"
+)
+
+func counter(v int64) []Param {
+	return []Param{{scale: ScaleCounter, value: v}}
+}
+
+func TestStatQuality(t *testing.T) {
+	prev := &Stat{
+		PostErrBit: counter(100),
+		PostTotBit: counter(1e6),
+		ErrBlk:     counter(3),
+		TotBlk:     counter(1000),
+	}
+	cur := &Stat{
+		CNR:        []Param{{scale: ScaleDecibel, value: 23000}},
+		PostErrBit: counter(100),
+		PostTotBit: counter(2e6),
+		ErrBlk:     counter(3),
+		TotBlk:     counter(2000),
+	}
+	s := cur.Sub(prev)
+	if ber, ok := s.PostBER(); !ok || ber != 0 {
+		t.Fatalf("PostBER: %g %t", ber, ok)
+	}
+	if per, ok := s.PER(); !ok || per != 0 {
+		t.Fatalf("PER: %g %t", per, ok)
+	}
+	margin, ok := s.CNRMargin(dvb.SysDVBT, dvb.QAM64, dvb.FEC34)
+	if !ok || margin < 4.99 || margin > 5.01 {
+		t.Fatalf("CNRMargin: %g %t", margin, ok)
+	}
+	if q := s.Quality(dvb.SysDVBT, dvb.QAM64, dvb.FEC34); q != 50 {
+		t.Fatalf("Quality: %d", q)
+	}
+	// Cumulative counters contain uncorrected blocks.
+	if q := cur.Quality(dvb.SysDVBT, dvb.QAM64, dvb.FEC34); q != 10 {
+		t.Fatalf("Quality: %d", q)
+	}
+	if q := new(Stat).Quality(dvb.SysDVBT, dvb.QAM64, dvb.FEC34); q != -1 {
+		t.Fatalf("Quality: %d", q)
+	}
+	r := RelativeParam(-1)
+	if r.Relative() != 100 {
+		t.Fatalf("RelativeParam: %g", r.Relative())
+	}
+}
@@ -0,0 +1,682 @@
+package frontend
+
+import (
+	"errors"
+	"syscall"
+	"unsafe"
+
+	"github.com/ziutek/dvb"
+)
+
+var (
+	ErrNotSupported = errors.New("not supported by frontend")
+	ErrBadValue     = errors.New("bad value")
+)
+
+// Params is implemented by all APIv5 tuning parameter sets. Use Device.Apply
+// to tune frontend using Params and Device.Get to read back parameters
+// detected by frontend.
+type Params interface {
+	// DeliverySystem returns delivery system that p describes.
+	DeliverySystem() dvb.DeliverySystem
+	// Validate checks p against capabilities of frontend (see Info.Caps).
+	Validate(c Caps) error
+
+	appendProps(ps []property) []property
+	loadProps(ps []property)
+}
+
+func (d Device) ioctlProps(req uintptr, ps []property) syscall.Errno {
+	if len(ps) == 0 {
+		return 0
+	}
+	pss := properties{uint32(len(ps)), &ps[0]}
+	_, _, e := syscall.Syscall(
+		syscall.SYS_IOCTL,
+		d.Fd(),
+		req,
+		uintptr(unsafe.Pointer(&pss)),
+	)
+	return e
+}
+
+// Apply sets delivery system, all parameters from p and starts tuning. All
+// properties are passed to the kernel using one FE_SET_PROPERTY ioctl so
+// frontend never sees partially set parameters. Apply doesn't call
+// p.Validate.
+func (d Device) Apply(p Params) error {
+	ps := make([]property, 0, 24)
+	ps = append(ps, property{cmd: dtvClear})
+	ps = append(ps, property{
+		cmd: dtvDeliverySystem, data: uint32(p.DeliverySystem()),
+	})
+	ps = p.appendProps(ps)
+	ps = append(ps, property{cmd: dtvTune})
+	if e := d.ioctlProps(_FE_SET_PROPERTY, ps); e != 0 {
+		return Error{"apply", p.DeliverySystem().String() + " params", e}
+	}
+	return nil
+}
+
+// Get reads current parameters from frontend into p. After lock most drivers
+// report parameters detected in received signal in place of auto values.
+func (d Device) Get(p Params) error {
+	ps := make([]property, 0, 24)
+	ps = append(ps, property{cmd: dtvDeliverySystem})
+	ps = p.appendProps(ps)
+	for i := range ps {
+		ps[i].data = 0
+	}
+	if e := d.ioctlProps(_FE_GET_PROPERTY, ps); e != 0 {
+		return Error{"get", p.DeliverySystem().String() + " params", e}
+	}
+	p.loadProps(ps)
+	return nil
+}
+
+func prop(c cmd, v uint32) property {
+	return property{cmd: c, data: v}
+}
+
+// propValue returns value of first property in ps that has command c.
+func propValue(ps []property, c cmd) uint32 {
+	for i := range ps {
+		if ps[i].cmd == c {
+			return ps[i].data
+		}
+	}
+	return 0
+}
+
+func need(c, flag Caps, what string) error {
+	if c&flag == 0 {
+		return Error{"validate", what, ErrNotSupported}
+	}
+	return nil
+}
+
+func badValue(what string) error {
+	return Error{"validate", what, ErrBadValue}
+}
+
+func checkInversion(c Caps, i dvb.Inversion) error {
+	switch i {
+	case dvb.InversionOff, dvb.InversionOn:
+		return nil
+	case dvb.InversionAuto:
+		return need(c, CanInversionAuto, "inversion")
+	}
+	return badValue("inversion")
+}
+
+var fecCaps = map[dvb.CodeRate]Caps{
+	dvb.FEC12:   CanFEC12,
+	dvb.FEC23:   CanFEC23,
+	dvb.FEC34:   CanFEC34,
+	dvb.FEC45:   CanFEC45,
+	dvb.FEC56:   CanFEC56,
+	dvb.FEC67:   CanFEC67,
+	dvb.FEC78:   CanFEC78,
+	dvb.FEC89:   CanFEC89,
+	dvb.FECAuto: CanFECAuto,
+	dvb.FEC35:   Can2GModulation,
+	dvb.FEC910:  Can2GModulation,
+}
+
+// checkFEC checks does r is one of allowed code rates and is supported by
+// frontend.
+func checkFEC(c Caps, what string, r dvb.CodeRate, allowed ...dvb.CodeRate) error {
+	for _, a := range allowed {
+		if r == a {
+			if r == dvb.FECNone {
+				return nil
+			}
+			return need(c, fecCaps[r], what)
+		}
+	}
+	return badValue(what)
+}
+
+var modCaps = map[dvb.Modulation]Caps{
+	dvb.QPSK:    CanQPSK,
+	dvb.QAM16:   CanQAM16,
+	dvb.QAM32:   CanQAM32,
+	dvb.QAM64:   CanQAM64,
+	dvb.QAM128:  CanQAM128,
+	dvb.QAM256:  CanQAM256,
+	dvb.QAMAuto: CanQAMAuto,
+	dvb.VSB8:    Can8VSB,
+	dvb.VSB16:   Can16VSB,
+	dvb.PSK8:    Can2GModulation,
+	dvb.APSK16:  Can2GModulation,
+	dvb.APSK32:  Can2GModulation,
+}
+
+func checkModulation(c Caps, m dvb.Modulation, allowed ...dvb.Modulation) error {
+	for _, a := range allowed {
+		if m == a {
+			return need(c, modCaps[m], "modulation")
+		}
+	}
+	return badValue("modulation")
+}
+
+func checkTxMode(c Caps, m dvb.TxMode, allowed ...dvb.TxMode) error {
+	for _, a := range allowed {
+		if m == a {
+			if m == dvb.TxModeAuto {
+				return need(c, CanTxModeAuto, "transmission mode")
+			}
+			return nil
+		}
+	}
+	return badValue("transmission mode")
+}
+
+func checkGuard(c Caps, g dvb.Guard, allowed ...dvb.Guard) error {
+	for _, a := range allowed {
+		if g == a {
+			if g == dvb.GuardAuto {
+				return need(c, CanGuardAuto, "guard interval")
+			}
+			return nil
+		}
+	}
+	return badValue("guard interval")
+}
+
+func checkBandwidth(c Caps, hz uint32, allowed ...uint32) error {
+	if hz == 0 {
+		return need(c, CanBandwidthAuto, "bandwidth")
+	}
+	for _, a := range allowed {
+		if hz == a {
+			return nil
+		}
+	}
+	return badValue("bandwidth")
+}
+
+func checkFreq(f uint32) error {
+	if f == 0 {
+		return badValue("frequency")
+	}
+	return nil
+}
+
+func firstErr(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DVBTParams contains DVB-T tuning parameters.
+type DVBTParams struct {
+	Freq       uint32 // frequency in Hz
+	Bandwidth  uint32 // bandwidth in Hz (0 means auto)
+	Inversion  dvb.Inversion
+	Modulation dvb.Modulation
+	CodeRateHP dvb.CodeRate
+	CodeRateLP dvb.CodeRate
+	TxMode     dvb.TxMode
+	Guard      dvb.Guard
+	Hierarchy  dvb.Hierarchy
+}
+
+func (p *DVBTParams) DeliverySystem() dvb.DeliverySystem {
+	return dvb.SysDVBT
+}
+
+func (p *DVBTParams) Validate(c Caps) error {
+	var hierarchy error
+	switch p.Hierarchy {
+	case dvb.HierarchyNone, dvb.Hierarchy1, dvb.Hierarchy2, dvb.Hierarchy4:
+	case dvb.HierarchyAuto:
+		hierarchy = need(c, CanHierarchyAuto, "hierarchy")
+	default:
+		hierarchy = badValue("hierarchy")
+	}
+	fecs := []dvb.CodeRate{
+		dvb.FEC12, dvb.FEC23, dvb.FEC34, dvb.FEC56, dvb.FEC78, dvb.FECAuto,
+	}
+	codeRateLP := checkFEC(c, "code rate LP", p.CodeRateLP,
+		append(fecs, dvb.FECNone)...)
+	return firstErr(
+		checkFreq(p.Freq),
+		checkBandwidth(c, p.Bandwidth, 5e6, 6e6, 7e6, 8e6),
+		checkInversion(c, p.Inversion),
+		checkModulation(c, p.Modulation,
+			dvb.QPSK, dvb.QAM16, dvb.QAM64, dvb.QAMAuto),
+		checkFEC(c, "code rate HP", p.CodeRateHP, fecs...),
+		codeRateLP,
+		checkTxMode(c, p.TxMode, dvb.TxMode2k, dvb.TxMode8k, dvb.TxModeAuto),
+		checkGuard(c, p.Guard,
+			dvb.Guard32, dvb.Guard16, dvb.Guard8, dvb.Guard4, dvb.GuardAuto),
+		hierarchy,
+	)
+}
+
+func (p *DVBTParams) appendProps(ps []property) []property {
+	return append(
+		ps,
+		prop(dtvFrequency, p.Freq),
+		prop(dtvBandwidthHz, p.Bandwidth),
+		prop(dtvInversion, uint32(p.Inversion)),
+		prop(dtvModulation, uint32(p.Modulation)),
+		prop(dtvCodeRateHP, uint32(p.CodeRateHP)),
+		prop(dtvCodeRateLP, uint32(p.CodeRateLP)),
+		prop(dtvTransmissionMode, uint32(p.TxMode)),
+		prop(dtvGuardInterval, uint32(p.Guard)),
+		prop(dtvHierarchy, uint32(p.Hierarchy)),
+	)
+}
+
+func (p *DVBTParams) loadProps(ps []property) {
+	p.Freq = propValue(ps, dtvFrequency)
+	p.Bandwidth = propValue(ps, dtvBandwidthHz)
+	p.Inversion = dvb.Inversion(propValue(ps, dtvInversion))
+	p.Modulation = dvb.Modulation(propValue(ps, dtvModulation))
+	p.CodeRateHP = dvb.CodeRate(propValue(ps, dtvCodeRateHP))
+	p.CodeRateLP = dvb.CodeRate(propValue(ps, dtvCodeRateLP))
+	p.TxMode = dvb.TxMode(propValue(ps, dtvTransmissionMode))
+	p.Guard = dvb.Guard(propValue(ps, dtvGuardInterval))
+	p.Hierarchy = dvb.Hierarchy(propValue(ps, dtvHierarchy))
+}
+
+// DVBT2Params contains DVB-T2 tuning parameters.
+type DVBT2Params struct {
+	Freq       uint32 // frequency in Hz
+	Bandwidth  uint32 // bandwidth in Hz (0 means auto)
+	Inversion  dvb.Inversion
+	Modulation dvb.Modulation
+	CodeRate   dvb.CodeRate
+	TxMode     dvb.TxMode
+	Guard      dvb.Guard
+	StreamId   uint32 // PLP id (0-255) or NoStreamId
+}
+
+func (p *DVBT2Params) DeliverySystem() dvb.DeliverySystem {
+	return dvb.SysDVBT2
+}
+
+func (p *DVBT2Params) Validate(c Caps) error {
+	return firstErr(
+		need(c, Can2GModulation, "delivery system"),
+		checkFreq(p.Freq),
+		checkBandwidth(c, p.Bandwidth, 1712e3, 5e6, 6e6, 7e6, 8e6, 10e6),
+		checkInversion(c, p.Inversion),
+		checkModulation(c, p.Modulation,
+			dvb.QPSK, dvb.QAM16, dvb.QAM64, dvb.QAM256, dvb.QAMAuto),
+		checkFEC(c, "code rate", p.CodeRate,
+			dvb.FEC12, dvb.FEC35, dvb.FEC23, dvb.FEC34, dvb.FEC45, dvb.FEC56,
+			dvb.FECAuto),
+		checkTxMode(c, p.TxMode,
+			dvb.TxMode1k, dvb.TxMode2k, dvb.TxMode4k, dvb.TxMode8k,
+			dvb.TxMode16k, dvb.TxMode32k, dvb.TxModeAuto),
+		checkGuard(c, p.Guard,
+			dvb.Guard128, dvb.Guard32, dvb.Guard16, dvb.GuardN256,
+			dvb.Guard8, dvb.GuardN128, dvb.Guard4, dvb.GuardAuto),
+		checkStreamId(p.StreamId, 0xff),
+	)
+}
+
+func checkStreamId(id, max uint32) error {
+	if id > max && id != NoStreamId {
+		return badValue("stream id")
+	}
+	return nil
+}
+
+func (p *DVBT2Params) appendProps(ps []property) []property {
+	return append(
+		ps,
+		prop(dtvFrequency, p.Freq),
+		prop(dtvBandwidthHz, p.Bandwidth),
+		prop(dtvInversion, uint32(p.Inversion)),
+		prop(dtvModulation, uint32(p.Modulation)),
+		prop(dtvCodeRateHP, uint32(p.CodeRate)),
+		prop(dtvTransmissionMode, uint32(p.TxMode)),
+		prop(dtvGuardInterval, uint32(p.Guard)),
+		prop(dtvStreamId, p.StreamId),
+	)
+}
+
+func (p *DVBT2Params) loadProps(ps []property) {
+	p.Freq = propValue(ps, dtvFrequency)
+	p.Bandwidth = propValue(ps, dtvBandwidthHz)
+	p.Inversion = dvb.Inversion(propValue(ps, dtvInversion))
+	p.Modulation = dvb.Modulation(propValue(ps, dtvModulation))
+	p.CodeRate = dvb.CodeRate(propValue(ps, dtvCodeRateHP))
+	p.TxMode = dvb.TxMode(propValue(ps, dtvTransmissionMode))
+	p.Guard = dvb.Guard(propValue(ps, dtvGuardInterval))
+	p.StreamId = propValue(ps, dtvStreamId)
+}
+
+// DVBSParams contains DVB-S tuning parameters. Use SecParam to obtain Freq,
+// Tone and Voltage for given transponder frequency and polarization.
+type DVBSParams struct {
+	Freq       uint32 // intermediate frequency in kHz
+	SymbolRate uint32 // symbol rate in Bd
+	Inversion  dvb.Inversion
+	InnerFEC   dvb.CodeRate
+	Tone       Tone
+	Voltage    Voltage
+}
+
+func (p *DVBSParams) DeliverySystem() dvb.DeliverySystem {
+	return dvb.SysDVBS
+}
+
+func checkSymbolRate(sr uint32) error {
+	if sr == 0 {
+		return badValue("symbol rate")
+	}
+	return nil
+}
+
+func checkSEC(t Tone, v Voltage) error {
+	if t > ToneOff {
+		return badValue("tone")
+	}
+	if v > VoltageOff {
+		return badValue("voltage")
+	}
+	return nil
+}
+
+func (p *DVBSParams) Validate(c Caps) error {
+	return firstErr(
+		checkFreq(p.Freq),
+		checkSymbolRate(p.SymbolRate),
+		checkInversion(c, p.Inversion),
+		need(c, CanQPSK, "modulation"),
+		checkFEC(c, "inner fec", p.InnerFEC,
+			dvb.FEC12, dvb.FEC23, dvb.FEC34, dvb.FEC56, dvb.FEC78, dvb.FECAuto),
+		checkSEC(p.Tone, p.Voltage),
+	)
+}
+
+func (p *DVBSParams) appendProps(ps []property) []property {
+	return append(
+		ps,
+		prop(dtvFrequency, p.Freq),
+		prop(dtvSymbolRate, p.SymbolRate),
+		prop(dtvInversion, uint32(p.Inversion)),
+		prop(dtvModulation, uint32(dvb.QPSK)),
+		prop(dtvInnerFEC, uint32(p.InnerFEC)),
+		prop(dtvTone, uint32(p.Tone)),
+		prop(dtvVoltage, uint32(p.Voltage)),
+	)
+}
+
+func (p *DVBSParams) loadProps(ps []property) {
+	p.Freq = propValue(ps, dtvFrequency)
+	p.SymbolRate = propValue(ps, dtvSymbolRate)
+	p.Inversion = dvb.Inversion(propValue(ps, dtvInversion))
+	p.InnerFEC = dvb.CodeRate(propValue(ps, dtvInnerFEC))
+	p.Tone = Tone(propValue(ps, dtvTone))
+	p.Voltage = Voltage(propValue(ps, dtvVoltage))
+}
+
+// DVBS2Params contains DVB-S2 tuning parameters. Use SecParam to obtain Freq,
+// Tone and Voltage for given transponder frequency and polarization.
+type DVBS2Params struct {
+	Freq       uint32 // intermediate frequency in kHz
+	SymbolRate uint32 // symbol rate in Bd
+	Inversion  dvb.Inversion
+	Modulation dvb.Modulation
+	InnerFEC   dvb.CodeRate
+	Pilot      dvb.Pilot
+	Rolloff    dvb.Rolloff
+	Tone       Tone
+	Voltage    Voltage
+	PLSMode    PLSMode
+	PLSCode    uint32 // physical layer scrambling code (0 means default)
+
+	// HasStreamId enables input stream filtering in multistream signal.
+	// If it is false (default) StreamId is ignored and frontend passes
+	// all input streams.
+	HasStreamId bool
+	StreamId    uint32 // input stream id (ISI, 0-255)
+}
+
+func (p *DVBS2Params) DeliverySystem() dvb.DeliverySystem {
+	return dvb.SysDVBS2
+}
+
+func (p *DVBS2Params) Validate(c Caps) error {
+	var pilot, rolloff error
+	if p.Pilot > dvb.PilotAuto {
+		pilot = badValue("pilot")
+	}
+	if p.Rolloff > dvb.RolloffAuto {
+		rolloff = badValue("rolloff")
+	}
+	var pls error
+	if _, ok := p.goldCode(); !ok {
+		pls = badValue("PLS code")
+	}
+	return firstErr(
+		need(c, Can2GModulation, "delivery system"),
+		checkFreq(p.Freq),
+		checkSymbolRate(p.SymbolRate),
+		checkInversion(c, p.Inversion),
+		checkModulation(c, p.Modulation,
+			dvb.QPSK, dvb.PSK8, dvb.APSK16, dvb.APSK32),
+		checkFEC(c, "inner fec", p.InnerFEC,
+			dvb.FEC12, dvb.FEC35, dvb.FEC23, dvb.FEC34, dvb.FEC45, dvb.FEC56,
+			dvb.FEC89, dvb.FEC910, dvb.FECAuto),
+		pilot,
+		rolloff,
+		checkSEC(p.Tone, p.Voltage),
+		checkStreamId(p.streamId(), 0xff),
+		pls,
+	)
+}
+
+func (p *DVBS2Params) streamId() uint32 {
+	if !p.HasStreamId {
+		return NoStreamId
+	}
+	return p.StreamId
+}
+
+func (p *DVBS2Params) goldCode() (uint32, bool) {
+	if p.PLSMode == PLSRoot && p.PLSCode == 0 {
+		return 0, true // Default root code (1).
+	}
+	return PLSGoldCode(p.PLSMode, p.PLSCode)
+}
+
+func (p *DVBS2Params) appendProps(ps []property) []property {
+	// Scrambling sequence index isn't supported by older kernels so use it
+	// only when needed.
+	if g, _ := p.goldCode(); g != 0 {
+		ps = append(ps, prop(dtvScramblingSequenceIndex, g))
+	}
+	return append(
+		ps,
+		prop(dtvFrequency, p.Freq),
+		prop(dtvSymbolRate, p.SymbolRate),
+		prop(dtvInversion, uint32(p.Inversion)),
+		prop(dtvModulation, uint32(p.Modulation)),
+		prop(dtvInnerFEC, uint32(p.InnerFEC)),
+		prop(dtvPilot, uint32(p.Pilot)),
+		prop(dtvRolloff, uint32(p.Rolloff)),
+		prop(dtvTone, uint32(p.Tone)),
+		prop(dtvVoltage, uint32(p.Voltage)),
+		prop(dtvStreamId, p.streamId()),
+	)
+}
+
+func (p *DVBS2Params) loadProps(ps []property) {
+	p.Freq = propValue(ps, dtvFrequency)
+	p.SymbolRate = propValue(ps, dtvSymbolRate)
+	p.Inversion = dvb.Inversion(propValue(ps, dtvInversion))
+	p.Modulation = dvb.Modulation(propValue(ps, dtvModulation))
+	p.InnerFEC = dvb.CodeRate(propValue(ps, dtvInnerFEC))
+	p.Pilot = dvb.Pilot(propValue(ps, dtvPilot))
+	p.Rolloff = dvb.Rolloff(propValue(ps, dtvRolloff))
+	p.Tone = Tone(propValue(ps, dtvTone))
+	p.Voltage = Voltage(propValue(ps, dtvVoltage))
+	p.StreamId = propValue(ps, dtvStreamId)
+	p.HasStreamId = p.StreamId != NoStreamId
+	if !p.HasStreamId {
+		p.StreamId = 0
+	}
+	if g := propValue(ps, dtvScramblingSequenceIndex); g != 0 {
+		p.PLSMode, p.PLSCode = PLSGold, g
+	}
+}
+
+// DVBCParams contains DVB-C (ITU-T J.83 Annex A, B or C) tuning parameters.
+type DVBCParams struct {
+	// Annex should be one of dvb.SysDVBCAnnexA, dvb.SysDVBCAnnexB,
+	// dvb.SysDVBCAnnexC. Zero value (dvb.SysUndefined) means Annex A.
+	Annex      dvb.DeliverySystem
+	Freq       uint32 // frequency in Hz
+	SymbolRate uint32 // symbol rate in Bd (ignored for Annex B)
+	Inversion  dvb.Inversion
+	Modulation dvb.Modulation
+}
+
+func (p *DVBCParams) DeliverySystem() dvb.DeliverySystem {
+	if p.Annex == dvb.SysUndefined {
+		return dvb.SysDVBCAnnexA
+	}
+	return p.Annex
+}
+
+func (p *DVBCParams) Validate(c Caps) error {
+	var sr, modulation error
+	switch p.DeliverySystem() {
+	case dvb.SysDVBCAnnexA, dvb.SysDVBCAnnexC:
+		sr = checkSymbolRate(p.SymbolRate)
+		modulation = checkModulation(c, p.Modulation,
+			dvb.QAM16, dvb.QAM32, dvb.QAM64, dvb.QAM128, dvb.QAM256,
+			dvb.QAMAuto)
+	case dvb.SysDVBCAnnexB:
+		modulation = checkModulation(c, p.Modulation,
+			dvb.QAM64, dvb.QAM256, dvb.QAMAuto)
+	default:
+		return badValue("annex")
+	}
+	return firstErr(
+		checkFreq(p.Freq),
+		sr,
+		checkInversion(c, p.Inversion),
+		modulation,
+	)
+}
+
+func (p *DVBCParams) appendProps(ps []property) []property {
+	ps = append(
+		ps,
+		prop(dtvFrequency, p.Freq),
+		prop(dtvInversion, uint32(p.Inversion)),
+		prop(dtvModulation, uint32(p.Modulation)),
+	)
+	if p.DeliverySystem() != dvb.SysDVBCAnnexB {
+		ps = append(
+			ps,
+			prop(dtvSymbolRate, p.SymbolRate),
+			prop(dtvInnerFEC, uint32(dvb.FECNone)),
+		)
+	}
+	return ps
+}
+
+func (p *DVBCParams) loadProps(ps []property) {
+	p.Annex = dvb.DeliverySystem(propValue(ps, dtvDeliverySystem))
+	p.Freq = propValue(ps, dtvFrequency)
+	p.SymbolRate = propValue(ps, dtvSymbolRate)
+	p.Inversion = dvb.Inversion(propValue(ps, dtvInversion))
+	p.Modulation = dvb.Modulation(propValue(ps, dtvModulation))
+}
+
+// ATSCParams contains ATSC (8-VSB, 16-VSB) tuning parameters. Use DVBCParams
+// with dvb.SysDVBCAnnexB for QAM cable channels.
+type ATSCParams struct {
+	Freq       uint32 // frequency in Hz
+	Inversion  dvb.Inversion
+	Modulation dvb.Modulation
+}
+
+func (p *ATSCParams) DeliverySystem() dvb.DeliverySystem {
+	return dvb.SysATSC
+}
+
+func (p *ATSCParams) Validate(c Caps) error {
+	return firstErr(
+		checkFreq(p.Freq),
+		checkInversion(c, p.Inversion),
+		checkModulation(c, p.Modulation, dvb.VSB8, dvb.VSB16),
+	)
+}
+
+func (p *ATSCParams) appendProps(ps []property) []property {
+	return append(
+		ps,
+		prop(dtvFrequency, p.Freq),
+		prop(dtvInversion, uint32(p.Inversion)),
+		prop(dtvModulation, uint32(p.Modulation)),
+	)
+}
+
+func (p *ATSCParams) loadProps(ps []property) {
+	p.Freq = propValue(ps, dtvFrequency)
+	p.Inversion = dvb.Inversion(propValue(ps, dtvInversion))
+	p.Modulation = dvb.Modulation(propValue(ps, dtvModulation))
+}
+
+// ISDBTParams contains ISDB-T tuning parameters. If Layers is nil layer
+// parameters are left to be detected by frontend (from TMCC). Device.Get reads
+// layer parameters only if Layers isn't nil.
+type ISDBTParams struct {
+	Freq      uint32 // frequency in Hz
+	Bandwidth uint32 // bandwidth in Hz (0 means auto)
+	Inversion dvb.Inversion
+	Layers    *ISDBTLayerParams
+}
+
+func (p *ISDBTParams) DeliverySystem() dvb.DeliverySystem {
+	return dvb.SysISDBT
+}
+
+func (p *ISDBTParams) Validate(c Caps) error {
+	var layers error
+	if p.Layers != nil {
+		layers = p.Layers.Validate(c)
+	}
+	return firstErr(
+		checkFreq(p.Freq),
+		checkBandwidth(c, p.Bandwidth, 6e6, 7e6, 8e6),
+		checkInversion(c, p.Inversion),
+		layers,
+	)
+}
+
+func (p *ISDBTParams) appendProps(ps []property) []property {
+	ps = append(
+		ps,
+		prop(dtvFrequency, p.Freq),
+		prop(dtvBandwidthHz, p.Bandwidth),
+		prop(dtvInversion, uint32(p.Inversion)),
+	)
+	if p.Layers != nil {
+		ps = p.Layers.appendProps(ps)
+	}
+	return ps
+}
+
+func (p *ISDBTParams) loadProps(ps []property) {
+	p.Freq = propValue(ps, dtvFrequency)
+	p.Bandwidth = propValue(ps, dtvBandwidthHz)
+	p.Inversion = dvb.Inversion(propValue(ps, dtvInversion))
+	if p.Layers != nil {
+		p.Layers.loadProps(ps)
+	}
+}
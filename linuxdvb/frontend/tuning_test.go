@@ -0,0 +1,199 @@
+package frontend
+
+import (
+	"testing"
+
+	"github.com/ziutek/dvb"
+)
+
+// errKind returns ErrNotSupported or ErrBadValue wrapped by err or nil.
+func errKind(err error) error {
+	if e, ok := err.(Error); ok {
+		return e.Err
+	}
+	return err
+}
+
+const allCaps = ^Caps(0)
+
+func TestCheckFEC(t *testing.T) {
+	allowed := []dvb.CodeRate{dvb.FEC12, dvb.FEC35, dvb.FECAuto, dvb.FECNone}
+	tests := []struct {
+		caps Caps
+		r    dvb.CodeRate
+		err  error
+	}{
+		{CanFEC12, dvb.FEC12, nil},
+		{0, dvb.FEC12, ErrNotSupported},
+		{Can2GModulation, dvb.FEC35, nil},
+		{CanFEC12, dvb.FEC35, ErrNotSupported},
+		{CanFECAuto, dvb.FECAuto, nil},
+		{0, dvb.FECAuto, ErrNotSupported},
+		{0, dvb.FECNone, nil},
+		{allCaps, dvb.FEC78, ErrBadValue},
+	}
+	for i, tc := range tests {
+		err := checkFEC(tc.caps, "fec", tc.r, allowed...)
+		if errKind(err) != tc.err {
+			t.Errorf("%d: checkFEC(%v): %v, expected %v", i, tc.r, err, tc.err)
+		}
+	}
+}
+
+func TestCheckModulation(t *testing.T) {
+	allowed := []dvb.Modulation{dvb.QPSK, dvb.QAM64, dvb.PSK8}
+	tests := []struct {
+		caps Caps
+		m    dvb.Modulation
+		err  error
+	}{
+		{CanQPSK, dvb.QPSK, nil},
+		{CanQAM64, dvb.QPSK, ErrNotSupported},
+		{CanQAM64, dvb.QAM64, nil},
+		{Can2GModulation, dvb.PSK8, nil},
+		{CanQPSK, dvb.PSK8, ErrNotSupported},
+		{allCaps, dvb.QAM256, ErrBadValue},
+	}
+	for i, tc := range tests {
+		err := checkModulation(tc.caps, tc.m, allowed...)
+		if errKind(err) != tc.err {
+			t.Errorf("%d: checkModulation(%v): %v, expected %v",
+				i, tc.m, err, tc.err)
+		}
+	}
+}
+
+func TestCheckStreamId(t *testing.T) {
+	tests := []struct {
+		id, max uint32
+		err     error
+	}{
+		{0, 0xff, nil},
+		{0xff, 0xff, nil},
+		{0x100, 0xff, ErrBadValue},
+		{NoStreamId, 0xff, nil},
+		{0xffff, 0xffff, nil},
+		{0x10000, 0xffff, ErrBadValue},
+	}
+	for i, tc := range tests {
+		err := checkStreamId(tc.id, tc.max)
+		if errKind(err) != tc.err {
+			t.Errorf("%d: checkStreamId(%#x, %#x): %v, expected %v",
+				i, tc.id, tc.max, err, tc.err)
+		}
+	}
+}
+
+func TestCheckMisc(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		exp  error
+	}{
+		{"inversion auto", checkInversion(CanInversionAuto, dvb.InversionAuto), nil},
+		{"inversion auto unsupported", checkInversion(0, dvb.InversionAuto), ErrNotSupported},
+		{"inversion bad", checkInversion(allCaps, dvb.Inversion(7)), ErrBadValue},
+		{"bandwidth auto", checkBandwidth(CanBandwidthAuto, 0, 8e6), nil},
+		{"bandwidth auto unsupported", checkBandwidth(0, 0, 8e6), ErrNotSupported},
+		{"bandwidth", checkBandwidth(0, 8e6, 7e6, 8e6), nil},
+		{"bandwidth bad", checkBandwidth(allCaps, 9e6, 7e6, 8e6), ErrBadValue},
+		{"tx mode", checkTxMode(0, dvb.TxMode8k, dvb.TxMode8k), nil},
+		{"tx mode auto", checkTxMode(0, dvb.TxModeAuto, dvb.TxModeAuto), ErrNotSupported},
+		{"tx mode bad", checkTxMode(allCaps, dvb.TxMode1k, dvb.TxMode8k), ErrBadValue},
+		{"guard", checkGuard(0, dvb.Guard4, dvb.Guard4), nil},
+		{"guard auto", checkGuard(0, dvb.GuardAuto, dvb.GuardAuto), ErrNotSupported},
+		{"guard bad", checkGuard(allCaps, dvb.Guard128, dvb.Guard4), ErrBadValue},
+		{"freq", checkFreq(0), ErrBadValue},
+		{"symbol rate", checkSymbolRate(0), ErrBadValue},
+		{"tone", checkSEC(ToneOff+1, VoltageOff), ErrBadValue},
+		{"voltage", checkSEC(ToneOff, VoltageOff+1), ErrBadValue},
+	}
+	for _, tc := range tests {
+		if errKind(tc.err) != tc.exp {
+			t.Errorf("%s: %v, expected %v", tc.name, tc.err, tc.exp)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dvbt := &DVBTParams{
+		Freq:       538e6,
+		Bandwidth:  8e6,
+		Modulation: dvb.QAM64,
+		CodeRateHP: dvb.FEC23,
+		CodeRateLP: dvb.FECNone,
+		TxMode:     dvb.TxMode8k,
+		Guard:      dvb.Guard4,
+		Hierarchy:  dvb.HierarchyNone,
+	}
+	dvbtAuto := *dvbt
+	dvbtAuto.Hierarchy = dvb.HierarchyAuto
+	dvbt2 := &DVBT2Params{
+		Freq:       538e6,
+		Bandwidth:  8e6,
+		Modulation: dvb.QAM256,
+		CodeRate:   dvb.FEC23,
+		TxMode:     dvb.TxMode32k,
+		Guard:      dvb.Guard128,
+		StreamId:   0x100,
+	}
+	dvbs := &DVBSParams{
+		Freq:       1e6,
+		SymbolRate: 27.5e6,
+		InnerFEC:   dvb.FEC34,
+	}
+	dvbs2 := &DVBS2Params{
+		Freq:       1e6,
+		SymbolRate: 27.5e6,
+		Modulation: dvb.PSK8,
+		InnerFEC:   dvb.FEC910,
+		PLSMode:    PLSGold,
+		PLSCode:    1 << 18,
+	}
+	tests := []struct {
+		p    Params
+		caps Caps
+		what string
+		err  error
+	}{
+		{dvbt, CanQAM64 | CanFEC23, "", nil},
+		{dvbt, CanFEC23, "modulation", ErrNotSupported},
+		{&dvbtAuto, CanQAM64 | CanFEC23, "hierarchy", ErrNotSupported},
+		{dvbt2, allCaps, "stream id", ErrBadValue},
+		{dvbt2, allCaps &^ Can2GModulation, "delivery system", ErrNotSupported},
+		{dvbs, CanQPSK | CanFEC34, "", nil},
+		{dvbs, CanFEC34, "modulation", ErrNotSupported},
+		{dvbs2, allCaps, "PLS code", ErrBadValue},
+	}
+	for i, tc := range tests {
+		err := tc.p.Validate(tc.caps)
+		if errKind(err) != tc.err {
+			t.Errorf("%d: %v, expected %v", i, err, tc.err)
+			continue
+		}
+		if e, ok := err.(Error); ok && e.What != tc.what {
+			t.Errorf("%d: %v, expected error about %s", i, err, tc.what)
+		}
+	}
+}
+
+func TestDVBS2StreamId(t *testing.T) {
+	var p DVBS2Params
+	if id := propValue(p.appendProps(nil), dtvStreamId); id != NoStreamId {
+		t.Errorf("zero value sends stream id %#x", id)
+	}
+	p.HasStreamId = true
+	ps := p.appendProps(nil)
+	if id := propValue(ps, dtvStreamId); id != 0 {
+		t.Errorf("stream id %#x, expected 0", id)
+	}
+	var q DVBS2Params
+	q.loadProps(ps)
+	if !q.HasStreamId || q.StreamId != 0 {
+		t.Errorf("loaded %t %#x", q.HasStreamId, q.StreamId)
+	}
+	q.loadProps(new(DVBS2Params).appendProps(nil))
+	if q.HasStreamId || q.StreamId != 0 {
+		t.Errorf("loaded %t %#x", q.HasStreamId, q.StreamId)
+	}
+}
@@ -2,6 +2,8 @@
 #include <stdio.h>
 #include <linux/dvb/frontend.h>
 #include <linux/dvb/dmx.h>
+#include <linux/dvb/ca.h>
+#include <linux/dvb/net.h>
 
 typedef struct tuple tuple;
 struct tuple {
@@ -35,6 +37,18 @@ tuple table[] = {
 	{"_DMX_GET_STC", DMX_GET_STC},
 	{"_DMX_ADD_PID", DMX_ADD_PID},
 	{"_DMX_REMOVE_PID", DMX_REMOVE_PID},
+
+	{"_CA_RESET", CA_RESET},
+	{"_CA_GET_CAP", CA_GET_CAP},
+	{"_CA_GET_SLOT_INFO", CA_GET_SLOT_INFO},
+	{"_CA_GET_DESCR_INFO", CA_GET_DESCR_INFO},
+	{"_CA_GET_MSG", CA_GET_MSG},
+	{"_CA_SEND_MSG", CA_SEND_MSG},
+	{"_CA_SET_DESCR", CA_SET_DESCR},
+
+	{"_NET_ADD_IF", NET_ADD_IF},
+	{"_NET_REMOVE_IF", NET_REMOVE_IF},
+	{"_NET_GET_IF", NET_GET_IF},
 };
 
 int
@@ -0,0 +1,95 @@
+// Package net provides interface to Linux DVB net device that creates network
+// interfaces receiving IP datagrams encapsulated in MPE or ULE.
+package net
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// Encapsulation specifies method used to carry IP datagrams in MPEG-TS.
+type Encapsulation uint8
+
+const (
+	MPE Encapsulation = iota // multiprotocol encapsulation (EN 301 192)
+	ULE                      // unidirectional lightweight encapsulation (RFC 4326)
+)
+
+func (e Encapsulation) String() string {
+	switch e {
+	case MPE:
+		return "MPE"
+	case ULE:
+		return "ULE"
+	}
+	return "unknown"
+}
+
+// If describes network interface created by net device.
+type If struct {
+	Pid      uint16
+	Num      uint16
+	FeedType Encapsulation
+}
+
+// Name returns name of network interface created by net device of adapter.
+func (i If) Name(adapter int) string {
+	return "dvb" + strconv.Itoa(adapter) + "_" + strconv.Itoa(int(i.Num))
+}
+
+// Device represents Linux DVB net device.
+type Device struct {
+	file *os.File
+}
+
+func Open(path string) (d Device, err error) {
+	for {
+		d.file, err = os.OpenFile(path, os.O_RDWR, 0)
+		if err == nil || err.(*os.PathError).Err != syscall.EINTR {
+			break
+		}
+	}
+	return
+}
+
+func (d Device) Close() error {
+	return d.file.Close()
+}
+
+func (d Device) ioctl(req uintptr, i *If) error {
+	_, _, e := syscall.Syscall(
+		syscall.SYS_IOCTL, d.file.Fd(), req, uintptr(unsafe.Pointer(i)),
+	)
+	if e != 0 {
+		return e
+	}
+	return nil
+}
+
+// AddIf creates network interface that receives datagrams encapsulated using
+// enc from pid. It returns description of created interface.
+func (d Device) AddIf(pid int16, enc Encapsulation) (If, error) {
+	i := If{Pid: uint16(pid), FeedType: enc}
+	err := d.ioctl(_NET_ADD_IF, &i)
+	return i, err
+}
+
+// RemoveIf removes network interface num.
+func (d Device) RemoveIf(num int) error {
+	_, _, e := syscall.Syscall(
+		syscall.SYS_IOCTL, d.file.Fd(), _NET_REMOVE_IF, uintptr(num),
+	)
+	if e != 0 {
+		return e
+	}
+	return nil
+}
+
+// GetIf returns description of network interface num.
+func (d Device) GetIf(num int) (If, error) {
+	i := If{Num: uint16(num)}
+	err := d.ioctl(_NET_GET_IF, &i)
+	return i, err
+}
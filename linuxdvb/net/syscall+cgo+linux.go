@@ -0,0 +1,15 @@
+// +build linux,cgo
+
+package net
+
+/*
+#include <sys/ioctl.h>
+#include <linux/dvb/net.h>
+*/
+import "C"
+
+const (
+	_NET_ADD_IF    = C.NET_ADD_IF
+	_NET_REMOVE_IF = C.NET_REMOVE_IF
+	_NET_GET_IF    = C.NET_GET_IF
+)
@@ -0,0 +1,11 @@
+// +build !cgo,linux,amd64
+
+package net
+
+// Generated by ../gensyscall/main.c
+
+const (
+	_NET_ADD_IF    = 0xc0066f34
+	_NET_REMOVE_IF = 0x00006f35
+	_NET_GET_IF    = 0xc0066f36
+)
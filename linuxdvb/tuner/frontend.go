@@ -0,0 +1,137 @@
+package tuner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/ziutek/dvb"
+	"github.com/ziutek/dvb/linuxdvb/adapter"
+	"github.com/ziutek/dvb/linuxdvb/demux"
+	"github.com/ziutek/dvb/linuxdvb/frontend"
+)
+
+var (
+	ErrMux   = errors.New("mux isn't described by frontend.Params")
+	ErrNoPid = errors.New("no PIDs specified")
+)
+
+// Frontend is a Backend that uses Linux DVB frontend and demux devices.
+type Frontend struct {
+	Path    string // path to frontend device
+	Demux   demux.Device
+	Systems []dvb.DeliverySystem
+
+	// PollInterval is a period of frontend status checks during tuning.
+	// Default 20 ms.
+	PollInterval time.Duration
+
+	dev    frontend.Device
+	opened bool
+}
+
+// NewFrontend returns backend that uses frontend described by fi and demux
+// returned by a.DemuxFor.
+func NewFrontend(a *adapter.Adapter, fi *adapter.FrontendInfo) *Frontend {
+	n, _ := a.DemuxFor(fi.Num)
+	return &Frontend{
+		Path:    a.DevPath(adapter.Frontend, fi.Num),
+		Demux:   demux.Device(a.DevPath(adapter.Demux, n)),
+		Systems: fi.DeliverySystems,
+	}
+}
+
+func (f *Frontend) DeliverySystems() []dvb.DeliverySystem {
+	return f.Systems
+}
+
+// DemuxDevice implements DemuxUser.
+func (f *Frontend) DemuxDevice() string {
+	return string(f.Demux)
+}
+
+// Tune opens frontend device (if not opened), applies parameters and waits
+// for lock. m must be frontend.Params.
+func (f *Frontend) Tune(ctx context.Context, m Mux) error {
+	p, ok := m.(frontend.Params)
+	if !ok {
+		return ErrMux
+	}
+	if !f.opened {
+		dev, err := frontend.Open(f.Path)
+		if err != nil {
+			return err
+		}
+		f.dev, f.opened = dev, true
+	}
+	if err := f.dev.Apply(p); err != nil {
+		return err
+	}
+	interval := f.PollInterval
+	if interval == 0 {
+		interval = 20 * time.Millisecond
+	}
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		s, err := frontend.API3{Device: f.dev}.Status()
+		if err != nil {
+			return err
+		}
+		if s&frontend.HasLock != 0 {
+			return nil
+		}
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// OpenStream returns demux filter that outputs TS packets of pids.
+func (f *Frontend) OpenStream(pids ...int16) (io.ReadCloser, error) {
+	if len(pids) == 0 {
+		return nil, ErrNoPid
+	}
+	filter, err := f.Demux.NewStreamFilter(&demux.StreamFilterParam{
+		Pid:   pids[0],
+		In:    demux.InFrontend,
+		Out:   demux.OutTSDemuxTap,
+		Type:  demux.Other,
+		Flags: demux.ImmediateStart,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, pid := range pids[1:] {
+		if err := filter.AddPid(pid); err != nil {
+			filter.Close()
+			return nil, err
+		}
+	}
+	return filter, nil
+}
+
+// Idle closes frontend device.
+func (f *Frontend) Idle() error {
+	if !f.opened {
+		return nil
+	}
+	f.opened = false
+	return f.dev.Close()
+}
+
+// AddAdapters adds to p all frontends from as that were successfully probed
+// and have demux device (see adapter.Adapter.DemuxFor).
+func (p *Pool) AddAdapters(as []*adapter.Adapter) {
+	for _, a := range as {
+		for i := range a.Frontends {
+			fi := &a.Frontends[i]
+			if _, ok := a.DemuxFor(fi.Num); fi.Err == nil && ok {
+				p.Add(a.Num, NewFrontend(a, fi))
+			}
+		}
+	}
+}
@@ -0,0 +1,363 @@
+// Package tuner manages a pool of tuners (frontend/demux pairs) shared by
+// many users of multi-adapter systems.
+package tuner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+
+	"github.com/ziutek/dvb"
+)
+
+var (
+	ErrNoTuner   = errors.New("no free tuner for requested mux")
+	ErrNoFilters = errors.New("demux filter limit exceeded")
+	ErrReleased  = errors.New("lease released")
+)
+
+// Mux describes multiplex (transport stream) to receive. Any
+// frontend.Params implements Mux. Two Muxes are considered equal if
+// reflect.DeepEqual reports that they are equal.
+type Mux interface {
+	DeliverySystem() dvb.DeliverySystem
+}
+
+// Backend is a tuner that can be managed by Pool.
+type Backend interface {
+	// DeliverySystems returns delivery systems supported by backend.
+	DeliverySystems() []dvb.DeliverySystem
+
+	// Tune tunes backend to m. It should return after lock is obtained or
+	// ctx is done.
+	Tune(ctx context.Context, m Mux) error
+
+	// OpenStream returns stream of TS packets that belong to pids. Every
+	// Read returns whole TS packets. OpenStream uses len(pids) demux
+	// filters.
+	OpenStream(pids ...int16) (io.ReadCloser, error)
+
+	// Idle is called when last lease of tuned backend is released. Backend
+	// can close its frontend device. Idle backend is always tuned again
+	// before next use.
+	Idle() error
+}
+
+// DemuxUser can be implemented by Backend that reads transport stream from
+// demux device shared with other backends (eg. frontends of one adapter that
+// have only demux0). Pool never tunes backends that use the same demux
+// device to different muxes at the same time.
+type DemuxUser interface {
+	// DemuxDevice returns path to demux device used by backend.
+	DemuxDevice() string
+}
+
+type tuner struct {
+	b        Backend
+	adapter  int
+	demux    string // shared demux device or empty string
+	mux      Mux
+	refs     int
+	ready    chan struct{} // closed after tuning
+	err      error         // tuning error
+	canceled bool          // tuning interrupted by done context
+}
+
+func (t *tuner) supports(ds dvb.DeliverySystem) bool {
+	for _, s := range t.b.DeliverySystems() {
+		if s == ds {
+			return true
+		}
+	}
+	return false
+}
+
+// Pool manages tuners of many adapters. Tuners of one adapter share its
+// demux filter limit. Pool is safe for concurrent use.
+type Pool struct {
+	mu      sync.Mutex
+	tuners  []*tuner
+	limits  map[int]int // per adapter filter limits
+	filters map[int]int // per adapter filters in use
+}
+
+func NewPool() *Pool {
+	return &Pool{limits: make(map[int]int), filters: make(map[int]int)}
+}
+
+// Add adds backend b that belongs to adapter to p.
+func (p *Pool) Add(adapter int, b Backend) {
+	p.mu.Lock()
+	t := &tuner{b: b, adapter: adapter}
+	if du, ok := b.(DemuxUser); ok {
+		t.demux = du.DemuxDevice()
+	}
+	p.tuners = append(p.tuners, t)
+	p.mu.Unlock()
+}
+
+// SetFilterLimit sets maximum number of demux filters that can be used at
+// the same time by all leases of adapter. Zero means no limit (default).
+func (p *Pool) SetFilterLimit(adapter, n int) {
+	p.mu.Lock()
+	p.limits[adapter] = n
+	p.mu.Unlock()
+}
+
+// Lease returns lease of tuner tuned to m. If some tuner is already tuned to
+// m it is shared, otherwise Lease tunes first free tuner that supports
+// delivery system of m and doesn't share demux device with tuner tuned to
+// other mux. If tuning fails Lease tries other free tuners and returns the
+// last error if none of them can be tuned. The lease is released when
+// Release is called or ctx is done.
+func (p *Pool) Lease(ctx context.Context, m Mux) (*Lease, error) {
+	tried := make(map[*tuner]bool)
+	err := ErrNoTuner
+	for {
+		t, tune := p.acquire(m, tried)
+		if t == nil {
+			return nil, err
+		}
+		err = nil
+		if !tune {
+			tune, err = p.wait(ctx, t)
+		}
+		if tune {
+			err = t.b.Tune(ctx, m)
+			p.mu.Lock()
+			t.err = err
+			t.canceled = err != nil && ctx.Err() != nil
+			close(t.ready)
+			p.mu.Unlock()
+		}
+		if err == nil {
+			return p.newLease(ctx, t, m), nil
+		}
+		p.release(t) // Idle error is less important than tuning error
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		tried[t] = true
+	}
+}
+
+// wait waits until t is tuned by other Lease call. If tuning was interrupted
+// because context of other Lease call was done, wait takes over tuning and
+// returns tune == true.
+func (p *Pool) wait(ctx context.Context, t *tuner) (tune bool, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		ready := t.ready
+		p.mu.Unlock()
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			p.mu.Lock()
+			return false, ctx.Err()
+		}
+		p.mu.Lock()
+		if t.ready != ready {
+			continue // other waiter took over tuning
+		}
+		if !t.canceled {
+			return false, t.err
+		}
+		t.canceled = false
+		t.err = nil
+		t.ready = make(chan struct{})
+		return true, nil
+	}
+}
+
+// busy reports whether demux device of t is used by other tuner.
+func (p *Pool) busy(t *tuner) bool {
+	if t.demux == "" {
+		return false
+	}
+	for _, tt := range p.tuners {
+		if tt != t && tt.demux == t.demux && tt.refs > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// acquire returns tuner tuned to m or free tuner that supports delivery
+// system of m. Tuners in tried are skipped. If returned tuner must be tuned
+// tune is true.
+func (p *Pool) acquire(m Mux, tried map[*tuner]bool) (t *tuner, tune bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var free *tuner
+	for _, tt := range p.tuners {
+		if tried[tt] {
+			continue
+		}
+		if tt.mux != nil && reflect.DeepEqual(tt.mux, m) {
+			t = tt
+			break
+		}
+		if free == nil && tt.refs == 0 && tt.supports(m.DeliverySystem()) &&
+			!p.busy(tt) {
+			free = tt
+		}
+	}
+	if t == nil {
+		if free == nil {
+			return nil, false
+		}
+		t, tune = free, true
+		t.mux = m
+		t.err = nil
+		t.canceled = false
+		t.ready = make(chan struct{})
+	}
+	t.refs++
+	return t, tune
+}
+
+func (p *Pool) newLease(ctx context.Context, t *tuner, m Mux) *Lease {
+	l := &Lease{p: p, t: t, m: m, done: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			l.Release()
+		case <-l.done:
+		}
+	}()
+	return l
+}
+
+func (p *Pool) release(t *tuner) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if t.refs--; t.refs > 0 {
+		return nil
+	}
+	t.mux = nil
+	return t.b.Idle()
+}
+
+func (p *Pool) allocFilters(adapter, n int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if limit := p.limits[adapter]; limit > 0 && p.filters[adapter]+n > limit {
+		return ErrNoFilters
+	}
+	p.filters[adapter] += n
+	return nil
+}
+
+func (p *Pool) freeFilters(adapter, n int) {
+	p.mu.Lock()
+	p.filters[adapter] -= n
+	p.mu.Unlock()
+}
+
+// Lease represents tuner leased from Pool.
+type Lease struct {
+	p    *Pool
+	t    *tuner
+	m    Mux
+	once sync.Once
+	done chan struct{}
+	err  error // returned by Release
+
+	mu       sync.Mutex
+	streams  map[*stream]struct{} // open streams
+	released bool
+}
+
+// Mux returns mux to which leased tuner is tuned.
+func (l *Lease) Mux() Mux {
+	return l.m
+}
+
+// Adapter returns number of adapter of leased tuner.
+func (l *Lease) Adapter() int {
+	return l.t.adapter
+}
+
+// Backend returns leased backend.
+func (l *Lease) Backend() Backend {
+	return l.t.b
+}
+
+// Done returns channel that is closed after lease is released.
+func (l *Lease) Done() <-chan struct{} {
+	return l.done
+}
+
+// OpenStream opens stream that contains pids. It returns ErrNoFilters if
+// adapter filter limit would be exceeded. Streams that remain open are
+// closed by Release.
+func (l *Lease) OpenStream(pids ...int16) (io.ReadCloser, error) {
+	select {
+	case <-l.done:
+		return nil, ErrReleased
+	default:
+	}
+	if err := l.p.allocFilters(l.t.adapter, len(pids)); err != nil {
+		return nil, err
+	}
+	r, err := l.t.b.OpenStream(pids...)
+	if err != nil {
+		l.p.freeFilters(l.t.adapter, len(pids))
+		return nil, err
+	}
+	s := &stream{ReadCloser: r, l: l, n: len(pids)}
+	l.mu.Lock()
+	if l.released {
+		l.mu.Unlock()
+		s.Close()
+		return nil, ErrReleased
+	}
+	if l.streams == nil {
+		l.streams = make(map[*stream]struct{})
+	}
+	l.streams[s] = struct{}{}
+	l.mu.Unlock()
+	return s, nil
+}
+
+// Release releases lease and closes all its open streams. Tuner becomes free
+// if it isn't used by other leases. Release can be called multiple times. It
+// returns error returned by Backend.Idle (the same error is returned by all
+// calls).
+func (l *Lease) Release() error {
+	l.once.Do(func() {
+		l.mu.Lock()
+		l.released = true
+		streams := l.streams
+		l.streams = nil
+		l.mu.Unlock()
+		for s := range streams {
+			s.Close()
+		}
+		l.err = l.p.release(l.t)
+		close(l.done)
+	})
+	return l.err
+}
+
+type stream struct {
+	io.ReadCloser
+	l    *Lease
+	once sync.Once
+	n    int
+	err  error
+}
+
+func (s *stream) Close() error {
+	s.once.Do(func() {
+		s.l.mu.Lock()
+		delete(s.l.streams, s)
+		s.l.mu.Unlock()
+		s.l.p.freeFilters(s.l.t.adapter, s.n)
+		s.err = s.ReadCloser.Close()
+	})
+	return s.err
+}
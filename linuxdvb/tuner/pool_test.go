@@ -0,0 +1,318 @@
+package tuner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ziutek/dvb"
+	"github.com/ziutek/dvb/ts"
+)
+
+type testMux struct {
+	Freq uint32
+}
+
+func (m *testMux) DeliverySystem() dvb.DeliverySystem {
+	return dvb.SysDVBT
+}
+
+func TestPool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tuner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var buf bytes.Buffer
+	w := ts.PktStreamWriter{W: &buf}
+	for i := 0; i < 10; i++ {
+		p := new(ts.ArrayPkt)
+		p.SetSync()
+		p.SetPid(int16(0x100 + i%3))
+		if err := w.WritePkt(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, "mux.ts")
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m1, m2, m3 := &testMux{474e6}, &testMux{482e6}, &testMux{490e6}
+	replay := func() *Replay {
+		return &Replay{
+			Systems: []dvb.DeliverySystem{dvb.SysDVBT},
+			Muxes:   []ReplayMux{{m1, path}, {m2, path}},
+		}
+	}
+	p := NewPool()
+	p.Add(0, replay())
+	p.Add(1, replay())
+	p.SetFilterLimit(0, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l1, err := p.Lease(ctx, m1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l2, err := p.Lease(context.Background(), &testMux{474e6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l1.Backend() != l2.Backend() {
+		t.Fatal("tuner not shared")
+	}
+	if _, err := p.Lease(context.Background(), m3); err != ErrNoSignal {
+		t.Fatal("expected ErrNoSignal, got", err)
+	}
+	l3, err := p.Lease(context.Background(), m2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l3.Adapter() != 1 {
+		t.Fatal("bad adapter", l3.Adapter())
+	}
+	if _, err := p.Lease(context.Background(), m3); err != ErrNoTuner {
+		t.Fatal("expected ErrNoTuner, got", err)
+	}
+
+	s, err := l1.OpenStream(0x100, 0x102)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l2.OpenStream(0x101); err != ErrNoFilters {
+		t.Fatal("expected ErrNoFilters, got", err)
+	}
+	data, err := ioutil.ReadAll(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 7*ts.PktLen {
+		t.Fatalf("read %d packets", len(data)/ts.PktLen)
+	}
+	s.Close()
+	s, err = l2.OpenStream(0x101)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	l3.Release()
+	cancel()
+	select {
+	case <-l1.Done():
+	case <-time.After(time.Second):
+		t.Fatal("lease not released on cancel")
+	}
+	if _, err := l1.OpenStream(0x100); err != ErrReleased {
+		t.Fatal("expected ErrReleased, got", err)
+	}
+	// Adapter 0 is still used by l2.
+	if l, err := p.Lease(context.Background(), m2); err != nil || l.Adapter() != 1 {
+		t.Fatal("can't lease adapter 1:", err)
+	}
+	l2.Release()
+	if l, err := p.Lease(context.Background(), m1); err != nil || l.Adapter() != 0 {
+		t.Fatal("can't lease adapter 0:", err)
+	}
+}
+
+type fakeBackend struct {
+	err     error
+	idleErr error
+	demux   string
+	block   bool // first Tune waits for done context
+	tuned   int
+	closed  int
+}
+
+func (b *fakeBackend) DeliverySystems() []dvb.DeliverySystem {
+	return []dvb.DeliverySystem{dvb.SysDVBT}
+}
+
+func (b *fakeBackend) Tune(ctx context.Context, m Mux) error {
+	if b.tuned++; b.tuned == 1 && b.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return b.err
+}
+
+func (b *fakeBackend) OpenStream(pids ...int16) (io.ReadCloser, error) {
+	return fakeStream{b}, nil
+}
+
+func (b *fakeBackend) Idle() error {
+	return b.idleErr
+}
+
+func (b *fakeBackend) DemuxDevice() string {
+	return b.demux
+}
+
+type fakeStream struct {
+	b *fakeBackend
+}
+
+func (s fakeStream) Read(buf []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (s fakeStream) Close() error {
+	s.b.closed++
+	return nil
+}
+
+func TestPoolRetune(t *testing.T) {
+	b0 := &fakeBackend{err: ErrNoSignal}
+	b1 := &fakeBackend{}
+	p := NewPool()
+	p.Add(0, b0)
+	p.Add(1, b1)
+	l, err := p.Lease(context.Background(), &testMux{474e6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Adapter() != 1 || b0.tuned != 1 || b1.tuned != 1 {
+		t.Fatal("other free tuner not tried", l.Adapter(), b0.tuned, b1.tuned)
+	}
+	b1.err = errors.New("no lock")
+	if _, err := p.Lease(context.Background(), &testMux{482e6}); err != ErrNoSignal {
+		t.Fatal("expected ErrNoSignal, got", err)
+	}
+	l.Release()
+	if _, err := p.Lease(context.Background(), &testMux{482e6}); err != b1.err {
+		t.Fatal("expected last error, got", err)
+	}
+}
+
+func TestLeaseReleaseStreams(t *testing.T) {
+	b := &fakeBackend{}
+	p := NewPool()
+	p.Add(0, b)
+	p.SetFilterLimit(0, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	l, err := p.Lease(ctx, &testMux{474e6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s1, err := l.OpenStream(0x100, 0x101)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := l.OpenStream(0x102)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2.Close()
+	cancel()
+	select {
+	case <-l.Done():
+	case <-time.After(time.Second):
+		t.Fatal("lease not released on cancel")
+	}
+	if b.closed != 2 {
+		t.Fatal("closed", b.closed, "streams, expected 2")
+	}
+	if err := s1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if b.closed != 2 {
+		t.Fatal("stream closed twice")
+	}
+	l, err = p.Lease(context.Background(), &testMux{474e6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.OpenStream(1, 2, 3); err != nil {
+		t.Fatal("filters not freed:", err)
+	}
+}
+
+func TestPoolSharedDemux(t *testing.T) {
+	p := NewPool()
+	p.Add(0, &fakeBackend{demux: "demux0"})
+	p.Add(0, &fakeBackend{demux: "demux0"})
+	p.Add(1, &fakeBackend{})
+	l1, err := p.Lease(context.Background(), &testMux{474e6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l2, err := p.Lease(context.Background(), &testMux{482e6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l2.Adapter() != 1 {
+		t.Fatal("tuner that shares busy demux leased")
+	}
+	if _, err := p.Lease(context.Background(), &testMux{490e6}); err != ErrNoTuner {
+		t.Fatal("expected ErrNoTuner, got", err)
+	}
+	l1.Release()
+	l2.Release()
+	l, err := p.Lease(context.Background(), &testMux{490e6})
+	if err != nil || l.Adapter() != 0 {
+		t.Fatal("can't lease tuner of free demux:", err)
+	}
+}
+
+func TestPoolTakeOverTuning(t *testing.T) {
+	b := &fakeBackend{block: true}
+	p := NewPool()
+	p.Add(0, b)
+	ctx, cancel := context.WithCancel(context.Background())
+	waitRefs := func(n int) {
+		for {
+			p.mu.Lock()
+			refs := p.tuners[0].refs
+			p.mu.Unlock()
+			if refs == n {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	owner := make(chan error, 1)
+	go func() {
+		_, err := p.Lease(ctx, &testMux{474e6})
+		owner <- err
+	}()
+	waitRefs(1)
+	waiter := make(chan error, 1)
+	go func() {
+		_, err := p.Lease(context.Background(), &testMux{474e6})
+		waiter <- err
+	}()
+	waitRefs(2)
+	cancel()
+	if err := <-owner; err != context.Canceled {
+		t.Fatal("owner: expected context.Canceled, got", err)
+	}
+	if err := <-waiter; err != nil {
+		t.Fatal("waiter:", err)
+	}
+	if b.tuned != 2 {
+		t.Fatal("tuning not taken over")
+	}
+}
+
+func TestLeaseReleaseError(t *testing.T) {
+	b := &fakeBackend{idleErr: errors.New("can't close")}
+	p := NewPool()
+	p.Add(0, b)
+	l, err := p.Lease(context.Background(), &testMux{474e6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Release(); err != b.idleErr {
+		t.Fatal("expected Idle error, got", err)
+	}
+	if err := l.Release(); err != b.idleErr {
+		t.Fatal("second Release:", err)
+	}
+}
@@ -0,0 +1,98 @@
+package tuner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+
+	"github.com/ziutek/dvb"
+	"github.com/ziutek/dvb/ts"
+)
+
+var ErrNoSignal = errors.New("no signal")
+
+// ReplayMux maps Mux to file that contains its transport stream.
+type ReplayMux struct {
+	Mux  Mux
+	Path string
+}
+
+// Replay is a Backend that replays recorded TS files instead of receiving
+// signal. It can stand in for real tuners in tests.
+type Replay struct {
+	Systems []dvb.DeliverySystem
+	Muxes   []ReplayMux
+
+	path string
+}
+
+func (r *Replay) DeliverySystems() []dvb.DeliverySystem {
+	return r.Systems
+}
+
+// Tune selects file for m. It returns ErrNoSignal if there is no file for m.
+func (r *Replay) Tune(ctx context.Context, m Mux) error {
+	for _, rm := range r.Muxes {
+		if reflect.DeepEqual(rm.Mux, m) {
+			r.path = rm.Path
+			return nil
+		}
+	}
+	return ErrNoSignal
+}
+
+// OpenStream opens selected file and returns stream that contains only
+// packets of pids (filtered in software). Stream returns io.EOF at the end
+// of file.
+func (r *Replay) OpenStream(pids ...int16) (io.ReadCloser, error) {
+	if len(pids) == 0 {
+		return nil, ErrNoPid
+	}
+	f, err := os.Open(r.path)
+	if err != nil {
+		return nil, err
+	}
+	s := &replayStream{f: f, r: ts.NewPktStreamReader(f)}
+	for _, pid := range pids {
+		s.pids[uint16(pid)&0x1fff] = true
+	}
+	return s, nil
+}
+
+func (r *Replay) Idle() error {
+	r.path = ""
+	return nil
+}
+
+type replayStream struct {
+	f    *os.File
+	r    *ts.PktStreamReader
+	pkt  ts.ArrayPkt
+	rest []byte // unread part of pkt
+	pids [8192]bool
+}
+
+func (s *replayStream) Read(buf []byte) (int, error) {
+	n := copy(buf, s.rest)
+	s.rest = s.rest[n:]
+	for n < len(buf) {
+		if err := s.r.ReadPkt(&s.pkt); err != nil {
+			if n > 0 && err == io.EOF {
+				break
+			}
+			return n, err
+		}
+		if s.pids[s.pkt.Pid()] {
+			k := copy(buf[n:], s.pkt[:])
+			s.rest = s.pkt[k:]
+			n += k
+		}
+	}
+	return n, nil
+}
+
+func (s *replayStream) Close() error {
+	return s.f.Close()
+}
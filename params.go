@@ -44,10 +44,11 @@ var dsn = []string{
 	"DAB",
 	"DVB-T2",
 	"TURBO",
+	"DVB-C Annex C",
 }
 
 func (ds DeliverySystem) String() string {
-	if ds > DeliverySystem(len(dsn)) {
+	if ds >= DeliverySystem(len(dsn)) {
 		return "unknown"
 	}
 	return dsn[ds]
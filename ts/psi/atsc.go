@@ -0,0 +1,622 @@
+package psi
+
+import (
+	"time"
+	"unicode/utf16"
+
+	"github.com/ziutek/dvb"
+)
+
+// ATSC A/65 Program and System Information Protocol tables. All PSIP tables
+// (except the PSIP-only extended tables) are carried on PSIPPid. First byte
+// of data of every PSIP section is protocol_version.
+
+const PSIPPid = 0x1ffb
+
+const (
+	MGTTableId     = 0xc7
+	TVCTTableId    = 0xc8
+	CVCTTableId    = 0xc9
+	RRTTableId     = 0xca
+	ATSCEITTableId = 0xcb
+	ETTTableId     = 0xcc
+	STTTableId     = 0xcd
+)
+
+var (
+	ErrPSIPSectionSyntax = dvb.TemporaryError("incorrect PSIP section syntax")
+	ErrPSIPSectionLen    = dvb.TemporaryError("incorrect PSIP section length")
+	ErrPSIPTableEmpty    = dvb.TemporaryError("empty PSIP table")
+)
+
+// GPSEpoch is a beginning of GPS time (1980-01-06 00:00:00 UTC) used by
+// ATSC.
+var GPSEpoch = time.Date(1980, 1, 6, 0, 0, 0, 0, time.UTC)
+
+// GPSTime converts GPS seconds to UTC time using GPS_UTC_offset (leap
+// seconds) from STT.
+func GPSTime(sec uint32, utcOffset byte) time.Time {
+	return GPSEpoch.Add(time.Duration(int64(sec)-int64(utcOffset)) * time.Second)
+}
+
+// updatePSIP reads table and checks minimal length of its sections.
+func updatePSIP(t *Table, r SectionReader, tableId byte, minLen int) error {
+	if err := t.Update(r, tableId, true, true, SectionMaxLen); err != nil {
+		return err
+	}
+	for _, s := range *t {
+		if len(s.Data()) < minLen {
+			t.Reset()
+			return ErrPSIPSectionLen
+		}
+	}
+	return nil
+}
+
+// psipLoop iterates over count prefixed loop of elements in all sections of
+// PSIP table. Elements start at data offset head, count is a byte at data
+// offset 1 (or 16-bit word if wide is true).
+type psipLoop struct {
+	tc   TableCursor
+	n    int
+	head int
+	wide bool
+}
+
+func (l psipLoop) IsEmpty() bool {
+	return l.n == 0 && len(l.tc.Tab) == 0
+}
+
+// pop returns next element (its length is returned by elen) or nil.
+func (l psipLoop) pop(elen func([]byte) int) ([]byte, psipLoop) {
+	for l.n == 0 {
+		if len(l.tc.Tab) == 0 {
+			return nil, l
+		}
+		l.tc = l.tc.NextSection()
+		d := l.tc.Data
+		if len(d) < l.head {
+			return nil, psipLoop{}
+		}
+		if l.wide {
+			l.n = int(decodeU16(d[1:3]))
+		} else {
+			l.n = int(d[1])
+		}
+		l.tc.Data = d[l.head:]
+	}
+	d := l.tc.Data
+	n := elen(d)
+	if n < 0 || n > len(d) {
+		return nil, psipLoop{}
+	}
+	l.tc.Data = d[n:]
+	l.n--
+	return d[:n], l
+}
+
+// psipDescriptors returns descriptors that follow loop in every section of
+// t. lenMask is a mask of descriptors length field.
+func psipDescriptors(t Table, head int, wide bool, elen func([]byte) int, lenMask uint16) DescriptorList {
+	var dl DescriptorList
+	for _, s := range t {
+		l := psipLoop{tc: TableCursor{Tab: Table{s}}, head: head, wide: wide}
+		var e []byte
+		for {
+			if e, l = l.pop(elen); e == nil {
+				break
+			}
+		}
+		if len(l.tc.Tab) != 0 || len(l.tc.Data) < 2 {
+			continue
+		}
+		n := int(decodeU16(l.tc.Data) & lenMask)
+		if d := l.tc.Data[2:]; n <= len(d) {
+			dl = append(dl, d[:n]...)
+		}
+	}
+	return dl
+}
+
+// descrLen returns length of element that has fixed part of length n with
+// descriptor loop length field (masked by mask) at its end.
+func descrLen(b []byte, n int, mask uint16) int {
+	if len(b) < n {
+		return -1
+	}
+	return n + int(decodeU16(b[n-2:n])&mask)
+}
+
+// MGT represents Master Guide Table.
+type MGT Table
+
+// Update reads next MGT from r.
+func (mgt *MGT) Update(r SectionReader) error {
+	return updatePSIP((*Table)(mgt), r, MGTTableId, 3)
+}
+
+func (mgt MGT) Version() int8 {
+	return Table(mgt).Version()
+}
+
+// ProtocolVersion returns protocol_version of mgt.
+func (mgt MGT) ProtocolVersion() (byte, error) {
+	if len(mgt) == 0 {
+		return 0, ErrPSIPTableEmpty
+	}
+	d := mgt[0].Data()
+	if len(d) < 1 {
+		return 0, ErrPSIPSectionSyntax
+	}
+	return d[0], nil
+}
+
+func mgtTableLen(b []byte) int {
+	return descrLen(b, 11, 0x0fff)
+}
+
+// Tables returns list of tables described by mgt.
+func (mgt MGT) Tables() MGTTableList {
+	return MGTTableList{psipLoop{tc: Table(mgt).Cursor(), head: 3, wide: true}}
+}
+
+// Descriptors returns MGT descriptors.
+func (mgt MGT) Descriptors() DescriptorList {
+	return psipDescriptors(Table(mgt), 3, true, mgtTableLen, 0x0fff)
+}
+
+type MGTTableList struct {
+	psipLoop
+}
+
+// Pop returns first MGTTable from tl. If there is no more data to read Pop
+// returns empty MGTTableList. If an error occurs it returns nil MGTTable.
+func (tl MGTTableList) Pop() (MGTTable, MGTTableList) {
+	e, l := tl.pop(mgtTableLen)
+	return MGTTable(e), MGTTableList{l}
+}
+
+// MGTTableType describes type of table listed in MGT.
+type MGTTableType uint16
+
+const (
+	TVCTCurrent MGTTableType = 0x0000
+	TVCTNext    MGTTableType = 0x0001
+	CVCTCurrent MGTTableType = 0x0002
+	CVCTNext    MGTTableType = 0x0003
+	ChannelETT  MGTTableType = 0x0004
+	DCCSCT      MGTTableType = 0x0005
+	EIT0        MGTTableType = 0x0100 // EIT-0 ... EIT-127
+	EventETT0   MGTTableType = 0x0200 // event ETT-0 ... event ETT-127
+	RRTRegion1  MGTTableType = 0x0301 // RRT rating_region 1 ... 255
+	DCCTId0     MGTTableType = 0x1400 // DCCT dcc_id 0x00 ... 0xff
+)
+
+// EIT returns n and true if t is EIT-n.
+func (t MGTTableType) EIT() (int, bool) {
+	if t >= EIT0 && t < EIT0+128 {
+		return int(t - EIT0), true
+	}
+	return 0, false
+}
+
+// EventETT returns n and true if t is event ETT-n.
+func (t MGTTableType) EventETT() (int, bool) {
+	if t >= EventETT0 && t < EventETT0+128 {
+		return int(t - EventETT0), true
+	}
+	return 0, false
+}
+
+// MGTTable describes one table listed in MGT.
+type MGTTable []byte
+
+func (t MGTTable) Type() MGTTableType {
+	return MGTTableType(decodeU16(t[0:2]))
+}
+
+func (t MGTTable) Pid() int16 {
+	return int16(decodeU16(t[2:4]) & 0x1fff)
+}
+
+func (t MGTTable) Version() int8 {
+	return int8(t[4] & 0x1f)
+}
+
+// NumBytes returns total length of table sections.
+func (t MGTTable) NumBytes() uint32 {
+	return decodeU32(t[5:9])
+}
+
+func (t MGTTable) Descriptors() DescriptorList {
+	return DescriptorList(t[11:])
+}
+
+// VCT represents Terrestrial (TVCT) or Cable (CVCT) Virtual Channel Table.
+type VCT Table
+
+// Update reads next VCT from r. If cable is true it reads CVCT.
+func (vct *VCT) Update(r SectionReader, cable bool) error {
+	tableId := byte(TVCTTableId)
+	if cable {
+		tableId = CVCTTableId
+	}
+	return updatePSIP((*Table)(vct), r, tableId, 2)
+}
+
+func (vct VCT) Version() int8 {
+	return Table(vct).Version()
+}
+
+// Cable returns true if vct is CVCT.
+func (vct VCT) Cable() bool {
+	return Table(vct).TableId() == CVCTTableId
+}
+
+func (vct VCT) MuxId() uint16 {
+	return Table(vct).TableIdExt()
+}
+
+func channelLen(b []byte) int {
+	return descrLen(b, 32, 0x03ff)
+}
+
+// Channels returns list of virtual channels.
+func (vct VCT) Channels() ChannelList {
+	return ChannelList{psipLoop{tc: Table(vct).Cursor(), head: 2}}
+}
+
+// Descriptors returns additional descriptors.
+func (vct VCT) Descriptors() DescriptorList {
+	return psipDescriptors(Table(vct), 2, false, channelLen, 0x03ff)
+}
+
+type ChannelList struct {
+	psipLoop
+}
+
+// Pop returns first Channel from cl. If there is no more data to read Pop
+// returns empty ChannelList. If an error occurs it returns nil Channel.
+func (cl ChannelList) Pop() (Channel, ChannelList) {
+	e, l := cl.pop(channelLen)
+	return Channel(e), ChannelList{l}
+}
+
+// ATSCServiceType is a value of service_type field of virtual channel.
+type ATSCServiceType byte
+
+const (
+	ATSCAnalogTV  ATSCServiceType = 0x01
+	ATSCDigitalTV ATSCServiceType = 0x02
+	ATSCAudio     ATSCServiceType = 0x03
+	ATSCData      ATSCServiceType = 0x04
+	ATSCSoftware  ATSCServiceType = 0x05
+)
+
+// Channel describes virtual channel.
+type Channel []byte
+
+// ShortName returns channel name (up to 7 characters).
+func (c Channel) ShortName() string {
+	u := make([]uint16, 0, 7)
+	for i := 0; i < 14; i += 2 {
+		v := decodeU16(c[i:])
+		if v == 0 {
+			break
+		}
+		u = append(u, v)
+	}
+	return string(utf16.Decode(u))
+}
+
+func (c Channel) Major() int {
+	return int(c[14]&0x0f)<<6 | int(c[15]>>2)
+}
+
+func (c Channel) Minor() int {
+	return int(c[15]&0x03)<<8 | int(c[16])
+}
+
+func (c Channel) ModulationMode() byte {
+	return c[17]
+}
+
+// CarrierFrequency returns carrier_frequency field (deprecated, usually 0).
+func (c Channel) CarrierFrequency() uint32 {
+	return decodeU32(c[18:22])
+}
+
+// MuxId returns channel_TSID.
+func (c Channel) MuxId() uint16 {
+	return decodeU16(c[22:24])
+}
+
+func (c Channel) ProgramNumber() uint16 {
+	return decodeU16(c[24:26])
+}
+
+func (c Channel) ETMLocation() byte {
+	return c[26] >> 6
+}
+
+func (c Channel) AccessControlled() bool {
+	return c[26]&0x20 != 0
+}
+
+func (c Channel) Hidden() bool {
+	return c[26]&0x10 != 0
+}
+
+// PathSelect is valid only for CVCT.
+func (c Channel) PathSelect() bool {
+	return c[26]&0x08 != 0
+}
+
+// OutOfBand is valid only for CVCT.
+func (c Channel) OutOfBand() bool {
+	return c[26]&0x04 != 0
+}
+
+func (c Channel) HideGuide() bool {
+	return c[26]&0x02 != 0
+}
+
+func (c Channel) ServiceType() ATSCServiceType {
+	return ATSCServiceType(c[27] & 0x3f)
+}
+
+func (c Channel) SourceId() uint16 {
+	return decodeU16(c[28:30])
+}
+
+func (c Channel) Descriptors() DescriptorList {
+	return DescriptorList(c[32:])
+}
+
+// STT represents System Time Table.
+type STT Section
+
+// ParseSTT checks s and returns it as STT.
+func ParseSTT(s Section) (STT, error) {
+	if s.TableId() != STTTableId || !s.GenericSyntax() {
+		return nil, ErrPSIPSectionSyntax
+	}
+	if l := s.Len(); l == -1 || l > len(s) || l < 3+5+8+4 {
+		return nil, ErrPSIPSectionLen
+	}
+	if !s.CheckCRC() {
+		return nil, ErrSectionCRC
+	}
+	return STT(s), nil
+}
+
+func (stt STT) data() []byte {
+	return Section(stt).Data()
+}
+
+// SystemTime returns number of GPS seconds since GPSEpoch.
+func (stt STT) SystemTime() uint32 {
+	return decodeU32(stt.data()[1:5])
+}
+
+// GPSUTCOffset returns current offset (leap seconds) between GPS and UTC.
+func (stt STT) GPSUTCOffset() byte {
+	return stt.data()[5]
+}
+
+// Time returns system time as UTC time.
+func (stt STT) Time() time.Time {
+	return GPSTime(stt.SystemTime(), stt.GPSUTCOffset())
+}
+
+// DaylightSaving returns daylight_saving fields: status, day of month and
+// hour of transition (day == 0 if there is no transition in current month).
+func (stt STT) DaylightSaving() (ds bool, day, hour int) {
+	d := stt.data()
+	return d[6]&0x80 != 0, int(d[6] & 0x1f), int(d[7])
+}
+
+func (stt STT) Descriptors() DescriptorList {
+	return DescriptorList(stt.data()[8:])
+}
+
+// ATSCEIT represents ATSC Event Information Table (EIT-n) of one virtual
+// channel.
+type ATSCEIT Table
+
+// Update reads next EIT from r.
+func (eit *ATSCEIT) Update(r SectionReader) error {
+	return updatePSIP((*Table)(eit), r, ATSCEITTableId, 2)
+}
+
+func (eit ATSCEIT) Version() int8 {
+	return Table(eit).Version()
+}
+
+func (eit ATSCEIT) SourceId() uint16 {
+	return Table(eit).TableIdExt()
+}
+
+func atscEventLen(b []byte) int {
+	if len(b) < 10 {
+		return -1
+	}
+	return descrLen(b, 10+int(b[9])+2, 0x0fff)
+}
+
+// Events returns list of events.
+func (eit ATSCEIT) Events() ATSCEventList {
+	return ATSCEventList{psipLoop{tc: Table(eit).Cursor(), head: 2}}
+}
+
+type ATSCEventList struct {
+	psipLoop
+}
+
+// Pop returns first ATSCEvent from el. If there is no more data to read Pop
+// returns empty ATSCEventList. If an error occurs it returns nil ATSCEvent.
+func (el ATSCEventList) Pop() (ATSCEvent, ATSCEventList) {
+	e, l := el.pop(atscEventLen)
+	return ATSCEvent(e), ATSCEventList{l}
+}
+
+// ATSCEvent describes one event from ATSC EIT.
+type ATSCEvent []byte
+
+func (e ATSCEvent) EventId() uint16 {
+	return decodeU16(e[0:2]) & 0x3fff
+}
+
+// StartTime returns start time in GPS seconds. Use GPSTime to convert it to
+// UTC time.
+func (e ATSCEvent) StartTime() uint32 {
+	return decodeU32(e[2:6])
+}
+
+func (e ATSCEvent) ETMLocation() byte {
+	return e[6] >> 4 & 3
+}
+
+func (e ATSCEvent) Duration() time.Duration {
+	return time.Duration(decodeU24(e[6:9])&0xfffff) * time.Second
+}
+
+func (e ATSCEvent) Title() MultipleString {
+	return MultipleString(e[10 : 10+int(e[9])])
+}
+
+func (e ATSCEvent) Descriptors() DescriptorList {
+	return DescriptorList(e[10+int(e[9])+2:])
+}
+
+// ETT represents Extended Text Table.
+type ETT Section
+
+// ParseETT checks s and returns it as ETT.
+func ParseETT(s Section) (ETT, error) {
+	if s.TableId() != ETTTableId || !s.GenericSyntax() {
+		return nil, ErrPSIPSectionSyntax
+	}
+	if l := s.Len(); l == -1 || l > len(s) || l < 3+5+5+4 {
+		return nil, ErrPSIPSectionLen
+	}
+	if !s.CheckCRC() {
+		return nil, ErrSectionCRC
+	}
+	return ETT(s), nil
+}
+
+// ETMId returns ETM_id. See SourceId, EventId.
+func (ett ETT) ETMId() uint32 {
+	return decodeU32(Section(ett).Data()[1:5])
+}
+
+// SourceId returns source_id of channel the text refers to.
+func (ett ETT) SourceId() uint16 {
+	return uint16(ett.ETMId() >> 16)
+}
+
+// EventId returns event_id and true if text describes event or false if it
+// describes channel.
+func (ett ETT) EventId() (uint16, bool) {
+	id := ett.ETMId()
+	return uint16(id>>2) & 0x3fff, id&3 == 2
+}
+
+func (ett ETT) Text() MultipleString {
+	return MultipleString(Section(ett).Data()[5:])
+}
+
+// RRT represents Rating Region Table.
+type RRT Table
+
+// Update reads next RRT from r.
+func (rrt *RRT) Update(r SectionReader) error {
+	return updatePSIP((*Table)(rrt), r, RRTTableId, 2)
+}
+
+func (rrt RRT) Version() int8 {
+	return Table(rrt).Version()
+}
+
+// Region returns rating_region.
+func (rrt RRT) Region() byte {
+	return byte(Table(rrt).TableIdExt())
+}
+
+// RatingValue describes one value of rating dimension.
+type RatingValue struct {
+	Abbrev MultipleString
+	Text   MultipleString
+}
+
+// RatingDimension describes one dimension of rating region.
+type RatingDimension struct {
+	Name      MultipleString
+	Graduated bool
+	Values    []RatingValue
+}
+
+// RatingRegion contains content of RRT.
+type RatingRegion struct {
+	Name        MultipleString
+	Dimensions  []RatingDimension
+	Descriptors DescriptorList
+}
+
+// popMS returns multiple string prefixed by its length.
+func popMS(b []byte) (MultipleString, []byte, bool) {
+	if len(b) < 1 || len(b) < 1+int(b[0]) {
+		return nil, nil, false
+	}
+	return MultipleString(b[1 : 1+int(b[0])]), b[1+int(b[0]):], true
+}
+
+// Decode decodes content of first section of rrt (RRT always fits in one
+// section).
+func (rrt RRT) Decode() (*RatingRegion, error) {
+	var (
+		rr RatingRegion
+		ok bool
+	)
+	if len(rrt) == 0 {
+		return nil, ErrPSIPTableEmpty
+	}
+	b := rrt[0].Data()
+	if len(b) < 1 {
+		return nil, ErrPSIPSectionSyntax
+	}
+	b = b[1:]
+	if rr.Name, b, ok = popMS(b); !ok || len(b) < 1 {
+		return nil, ErrPSIPSectionSyntax
+	}
+	rr.Dimensions = make([]RatingDimension, b[0])
+	b = b[1:]
+	for i := range rr.Dimensions {
+		dim := &rr.Dimensions[i]
+		if dim.Name, b, ok = popMS(b); !ok || len(b) < 1 {
+			return nil, ErrPSIPSectionSyntax
+		}
+		dim.Graduated = b[0]&0x10 != 0
+		dim.Values = make([]RatingValue, b[0]&0x0f)
+		b = b[1:]
+		for k := range dim.Values {
+			v := &dim.Values[k]
+			if v.Abbrev, b, ok = popMS(b); !ok {
+				return nil, ErrPSIPSectionSyntax
+			}
+			if v.Text, b, ok = popMS(b); !ok {
+				return nil, ErrPSIPSectionSyntax
+			}
+		}
+	}
+	if len(b) < 2 {
+		return nil, ErrPSIPSectionSyntax
+	}
+	n := int(decodeU16(b) & 0x03ff)
+	if len(b) < 2+n {
+		return nil, ErrPSIPSectionSyntax
+	}
+	rr.Descriptors = DescriptorList(b[2 : 2+n])
+	return &rr, nil
+}
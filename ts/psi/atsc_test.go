@@ -0,0 +1,120 @@
+package psi
+
+import (
+	"testing"
+	"time"
+)
+
+func makePSIPSection(tableId byte, ext uint16, data []byte) Section {
+	s := MakeEmptySection(SectionMaxLen, true)
+	s.SetTableId(tableId)
+	s.SetPrivateSyntax(true)
+	s.SetTableIdExt(ext)
+	s.SetVersion(3)
+	s.SetCurrent(true)
+	s.SetNumber(0)
+	s.SetLastNumber(0)
+	copy(s.Alloc(1+len(data), 0)[1:], data) // protocol_version = 0
+	s.MakeCRC()
+	return s
+}
+
+func TestVCT(t *testing.T) {
+	ch := make([]byte, 32)
+	for i, c := range "KABC-HD" {
+		ch[i*2+1] = byte(c)
+	}
+	ch[14], ch[15], ch[16] = 0xf0, 7<<2, 1 // 7.1
+	ch[26], ch[27] = 0x0e, 0xc2
+	encodeU16(ch[28:30], 0x55)
+	sl := []byte{0xa1, 9, 0xe1, 0x00, 1, 0x02, 0xe1, 0x01, 'e', 'n', 'g'}
+	ch[30], ch[31] = 0xfc, byte(len(sl))
+	data := append([]byte{1}, ch...)
+	data = append(data, sl...)
+	data = append(data, 0xfc, 0x00)
+	vct := VCT{makePSIPSection(TVCTTableId, 0x1234, data)}
+	c, cl := vct.Channels().Pop()
+	if c == nil || !cl.IsEmpty() {
+		t.Fatal("bad channel list")
+	}
+	if c.ShortName() != "KABC-HD" || c.Major() != 7 || c.Minor() != 1 ||
+		c.ServiceType() != ATSCDigitalTV || c.SourceId() != 0x55 ||
+		c.Hidden() || !c.HideGuide() {
+		t.Fatalf("bad channel: %s %d.%d", c.ShortName(), c.Major(), c.Minor())
+	}
+	d, _ := c.Descriptors().Pop()
+	sld, ok := ParseServiceLocationDescriptor(d)
+	if !ok || sld.PCRPid() != 0x100 {
+		t.Fatal("bad service_location_descriptor")
+	}
+	es := sld.Elements()
+	if len(es) != 1 || es[0].Pid != 0x101 || es[0].Lang != 'e'<<16|'n'<<8|'g' {
+		t.Fatalf("bad elements: %+v", es)
+	}
+	if len(vct.Descriptors()) != 0 {
+		t.Fatal("unexpected additional descriptors")
+	}
+}
+
+func TestATSCEvent(t *testing.T) {
+	title := MakeMultipleString(
+		LangString{Lang: 'e'<<16 | 'n'<<8 | 'g', Text: "News"},
+		LangString{Lang: 's'<<16 | 'p'<<8 | 'a', Text: "Noticias ñ €"},
+	)
+	ev := []byte{0xc0, 0x07, 0x4b, 0x00, 0x00, 0x00, 0xd0, 0x0e, 0x10}
+	ev = append(ev, byte(len(title)))
+	ev = append(ev, title...)
+	ev = append(ev, 0xf0, 0x00)
+	data := append([]byte{1}, ev...)
+	eit := ATSCEIT{makePSIPSection(ATSCEITTableId, 0x55, data)}
+	e, _ := eit.Events().Pop()
+	if e == nil || e.EventId() != 7 || e.Duration() != time.Hour ||
+		e.ETMLocation() != 1 {
+		t.Fatal("bad event")
+	}
+	start := GPSTime(e.StartTime(), 18)
+	if !start.Equal(GPSEpoch.Add((0x4b000000 - 18) * time.Second)) {
+		t.Fatal("bad start time:", start)
+	}
+	s, err := e.Title().Text('s'<<16 | 'p'<<8 | 'a')
+	if err != nil || s != "Noticias ñ €" {
+		t.Fatalf("bad title: %q %v", s, err)
+	}
+}
+
+func TestSTT(t *testing.T) {
+	stt, err := ParseSTT(makePSIPSection(
+		STTTableId, 0, []byte{0x4b, 0x00, 0x00, 0x00, 18, 0x80, 0x00},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stt.GPSUTCOffset() != 18 || !stt.Time().Equal(GPSTime(0x4b000000, 18)) {
+		t.Fatal("bad STT")
+	}
+	if ds, day, _ := stt.DaylightSaving(); !ds || day != 0 {
+		t.Fatal("bad daylight_saving")
+	}
+}
+
+func TestHuffman(t *testing.T) {
+	table := make([]byte, 256+4)
+	for i := 0; i < 128; i++ {
+		encodeU16(table[i*2:], 256)
+	}
+	// 'a' = 0, end = 10, escape = 11
+	copy(table[256:], []byte{0x80 | 'a', 1, 0x80, 0x80 | huffmanEsc})
+	s, err := DecodeHuffman(table, []byte{0x3e, 0x98})
+	if err != nil || s != "aaé" {
+		t.Fatalf("%q %v", s, err)
+	}
+}
+
+func TestPSIPEmptyTable(t *testing.T) {
+	if _, err := (MGT{}).ProtocolVersion(); err != ErrPSIPTableEmpty {
+		t.Error("MGT.ProtocolVersion:", err)
+	}
+	if _, err := (RRT{}).Decode(); err != ErrPSIPTableEmpty {
+		t.Error("RRT.Decode:", err)
+	}
+}
@@ -0,0 +1,191 @@
+package psi
+
+// ATSC A/65 descriptors.
+
+const (
+	ATSCAC3AudioTag        DescriptorTag = 0x81
+	CaptionServiceTag      DescriptorTag = 0x86
+	ContentAdvisoryTag     DescriptorTag = 0x87
+	ExtendedChannelNameTag DescriptorTag = 0xa0
+	ServiceLocationTag     DescriptorTag = 0xa1
+)
+
+// ATSCAC3Descriptor represents fixed part of ATSC A/52 AC-3 audio stream
+// descriptor.
+type ATSCAC3Descriptor struct {
+	SampleRateCode byte // 3 bits
+	BSID           byte // 5 bits
+	BitRateCode    byte // 6 bits
+	SurroundMode   byte // 2 bits
+	BSMod          byte // 3 bits
+	NumChannels    byte // 4 bits
+	FullSvc        bool
+	Lang           byte // langcod (0 if not present)
+}
+
+func ParseATSCAC3Descriptor(d Descriptor) (ad ATSCAC3Descriptor, ok bool) {
+	if d.Tag() != ATSCAC3AudioTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 3 {
+		return
+	}
+	ad.SampleRateCode = data[0] >> 5
+	ad.BSID = data[0] & 0x1f
+	ad.BitRateCode = data[1] >> 2
+	ad.SurroundMode = data[1] & 0x03
+	ad.BSMod = data[2] >> 5
+	ad.NumChannels = data[2] >> 1 & 0x0f
+	ad.FullSvc = data[2]&0x01 != 0
+	if len(data) > 3 {
+		ad.Lang = data[3]
+	}
+	ok = true
+	return
+}
+
+// CaptionService describes one closed caption service.
+type CaptionService struct {
+	Lang          ISO639LangCode
+	Digital       bool // CEA-708 (true) or line 21 (false) service
+	ServiceNumber byte // caption_service_number if Digital
+	Line21Field   bool // line21_field if !Digital
+	EasyReader    bool
+	WideAspect    bool
+}
+
+type CaptionServiceDescriptor []byte
+
+func ParseCaptionServiceDescriptor(d Descriptor) (csd CaptionServiceDescriptor, ok bool) {
+	if d.Tag() != CaptionServiceTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 1 {
+		return
+	}
+	return CaptionServiceDescriptor(data[1:]), true
+}
+
+// Pop returns first CaptionService from d. Remaining services are returned in
+// rd. If there is no more services to read len(rd) == 0. If an error occurs
+// rd = nil.
+func (d CaptionServiceDescriptor) Pop() (cs CaptionService, rd CaptionServiceDescriptor) {
+	if len(d) < 6 {
+		return
+	}
+	cs.Lang = ISO639LangCode(decodeU24(d[0:3]))
+	cs.Digital = d[3]&0x80 != 0
+	if cs.Digital {
+		cs.ServiceNumber = d[3] & 0x3f
+	} else {
+		cs.Line21Field = d[3]&0x01 != 0
+	}
+	cs.EasyReader = d[4]&0x80 != 0
+	cs.WideAspect = d[4]&0x40 != 0
+	rd = d[6:]
+	return
+}
+
+// RatedDimension is a rating value in one dimension of rating region.
+type RatedDimension struct {
+	Dimension byte
+	Value     byte // 4 bits
+}
+
+// RegionRating is a content advisory for one rating region.
+type RegionRating struct {
+	Region      byte
+	Dimensions  []RatedDimension
+	Description MultipleString
+}
+
+type ContentAdvisoryDescriptor []byte
+
+func ParseContentAdvisoryDescriptor(d Descriptor) (cad ContentAdvisoryDescriptor, ok bool) {
+	if d.Tag() != ContentAdvisoryTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 1 {
+		return
+	}
+	return ContentAdvisoryDescriptor(data[1:]), true
+}
+
+// Pop returns first RegionRating from d. Remaining ratings are returned in rd.
+// If there is no more ratings to read len(rd) == 0. If an error occurs
+// rd = nil.
+func (d ContentAdvisoryDescriptor) Pop() (rr RegionRating, rd ContentAdvisoryDescriptor) {
+	if len(d) < 2 {
+		return
+	}
+	rr.Region = d[0]
+	n := int(d[1])
+	b := d[2:]
+	if len(b) < n*2+1 {
+		return
+	}
+	rr.Dimensions = make([]RatedDimension, n)
+	for i := range rr.Dimensions {
+		rr.Dimensions[i] = RatedDimension{b[0], b[1] & 0x0f}
+		b = b[2:]
+	}
+	var ok bool
+	if rr.Description, b, ok = popMS(b); !ok {
+		return
+	}
+	rd = b
+	return
+}
+
+// ParseExtendedChannelNameDescriptor returns long channel name.
+func ParseExtendedChannelNameDescriptor(d Descriptor) (name MultipleString, ok bool) {
+	if d.Tag() != ExtendedChannelNameTag {
+		return
+	}
+	return MultipleString(d.Data()), true
+}
+
+// ServiceLocationElement describes one elementary stream of virtual channel.
+type ServiceLocationElement struct {
+	Type StreamType
+	Pid  int16
+	Lang ISO639LangCode
+}
+
+type ServiceLocationDescriptor []byte
+
+func ParseServiceLocationDescriptor(d Descriptor) (sld ServiceLocationDescriptor, ok bool) {
+	if d.Tag() != ServiceLocationTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 3 {
+		return
+	}
+	return ServiceLocationDescriptor(data), true
+}
+
+// PCRPid returns PCR_PID.
+func (d ServiceLocationDescriptor) PCRPid() int16 {
+	return int16(decodeU16(d[0:2]) & 0x1fff)
+}
+
+// Elements returns elementary streams. It returns nil if d is too short.
+func (d ServiceLocationDescriptor) Elements() []ServiceLocationElement {
+	n := int(d[2])
+	b := d[3:]
+	if len(b) < n*6 {
+		return nil
+	}
+	es := make([]ServiceLocationElement, n)
+	for i := range es {
+		es[i].Type = StreamType(b[0])
+		es[i].Pid = int16(decodeU16(b[1:3]) & 0x1fff)
+		es[i].Lang = ISO639LangCode(decodeU24(b[3:6]))
+		b = b[6:]
+	}
+	return es
+}
@@ -0,0 +1,223 @@
+package psi
+
+import (
+	"errors"
+	"unicode/utf16"
+)
+
+var (
+	ErrMultipleString = errors.New("incorrect multiple_string_structure")
+	ErrATSCTextMode   = errors.New("unsupported ATSC text mode")
+	ErrHuffmanTable   = errors.New("no ATSC Huffman decode table")
+	ErrHuffmanData    = errors.New("incorrect Huffman compressed string")
+)
+
+// ATSC A/65 Annex C decode tables for compression_type 1 (program titles)
+// and 2 (program descriptions). Tables use A/65 Table C.4/C.5 layout: 128
+// big-endian 16-bit offsets of decode trees (one tree for every prior
+// character), followed by trees. Every tree node is a pair of bytes (branch
+// for bit 0, branch for bit 1); a byte with MSB set is a leaf that contains
+// 7-bit character, other bytes are indexes of next node in the tree.
+//
+// Tables are not included in this package. Set them before decoding strings
+// that use compression.
+var (
+	HuffmanTitleTable       []byte
+	HuffmanDescriptionTable []byte
+)
+
+const (
+	huffmanEnd = 0x00 // string terminate character
+	huffmanEsc = 0x1b // next 8 bits are uncompressed character
+)
+
+// DecodeHuffman decodes ATSC A/65 Annex C Huffman compressed string using
+// table (HuffmanTitleTable or HuffmanDescriptionTable).
+func DecodeHuffman(table, data []byte) (string, error) {
+	if len(table) < 256 {
+		return "", ErrHuffmanTable
+	}
+	var (
+		out   []byte
+		bit   uint
+		prior byte
+	)
+	nbits := uint(len(data)) * 8
+	next := func() (int, bool) {
+		if bit >= nbits {
+			return 0, false
+		}
+		b := int(data[bit/8]>>(7-bit%8)) & 1
+		bit++
+		return b, true
+	}
+	for {
+		root := int(decodeU16(table[int(prior)*2:]))
+		node := 0
+		var c byte
+		for {
+			b, ok := next()
+			if !ok {
+				return string(out), nil // no string terminate character
+			}
+			i := root + node*2 + b
+			if i >= len(table) {
+				return "", ErrHuffmanData
+			}
+			n := table[i]
+			if n&0x80 != 0 {
+				c = n & 0x7f
+				break
+			}
+			node = int(n)
+		}
+		switch c {
+		case huffmanEnd:
+			return string(out), nil
+		case huffmanEsc:
+			c = 0
+			for i := 0; i < 8; i++ {
+				b, ok := next()
+				if !ok {
+					return "", ErrHuffmanData
+				}
+				c = c<<1 | byte(b)
+			}
+			out = append(out, string(rune(c))...)
+		default:
+			out = append(out, c)
+		}
+		prior = c & 0x7f
+	}
+}
+
+// MultipleString represents ATSC multiple_string_structure.
+type MultipleString []byte
+
+// LangString is a string in one language.
+type LangString struct {
+	Lang ISO639LangCode
+	Text string
+}
+
+// Strings decodes all strings from ms.
+func (ms MultipleString) Strings() ([]LangString, error) {
+	if len(ms) == 0 {
+		return nil, nil
+	}
+	n := int(ms[0])
+	b := []byte(ms[1:])
+	ls := make([]LangString, n)
+	for i := range ls {
+		if len(b) < 4 {
+			return nil, ErrMultipleString
+		}
+		ls[i].Lang = ISO639LangCode(decodeU24(b))
+		segs := int(b[3])
+		b = b[4:]
+		for k := 0; k < segs; k++ {
+			if len(b) < 3 || len(b) < 3+int(b[2]) {
+				return nil, ErrMultipleString
+			}
+			s, err := decodeSegment(b[0], b[1], b[3:3+int(b[2])])
+			if err != nil {
+				return nil, err
+			}
+			ls[i].Text += s
+			b = b[3+int(b[2]):]
+		}
+	}
+	return ls, nil
+}
+
+// Text returns string in language lang or first string if there is no string
+// in lang.
+func (ms MultipleString) Text(lang ISO639LangCode) (string, error) {
+	ls, err := ms.Strings()
+	if err != nil || len(ls) == 0 {
+		return "", err
+	}
+	for _, s := range ls {
+		if s.Lang == lang {
+			return s.Text, nil
+		}
+	}
+	return ls[0].Text, nil
+}
+
+func decodeSegment(compression, mode byte, b []byte) (string, error) {
+	switch compression {
+	case 0:
+	case 1, 2:
+		if mode != 0xff {
+			return "", ErrATSCTextMode
+		}
+		table := HuffmanTitleTable
+		if compression == 2 {
+			table = HuffmanDescriptionTable
+		}
+		return DecodeHuffman(table, b)
+	default:
+		return "", ErrATSCTextMode
+	}
+	if mode == 0x3f { // UTF-16
+		u := make([]uint16, len(b)/2)
+		for i := range u {
+			u[i] = decodeU16(b[i*2:])
+		}
+		return string(utf16.Decode(u)), nil
+	}
+	if !bmpPage(mode) {
+		return "", ErrATSCTextMode
+	}
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(mode)<<8 | rune(c)
+	}
+	return string(r), nil
+}
+
+// bmpPage reports whether mode selects 256 character page of Unicode BMP.
+func bmpPage(mode byte) bool {
+	return mode <= 0x06 || mode >= 0x09 && mode <= 0x10 ||
+		mode >= 0x20 && mode <= 0x27 || mode >= 0x30 && mode <= 0x33
+}
+
+// MakeMultipleString returns multiple_string_structure that contains
+// uncompressed strings ls. Characters outside of Unicode BMP are replaced by
+// U+FFFD, characters from pages that can't be selected by mode are encoded
+// using UTF-16 mode. It returns nil if ls can't be encoded.
+func MakeMultipleString(ls ...LangString) MultipleString {
+	if len(ls) > 255 {
+		return nil
+	}
+	ms := MultipleString{byte(len(ls))}
+	for _, s := range ls {
+		ms = append(ms, byte(s.Lang>>16), byte(s.Lang>>8), byte(s.Lang))
+		var segs [][]byte
+		for _, r := range s.Text {
+			if r > 0xffff {
+				r = 0xfffd
+			}
+			mode, c := byte(r>>8), []byte{byte(r)}
+			if !bmpPage(mode) {
+				mode, c = 0x3f, []byte{byte(r >> 8), byte(r)}
+			}
+			k := len(segs) - 1
+			if k < 0 || segs[k][0] != mode || len(segs[k])+len(c) > 1+255 {
+				segs = append(segs, []byte{mode})
+				k++
+			}
+			segs[k] = append(segs[k], c...)
+		}
+		if len(segs) > 255 {
+			return nil
+		}
+		ms = append(ms, byte(len(segs)))
+		for _, seg := range segs {
+			ms = append(ms, 0, seg[0], byte(len(seg)-1))
+			ms = append(ms, seg[1:]...)
+		}
+	}
+	return ms
+}
@@ -0,0 +1,432 @@
+package psi
+
+// optField is an optional one byte field signaled by flag bit.
+type optField struct {
+	has *bool
+	v   *byte // nil if flag has no field
+}
+
+// decodeOptFields decodes fields fs signaled by flags (first field by MSB).
+func decodeOptFields(data []byte, flags byte, fs []optField) ([]byte, bool) {
+	for i, f := range fs {
+		if flags&(0x80>>uint(i)) == 0 {
+			continue
+		}
+		*f.has = true
+		if f.v == nil {
+			continue
+		}
+		if len(data) == 0 {
+			return nil, false
+		}
+		*f.v = data[0]
+		data = data[1:]
+	}
+	return data, true
+}
+
+func encodeOptFields(fs []optField) (flags byte, vals []byte) {
+	for i, f := range fs {
+		if !*f.has {
+			continue
+		}
+		flags |= 0x80 >> uint(i)
+		if f.v != nil {
+			vals = append(vals, *f.v)
+		}
+	}
+	return
+}
+
+// AC3Descriptor represents DVB AC-3_descriptor (EN 300 468 annex D).
+type AC3Descriptor struct {
+	HasComponentType bool
+	ComponentType    byte
+	HasBSID          bool
+	BSID             byte
+	HasMainId        bool
+	MainId           byte
+	HasASVC          bool
+	ASVC             byte
+	AdditionalInfo   []byte
+}
+
+func (ad *AC3Descriptor) fields() []optField {
+	return []optField{
+		{&ad.HasComponentType, &ad.ComponentType},
+		{&ad.HasBSID, &ad.BSID},
+		{&ad.HasMainId, &ad.MainId},
+		{&ad.HasASVC, &ad.ASVC},
+	}
+}
+
+func ParseAC3Descriptor(d Descriptor) (ad AC3Descriptor, ok bool) {
+	if d.Tag() != AC3Tag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 1 {
+		return
+	}
+	if data, ok = decodeOptFields(data[1:], data[0], ad.fields()); ok {
+		ad.AdditionalInfo = data
+	}
+	return
+}
+
+func (ad AC3Descriptor) MakeDescriptor() Descriptor {
+	flags, vals := encodeOptFields(ad.fields())
+	d := MakeDescriptor(AC3Tag, 1+len(vals)+len(ad.AdditionalInfo))
+	data := d.Data()
+	data[0] = flags | 0x0f
+	copy(data[1+copy(data[1:], vals):], ad.AdditionalInfo)
+	return d
+}
+
+// EAC3Descriptor represents DVB enhanced_AC-3_descriptor (EN 300 468 annex D).
+type EAC3Descriptor struct {
+	HasComponentType bool
+	ComponentType    byte
+	HasBSID          bool
+	BSID             byte
+	HasMainId        bool
+	MainId           byte
+	HasASVC          bool
+	ASVC             byte
+	MixInfoExists    bool
+	HasSubstream     [3]bool
+	Substream        [3]byte
+	AdditionalInfo   []byte
+}
+
+func (ed *EAC3Descriptor) fields() []optField {
+	return []optField{
+		{&ed.HasComponentType, &ed.ComponentType},
+		{&ed.HasBSID, &ed.BSID},
+		{&ed.HasMainId, &ed.MainId},
+		{&ed.HasASVC, &ed.ASVC},
+		{&ed.MixInfoExists, nil},
+		{&ed.HasSubstream[0], &ed.Substream[0]},
+		{&ed.HasSubstream[1], &ed.Substream[1]},
+		{&ed.HasSubstream[2], &ed.Substream[2]},
+	}
+}
+
+func ParseEAC3Descriptor(d Descriptor) (ed EAC3Descriptor, ok bool) {
+	if d.Tag() != EnhancedAC3Tag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 1 {
+		return
+	}
+	if data, ok = decodeOptFields(data[1:], data[0], ed.fields()); ok {
+		ed.AdditionalInfo = data
+	}
+	return
+}
+
+func (ed EAC3Descriptor) MakeDescriptor() Descriptor {
+	flags, vals := encodeOptFields(ed.fields())
+	d := MakeDescriptor(EnhancedAC3Tag, 1+len(vals)+len(ed.AdditionalInfo))
+	data := d.Data()
+	data[0] = flags
+	copy(data[1+copy(data[1:], vals):], ed.AdditionalInfo)
+	return d
+}
+
+// DTSDescriptor represents DVB DTS_audio_stream_descriptor (EN 300 468
+// annex G).
+type DTSDescriptor struct {
+	SampleRateCode   byte   // 4 bits
+	BitRateCode      byte   // 6 bits
+	NBlks            byte   // 7 bits
+	FSize            uint16 // 14 bits
+	SurroundMode     byte   // 6 bits
+	LFE              bool
+	ExtendedSurround byte // 2 bits
+	AdditionalInfo   []byte
+}
+
+func ParseDTSDescriptor(d Descriptor) (dd DTSDescriptor, ok bool) {
+	if d.Tag() != DTSTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 5 {
+		return
+	}
+	v := uint64(decodeU32(data[0:4]))<<8 | uint64(data[4])
+	dd.SampleRateCode = byte(v>>36) & 0x0f
+	dd.BitRateCode = byte(v>>30) & 0x3f
+	dd.NBlks = byte(v>>23) & 0x7f
+	dd.FSize = uint16(v>>9) & 0x3fff
+	dd.SurroundMode = byte(v>>3) & 0x3f
+	dd.LFE = v&0x04 != 0
+	dd.ExtendedSurround = byte(v) & 0x03
+	dd.AdditionalInfo = data[5:]
+	ok = true
+	return
+}
+
+func (dd DTSDescriptor) MakeDescriptor() Descriptor {
+	d := MakeDescriptor(DTSTag, 5+len(dd.AdditionalInfo))
+	data := d.Data()
+	v := uint64(dd.SampleRateCode&0x0f)<<36 |
+		uint64(dd.BitRateCode&0x3f)<<30 |
+		uint64(dd.NBlks&0x7f)<<23 |
+		uint64(dd.FSize&0x3fff)<<9 |
+		uint64(dd.SurroundMode&0x3f)<<3 |
+		uint64(dd.ExtendedSurround&0x03)
+	if dd.LFE {
+		v |= 0x04
+	}
+	encodeU32(data[0:4], uint32(v>>8))
+	data[4] = byte(v)
+	copy(data[5:], dd.AdditionalInfo)
+	return d
+}
+
+// AACDescriptor represents DVB AAC_descriptor (EN 300 468 annex H).
+type AACDescriptor struct {
+	ProfileAndLevel byte
+	SAOCDE          bool
+	HasType         bool
+	Type            byte // AAC_type (component_type for stream_content 0x6)
+	AdditionalInfo  []byte
+}
+
+func ParseAACDescriptor(d Descriptor) (ad AACDescriptor, ok bool) {
+	if d.Tag() != AACTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 1 {
+		return
+	}
+	ad.ProfileAndLevel = data[0]
+	if len(data) > 1 {
+		ad.HasType = data[1]&0x80 != 0
+		ad.SAOCDE = data[1]&0x40 != 0
+		data = data[2:]
+		if ad.HasType {
+			if len(data) < 1 {
+				return
+			}
+			ad.Type = data[0]
+			data = data[1:]
+		}
+		ad.AdditionalInfo = data
+	}
+	ok = true
+	return
+}
+
+func (ad AACDescriptor) MakeDescriptor() Descriptor {
+	if !ad.HasType && !ad.SAOCDE && len(ad.AdditionalInfo) == 0 {
+		d := MakeDescriptor(AACTag, 1)
+		d.Data()[0] = ad.ProfileAndLevel
+		return d
+	}
+	n := 2
+	if ad.HasType {
+		n++
+	}
+	d := MakeDescriptor(AACTag, n+len(ad.AdditionalInfo))
+	data := d.Data()
+	data[0] = ad.ProfileAndLevel
+	data[1] = 0x3f
+	if ad.HasType {
+		data[1] |= 0x80
+		data[2] = ad.Type
+	}
+	if ad.SAOCDE {
+		data[1] |= 0x40
+	}
+	copy(data[n:], ad.AdditionalInfo)
+	return d
+}
+
+// Editorial classification of supplementary audio.
+const (
+	EditorialMainAudio        = 0x00
+	EditorialAudioDescription = 0x01 // for the visually impaired
+	EditorialCleanAudio       = 0x02 // for the hearing impaired
+	EditorialSpokenSubtitles  = 0x03 // for the visually impaired
+)
+
+// SupplementaryAudioDescriptor represents DVB supplementary_audio_descriptor
+// (extension descriptor).
+type SupplementaryAudioDescriptor struct {
+	// MixType is true for complete and independent stream, false for
+	// supplementary stream that need to be mixed with main audio.
+	MixType                 bool
+	EditorialClassification byte           // 5 bits
+	Lang                    ISO639LangCode // 0 if not present
+	PrivateData             []byte
+}
+
+func ParseSupplementaryAudioDescriptor(d Descriptor) (sad SupplementaryAudioDescriptor, ok bool) {
+	if tag, _ := d.ExtTag(); tag != SupplementaryAudioExtTag {
+		return
+	}
+	data := d.ExtData()
+	if len(data) < 1 {
+		return
+	}
+	sad.MixType = data[0]&0x80 != 0
+	sad.EditorialClassification = data[0] >> 2 & 0x1f
+	if data[0]&0x01 != 0 {
+		if len(data) < 4 {
+			return
+		}
+		sad.Lang = ISO639LangCode(decodeU24(data[1:4]))
+		data = data[3:]
+	}
+	sad.PrivateData = data[1:]
+	ok = true
+	return
+}
+
+func (sad SupplementaryAudioDescriptor) MakeDescriptor() Descriptor {
+	n := 1
+	if sad.Lang != 0 {
+		n += 3
+	}
+	d := MakeExtDescriptor(SupplementaryAudioExtTag, n+len(sad.PrivateData))
+	data := d.ExtData()
+	data[0] = sad.EditorialClassification<<2 | 0x02
+	if sad.MixType {
+		data[0] |= 0x80
+	}
+	if sad.Lang != 0 {
+		data[0] |= 0x01
+		data[1] = byte(sad.Lang >> 16)
+		data[2] = byte(sad.Lang >> 8)
+		data[3] = byte(sad.Lang)
+	}
+	copy(data[n:], sad.PrivateData)
+	return d
+}
+
+// Codec is a coding format of elementary stream.
+type Codec byte
+
+const (
+	UnknownCodec Codec = iota
+	MPEG1VideoCodec
+	MPEG2VideoCodec
+	MPEG4VideoCodec
+	H264Codec
+	H265Codec
+	MPEG1AudioCodec
+	MPEG2AudioCodec
+	AACCodec     // ADTS
+	LATMAACCodec // MPEG-4 audio in LATM/LOAS
+	AC3Codec
+	EAC3Codec
+	DTSCodec
+	DVBSubtitlesCodec
+	TeletextCodec
+)
+
+var codecNames = []string{
+	"unknown",
+	"MPEG-1 video",
+	"MPEG-2 video",
+	"MPEG-4 video",
+	"H.264",
+	"H.265",
+	"MPEG-1 audio",
+	"MPEG-2 audio",
+	"AAC",
+	"AAC LATM",
+	"AC-3",
+	"E-AC-3",
+	"DTS",
+	"DVB subtitles",
+	"Teletext",
+}
+
+func (c Codec) String() string {
+	if int(c) >= len(codecNames) {
+		return "unknown"
+	}
+	return codecNames[c]
+}
+
+// ATSC (A/53, A/52) stream types.
+const (
+	atscAC3Audio  StreamType = 0x81
+	atscEAC3Audio StreamType = 0x87
+)
+
+// Codec returns coding format of elementary stream. Private PES streams
+// (stream_type 0x06) are classified using DVB descriptors and registration
+// descriptor.
+func (i ESInfo) Codec() Codec {
+	switch i.Type() {
+	case MPEG1Video:
+		return MPEG1VideoCodec
+	case MPEG2Video:
+		return MPEG2VideoCodec
+	case MPEG4Video:
+		return MPEG4VideoCodec
+	case H264Video:
+		return H264Codec
+	case H265Video:
+		return H265Codec
+	case MPEG1Audio:
+		return MPEG1AudioCodec
+	case MPEG2Audio:
+		return MPEG2AudioCodec
+	case AAC:
+		return AACCodec
+	case MPEG4Audio:
+		return LATMAACCodec
+	case atscAC3Audio:
+		return AC3Codec
+	case atscEAC3Audio:
+		return EAC3Codec
+	case PrivPES:
+	default:
+		return UnknownCodec
+	}
+	dl := i.Descriptors()
+	for len(dl) != 0 {
+		var d Descriptor
+		if d, dl = dl.Pop(); d == nil {
+			break
+		}
+		switch d.Tag() {
+		case AC3Tag:
+			return AC3Codec
+		case EnhancedAC3Tag:
+			return EAC3Codec
+		case DTSTag:
+			return DTSCodec
+		case AACTag:
+			return AACCodec
+		case SubtitlingTag:
+			return DVBSubtitlesCodec
+		case TeletextTag, VBITeletextTag:
+			return TeletextCodec
+		case RegistrationTag:
+			rd, ok := ParseRegistrationDescriptor(d)
+			if !ok {
+				break
+			}
+			switch rd.Format() {
+			case "AC-3":
+				return AC3Codec
+			case "EAC3":
+				return EAC3Codec
+			case "DTS1", "DTS2", "DTS3":
+				return DTSCodec
+			case "HEVC":
+				return H265Codec
+			}
+		}
+	}
+	return UnknownCodec
+}
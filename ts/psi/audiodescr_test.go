@@ -0,0 +1,94 @@
+package psi_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ziutek/dvb/ts/psi"
+)
+
+func TestAudioDescriptors(t *testing.T) {
+	ac3 := psi.AC3Descriptor{
+		HasBSID: true, BSID: 8, HasASVC: true, ASVC: 3,
+		AdditionalInfo: []byte{1, 2},
+	}
+	if r, ok := psi.ParseAC3Descriptor(ac3.MakeDescriptor()); !ok || !reflect.DeepEqual(r, ac3) {
+		t.Errorf("AC-3: %+v", r)
+	}
+	eac3 := psi.EAC3Descriptor{
+		HasComponentType: true, ComponentType: 0xc2, MixInfoExists: true,
+		HasSubstream: [3]bool{false, true, false}, Substream: [3]byte{0, 5, 0},
+		AdditionalInfo: []byte{},
+	}
+	if r, ok := psi.ParseEAC3Descriptor(eac3.MakeDescriptor()); !ok || !reflect.DeepEqual(r, eac3) {
+		t.Errorf("E-AC-3: %+v", r)
+	}
+	dts := psi.DTSDescriptor{
+		SampleRateCode: 13, BitRateCode: 15, NBlks: 15, FSize: 2012,
+		SurroundMode: 9, LFE: true, ExtendedSurround: 2,
+		AdditionalInfo: []byte{},
+	}
+	if r, ok := psi.ParseDTSDescriptor(dts.MakeDescriptor()); !ok || !reflect.DeepEqual(r, dts) {
+		t.Errorf("DTS: %+v", r)
+	}
+	aac := psi.AACDescriptor{
+		ProfileAndLevel: 0x58, HasType: true, Type: 0x43,
+		AdditionalInfo: []byte{},
+	}
+	if r, ok := psi.ParseAACDescriptor(aac.MakeDescriptor()); !ok || !reflect.DeepEqual(r, aac) {
+		t.Errorf("AAC: %+v", r)
+	}
+	sad := psi.SupplementaryAudioDescriptor{
+		EditorialClassification: psi.EditorialAudioDescription,
+		Lang:                    0x706f6c,
+		PrivateData:             []byte{7},
+	}
+	d := sad.MakeDescriptor()
+	if tag, ok := d.ExtTag(); !ok || tag != psi.SupplementaryAudioExtTag {
+		t.Fatal("bad extension tag:", tag)
+	}
+	if r, ok := psi.ParseSupplementaryAudioDescriptor(d); !ok || !reflect.DeepEqual(r, sad) {
+		t.Errorf("supplementary audio: %+v", r)
+	}
+}
+
+func TestESInfoCodec(t *testing.T) {
+	es := func(typ psi.StreamType, d psi.Descriptor) psi.ESInfo {
+		i := psi.ESInfo{byte(typ), 0xe1, 0x00, 0xf0, byte(len(d))}
+		return append(i, d...)
+	}
+	cases := []struct {
+		i psi.ESInfo
+		c psi.Codec
+	}{
+		{es(psi.H264Video, nil), psi.H264Codec},
+		{es(psi.PrivPES, psi.EAC3Descriptor{}.MakeDescriptor()), psi.EAC3Codec},
+		{es(psi.PrivPES, psi.Descriptor{byte(psi.RegistrationTag), 4, 'A', 'C', '-', '3'}), psi.AC3Codec},
+		{es(psi.PrivPES, psi.MakeDescriptor(psi.ISO639LangTag, 4)), psi.UnknownCodec},
+		{es(0x81, nil), psi.AC3Codec},
+	}
+	for _, c := range cases {
+		if codec := c.i.Codec(); codec != c.c {
+			t.Errorf("%v != %v", codec, c.c)
+		}
+	}
+}
+
+func TestAC3Wire(t *testing.T) {
+	ac3 := psi.AC3Descriptor{
+		HasComponentType: true, ComponentType: 0x42,
+		HasBSID: true, BSID: 8, HasASVC: true, ASVC: 3,
+		AdditionalInfo: []byte{0xee},
+	}
+	checkWire(t, "AC-3", ac3.MakeDescriptor(), []byte{
+		0x6a, 0x05, 0xdf, 0x42, 0x08, 0x03, 0xee,
+	}, psi.ParseAC3Descriptor, ac3)
+	eac3 := psi.EAC3Descriptor{
+		HasComponentType: true, ComponentType: 0x84, MixInfoExists: true,
+		HasSubstream: [3]bool{false, true, false}, Substream: [3]byte{0, 0x11, 0},
+		AdditionalInfo: []byte{},
+	}
+	checkWire(t, "E-AC-3", eac3.MakeDescriptor(), []byte{
+		0x7a, 0x03, 0x8a, 0x84, 0x11,
+	}, psi.ParseEAC3Descriptor, eac3)
+}
@@ -0,0 +1,62 @@
+package psi
+
+// CAT represents conditional access table.
+type CAT Table
+
+func (cat CAT) Version() int8 {
+	return Table(cat).Version()
+}
+
+func (cat CAT) Current() bool {
+	return Table(cat).Current()
+}
+
+// Update reads next CAT from r.
+func (cat *CAT) Update(r SectionReader, current bool) error {
+	return (*Table)(cat).Update(r, 1, false, current, ISOSectionMaxLen)
+}
+
+// Descriptors returns list of descriptors from all sections of CAT.
+func (cat CAT) Descriptors() DescriptorList {
+	var dl DescriptorList
+	for _, s := range cat {
+		dl = append(dl, s.Data()...)
+	}
+	return dl
+}
+
+// CADescriptors returns list of CA descriptors from CAT.
+func (cat CAT) CADescriptors() []CADescriptor {
+	var cads []CADescriptor
+	dl := cat.Descriptors()
+	for len(dl) != 0 {
+		var d Descriptor
+		d, dl = dl.Pop()
+		if d == nil {
+			break
+		}
+		if cad, ok := ParseCADescriptor(d); ok {
+			cads = append(cads, cad)
+		}
+	}
+	return cads
+}
+
+// MakeCAT creates one section CAT that contains descriptors from dl. MakeCAT
+// calculates CRC sum.
+func MakeCAT(version int8, dl DescriptorList) CAT {
+	s := MakeEmptySection(ISOSectionMaxLen, true)
+	s.SetTableId(1)
+	s.SetTableIdExt(0xffff)
+	s.SetVersion(version)
+	s.SetCurrent(true)
+	s.SetNumber(0)
+	s.SetLastNumber(0)
+	buf := s.Alloc(len(dl), 0)
+	if buf == nil {
+		panic("psi: descriptors don't fit in CAT section")
+	}
+	copy(buf, dl)
+	s.MakeCRC()
+	return CAT{s[:s.Len()]}
+}
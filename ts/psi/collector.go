@@ -0,0 +1,155 @@
+package psi
+
+import (
+	"context"
+
+	"github.com/ziutek/dvb"
+	"github.com/ziutek/dvb/ts"
+)
+
+type subTable struct {
+	version  int8
+	secs     []Section // indexed by section_number
+	n        int       // number of collected sections
+	returned bool
+}
+
+func (st *subTable) reset(version int8, last byte) {
+	st.version = version
+	st.secs = make([]Section, int(last)+1)
+	st.n = 0
+	st.returned = false
+}
+
+// TableCollector assembles complete tables from sections read from
+// SectionReader. Tables that differ in table_id_extension (eg. SDT other or
+// EIT of different services) are collected independently. Every table
+// version is returned once.
+type TableCollector struct {
+	r       SectionReader
+	tableId byte
+	current bool
+	buf     Section
+	subs    map[uint16]*subTable
+}
+
+// NewTableCollector returns TableCollector that collects tables with tableId
+// from r. If current is false it collects next (not yet applicable) tables.
+func NewTableCollector(r SectionReader, tableId byte, current bool) *TableCollector {
+	return &TableCollector{
+		r:       r,
+		tableId: tableId,
+		current: current,
+		buf:     make(Section, SectionMaxLen),
+		subs:    make(map[uint16]*subTable),
+	}
+}
+
+type pidReplacer struct {
+	r   ts.PktReader
+	pid int16
+}
+
+func (r pidReplacer) ReplacePkt(p *ts.ArrayPkt) (*ts.ArrayPkt, error) {
+	for {
+		if err := r.r.ReadPkt(p); err != nil {
+			return p, err
+		}
+		if p.Pid() == r.pid {
+			return p, nil
+		}
+	}
+}
+
+// NewPktTableCollector returns TableCollector that decodes sections from
+// packets with pid read from r.
+func NewPktTableCollector(r ts.PktReader, pid int16, tableId byte, current bool) *TableCollector {
+	d := NewSectionDecoder(pidReplacer{r, pid}, true)
+	return NewTableCollector(d, tableId, current)
+}
+
+// Reset forgets all collected sections and returned versions.
+func (c *TableCollector) Reset() {
+	c.subs = make(map[uint16]*subTable)
+}
+
+// Collect reads sections until it collects complete table in version that
+// wasn't returned before. Sections in returned table are ordered by
+// section_number. If r returns error Collect returns it. Errors of
+// dvb.TemporaryError type don't break collecting, so Collect can be called
+// again.
+func (c *TableCollector) Collect() (Table, error) {
+	s := c.buf
+	for {
+		if err := c.r.ReadSection(s); err != nil {
+			return nil, err
+		}
+		if s.TableId() != c.tableId {
+			continue
+		}
+		if !s.GenericSyntax() {
+			return nil, ErrTableSyntax
+		}
+		if s.Current() != c.current {
+			continue
+		}
+		ext := s.TableIdExt()
+		st := c.subs[ext]
+		if st == nil {
+			st = new(subTable)
+			st.reset(s.Version(), s.LastNumber())
+			c.subs[ext] = st
+		} else if st.version != s.Version() ||
+			len(st.secs) != int(s.LastNumber())+1 {
+			// New version. Provider can also change table content without
+			// changing version, but we can't detect this reliably.
+			st.reset(s.Version(), s.LastNumber())
+		}
+		if st.returned {
+			continue
+		}
+		n := int(s.Number())
+		if n >= len(st.secs) {
+			return nil, ErrTableSectionNumber
+		}
+		if st.secs[n] != nil {
+			continue
+		}
+		sec := make(Section, s.Len())
+		sec.Copy(s)
+		st.secs[n] = sec
+		st.n++
+		if st.n == len(st.secs) {
+			st.returned = true
+			return Table(st.secs), nil
+		}
+	}
+}
+
+// Run collects tables and sends them to out until ctx is done or r returns
+// error that isn't of dvb.TemporaryError type. Tables can be converted to
+// specific types (PAT, SDT, NIT, ...). Run checks ctx only between read
+// sections, so use SectionReader that can be interrupted (eg. close the
+// source of data) if the stream can stall.
+func (c *TableCollector) Run(ctx context.Context, out chan<- Table) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		t, err := c.Collect()
+		if err != nil {
+			if _, ok := err.(dvb.TemporaryError); ok {
+				continue
+			}
+			if cerr := ctx.Err(); cerr != nil {
+				return cerr
+			}
+			return err
+		}
+		select {
+		case out <- t:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package psi_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/ziutek/dvb/ts"
+	"github.com/ziutek/dvb/ts/psi"
+)
+
+func TestTableCollector(t *testing.T) {
+	var buf bytes.Buffer
+	e := psi.NewSectionEncoder(ts.PktStreamWriter{W: &buf}, 0x11)
+	other := psi.NewSectionEncoder(ts.PktStreamWriter{W: &buf}, 0x12)
+	write := func(e *psi.SectionEncoder, id byte, version int8, n, last byte) {
+		s := psi.MakeEmptySection(psi.ISOSectionMaxLen, true)
+		s.SetTableId(id)
+		s.SetPrivateSyntax(true)
+		s.SetTableIdExt(7)
+		s.SetVersion(version)
+		s.SetCurrent(true)
+		s.SetNumber(n)
+		s.SetLastNumber(last)
+		s.Alloc(1, 0)[0] = n
+		s.MakeCRC()
+		if err := e.WriteSection(s); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(e, 0x42, 1, 1, 1)
+	write(other, 0x42, 5, 0, 0)
+	write(e, 0x46, 1, 0, 0)
+	write(e, 0x42, 1, 1, 1)
+	write(e, 0x42, 1, 0, 1)
+	write(e, 0x42, 1, 0, 1)
+	write(e, 0x42, 2, 0, 1)
+	write(e, 0x42, 2, 1, 1)
+
+	c := psi.NewPktTableCollector(ts.NewPktStreamReader(&buf), 0x11, 0x42, true)
+	for _, version := range []int8{1, 2} {
+		tab, err := c.Collect()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tab.Version() != version || len(tab) != 2 {
+			t.Fatalf("version=%d len=%d", tab.Version(), len(tab))
+		}
+		for i, s := range tab {
+			if s.Number() != byte(i) || s.Data()[0] != byte(i) {
+				t.Fatalf("bad section %d: %v", i, s)
+			}
+		}
+	}
+	if _, err := c.Collect(); err != io.EOF {
+		t.Fatal("expected EOF, got", err)
+	}
+}
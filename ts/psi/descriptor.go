@@ -95,11 +95,18 @@ const (
 	FmxBufferSizeTag   DescriptorTag = 0x23
 	MultiplexBufferTag DescriptorTag = 0x24
 
+	AVCVideoTag            DescriptorTag = 0x28 //PMT
+	AVCTimingHRDTag        DescriptorTag = 0x2a //PMT
+	MPEG4AudioExtensionTag DescriptorTag = 0x2e //PMT
+	HEVCVideoTag           DescriptorTag = 0x38 //PMT
+
 	NetworkNameTag               DescriptorTag = 0x40 //NIT
 	ServiceListTag               DescriptorTag = 0x41 //NIT
 	StuffingTag                  DescriptorTag = 0x42
 	SatelliteDeliverySystemTag   DescriptorTag = 0x43
 	CableDeliverySystemTag       DescriptorTag = 0x44
+	VBIDataTag                   DescriptorTag = 0x45
+	VBITeletextTag               DescriptorTag = 0x46
 	BouquetNameTag               DescriptorTag = 0x47
 	ServiceTag                   DescriptorTag = 0x48
 	CountryAvailabilityTag       DescriptorTag = 0x49
@@ -145,6 +152,7 @@ const (
 	EnhancedAC3Tag DescriptorTag = 0x7a //PMT
 	DTSTag         DescriptorTag = 0x7b
 	AACTag         DescriptorTag = 0x7c
+	ExtensionTag   DescriptorTag = 0x7f
 
 	LogicalChannelTag DescriptorTag = 0x83 //NIT
 )
@@ -178,11 +186,18 @@ var dtagstr = [...]string{
 	FmxBufferSizeTag:   "FmxBufferSize",
 	MultiplexBufferTag: "MultiplexBuffer",
 
+	AVCVideoTag:            "AVCVideo",
+	AVCTimingHRDTag:        "AVCTimingHRD",
+	MPEG4AudioExtensionTag: "MPEG4AudioExtension",
+	HEVCVideoTag:           "HEVCVideo",
+
 	NetworkNameTag:               "NetworkName",
 	ServiceListTag:               "ServiceList",
 	StuffingTag:                  "Stuffing",
 	SatelliteDeliverySystemTag:   "SatelliteDeliverySystem",
 	CableDeliverySystemTag:       "CableDeliverySystem",
+	VBIDataTag:                   "VBIData",
+	VBITeletextTag:               "VBITeletext",
 	BouquetNameTag:               "BouquetName",
 	ServiceTag:                   "Service",
 	CountryAvailabilityTag:       "CountryAvailability",
@@ -228,6 +243,7 @@ var dtagstr = [...]string{
 	EnhancedAC3Tag: "EnhancedAC3",
 	DTSTag:         "DTS",
 	AACTag:         "AAC",
+	ExtensionTag:   "Extension",
 
 	LogicalChannelTag: "LogicalChannel",
 }
@@ -238,3 +254,44 @@ func (tag DescriptorTag) String() string {
 	}
 	return dtagstr[tag]
 }
+
+// ExtDescriptorTag is a descriptor_tag_extension of DVB extension_descriptor.
+type ExtDescriptorTag byte
+
+const (
+	SupplementaryAudioExtTag ExtDescriptorTag = 0x06
+)
+
+var extdtagstr = [...]string{
+	SupplementaryAudioExtTag: "SupplementaryAudio",
+}
+
+func (tag ExtDescriptorTag) String() string {
+	if int(tag) >= len(extdtagstr) {
+		return ""
+	}
+	return extdtagstr[tag]
+}
+
+// MakeExtDescriptor returns extension_descriptor with descriptor_tag_extension
+// set to tag and room for datalen bytes of data (use ExtData to access them).
+func MakeExtDescriptor(tag ExtDescriptorTag, datalen int) Descriptor {
+	d := MakeDescriptor(ExtensionTag, 1+datalen)
+	d[2] = byte(tag)
+	return d
+}
+
+// ExtTag returns descriptor_tag_extension. ok == false if d isn't valid
+// extension_descriptor.
+func (d Descriptor) ExtTag() (tag ExtDescriptorTag, ok bool) {
+	if d.Tag() != ExtensionTag || d[1] < 1 {
+		return
+	}
+	return ExtDescriptorTag(d[2]), true
+}
+
+// ExtData returns data part of extension_descriptor (after
+// descriptor_tag_extension).
+func (d Descriptor) ExtData() []byte {
+	return d.Data()[1:]
+}
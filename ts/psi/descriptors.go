@@ -474,8 +474,9 @@ func (cas CAS) String() string {
 }
 
 type CADescriptor struct {
-	Sys CAS
-	Pid int16
+	Sys         CAS
+	Pid         int16
+	PrivateData []byte
 }
 
 func ParseCADescriptor(d Descriptor) (cad CADescriptor, ok bool) {
@@ -488,10 +489,21 @@ func ParseCADescriptor(d Descriptor) (cad CADescriptor, ok bool) {
 	}
 	cad.Sys = CAS(decodeU16(data[0:2]))
 	cad.Pid = int16(decodeU16(data[2:4]) & 0x1fff)
+	cad.PrivateData = data[4:]
 	ok = true
 	return
 }
 
+func (cad CADescriptor) MakeDescriptor() Descriptor {
+	checkPid(cad.Pid)
+	d := MakeDescriptor(CATag, 4+len(cad.PrivateData))
+	data := d.Data()
+	encodeU16(data[0:2], uint16(cad.Sys))
+	encodeU16(data[2:4], 0xe000|uint16(cad.Pid))
+	copy(data[4:], cad.PrivateData)
+	return d
+}
+
 type ISO639LangDescriptor []byte
 
 func ParseISO639LangDescriptor(d Descriptor) (ld ISO639LangDescriptor, ok bool) {
@@ -526,6 +538,11 @@ func (t AudioType) String() string {
 
 type ISO639LangCode uint32
 
+// String returns three letter language code.
+func (lc ISO639LangCode) String() string {
+	return string([]byte{byte(lc >> 16), byte(lc >> 8), byte(lc)})
+}
+
 // Pop returns first (lc, at) pair from d. Remaining pairs are returned in rd.
 // If there is no more pairs to read len(rd) == 0. If an error occurs rd = nil
 func (d ISO639LangDescriptor) Pop() (lc ISO639LangCode, at AudioType, rd ISO639LangDescriptor) {
@@ -604,8 +621,7 @@ func ParsePrivateDataSpecifier(d Descriptor) (pds uint32, ok bool) {
 	if len(data) != 4 {
 		return
 	}
-	pds = decodeU32(data)
-	return
+	return decodeU32(data), true
 }
 
 func MakePrivateDataSpecifierDescriptor(pds uint32) Descriptor {
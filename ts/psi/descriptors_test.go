@@ -0,0 +1,23 @@
+package psi_test
+
+import (
+	"testing"
+
+	"github.com/ziutek/dvb/ts/psi"
+)
+
+func TestPrivateDataSpecifier(t *testing.T) {
+	d := psi.MakePrivateDataSpecifierDescriptor(0x233a)
+	if pds, ok := psi.ParsePrivateDataSpecifier(d); !ok || pds != 0x233a {
+		t.Errorf("pds=%#x ok=%t", pds, ok)
+	}
+	if _, ok := psi.ParsePrivateDataSpecifier(
+		psi.MakeDescriptor(psi.PrivateDataSpecifierTag, 3),
+	); ok {
+		t.Error("accepted descriptor with bad length")
+	}
+	d = psi.MakeNetworkNameDescriptor("abcd")
+	if _, ok := psi.ParsePrivateDataSpecifier(d); ok {
+		t.Error("accepted descriptor with bad tag")
+	}
+}
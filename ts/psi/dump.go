@@ -0,0 +1,845 @@
+package psi
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ziutek/dvb"
+)
+
+var (
+	ErrDescriptorList = dvb.TemporaryError("incorrect descriptor list")
+	ErrSectionLoop    = dvb.TemporaryError("incorrect section loop")
+)
+
+// Field is a named value of Node. Value can be of any type. DumpText and
+// DumpJSON use reflection to render it.
+type Field struct {
+	Name  string
+	Value interface{}
+}
+
+// Node is a decoded section, descriptor or loop entry.
+type Node struct {
+	Name     string
+	Fields   []Field
+	Children []*Node
+}
+
+// Add appends field to n.
+func (n *Node) Add(name string, v interface{}) {
+	n.Fields = append(n.Fields, Field{name, v})
+}
+
+func (n *Node) fail(err interface{}, data []byte) {
+	n.Add("Error", fmt.Sprint(err))
+	if len(data) != 0 {
+		n.Add("Data", data)
+	}
+}
+
+// DecodeDescriptorNode decodes d using DecodeDescriptor. pds is the value of
+// private_data_specifier in scope of d. Descriptors without registered decoder
+// are returned as raw data.
+func DecodeDescriptorNode(d Descriptor, pds uint32) *Node {
+	n := &Node{Name: d.Tag().String()}
+	if n.Name == "" {
+		n.Name = fmt.Sprintf("Descriptor0x%02x", byte(d.Tag()))
+	}
+	n.Add("Tag", d.Tag())
+	n.Add("Len", len(d.Data()))
+	if ext, ok := d.ExtTag(); ok {
+		if s := ext.String(); s != "" {
+			n.Name = s
+		}
+		n.Add("ExtTag", ext)
+	}
+	if pds != 0 && pds != ATSCSpecifier && d.Tag() >= 0x80 {
+		n.Add("PrivateDataSpecifier", pds)
+	}
+	v, ok := DecodeDescriptor(d, pds)
+	if !ok {
+		n.Add("Data", d.Data())
+		return n
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Struct && rv.Type() != timeType {
+		addStructFields(n, rv)
+	} else {
+		n.Add("Value", v)
+	}
+	return n
+}
+
+func addStructFields(n *Node, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.PkgPath == "" {
+			n.Add(f.Name, v.Field(i).Interface())
+		}
+	}
+}
+
+// decodeDescriptors decodes descriptor loop. pds is the initial scope of
+// private descriptors (ATSCSpecifier for ATSC tables). It tracks
+// private_data_specifier_descriptors to select decoders of private
+// descriptors.
+func decodeDescriptors(n *Node, dl DescriptorList, pds uint32) {
+	for len(dl) != 0 {
+		d, rdl := dl.Pop()
+		if d == nil {
+			n.fail(ErrDescriptorList, dl)
+			return
+		}
+		n.Children = append(n.Children, DecodeDescriptorNode(d, pds))
+		if p, ok := ParsePrivateDataSpecifier(d); ok {
+			pds = p
+		}
+		dl = rdl
+	}
+}
+
+// popLoop returns descriptor loop of length encoded in 12 bits of b[0:2] and
+// remaining data.
+func popLoop(b []byte) (dl DescriptorList, rb []byte, ok bool) {
+	if len(b) < 2 {
+		return
+	}
+	l := loopLen(b)
+	if len(b) < 2+l {
+		return
+	}
+	return DescriptorList(b[2 : 2+l]), b[2+l:], true
+}
+
+// popEntry returns first loop entry from b. Entry consists of hl bytes of
+// header that ends with 12 bit descriptors_loop_length and descriptors.
+func popEntry(b []byte, hl int) (e, rb []byte, ok bool) {
+	if len(b) < hl {
+		return
+	}
+	l := hl + loopLen(b[hl-2:])
+	if len(b) < l {
+		return
+	}
+	return b[:l], b[l:], true
+}
+
+// decodeEntries decodes loop of entries that have hl bytes of header.
+func decodeEntries(n *Node, b []byte, hl int, pds uint32, decode func(e []byte) *Node) {
+	for len(b) != 0 {
+		e, rb, ok := popEntry(b, hl)
+		if !ok {
+			n.fail(ErrSectionLoop, b)
+			return
+		}
+		en := decode(e)
+		decodeDescriptors(en, DescriptorList(e[hl:]), pds)
+		n.Children = append(n.Children, en)
+		b = rb
+	}
+}
+
+// TableName returns the short name of table with tableId.
+func TableName(tableId byte) string {
+	switch {
+	case tableId >= 0x4e && tableId <= 0x6f:
+		return "EIT"
+	case int(tableId) < len(tableNames) && tableNames[tableId] != "":
+		return tableNames[tableId]
+	}
+	return fmt.Sprintf("Table0x%02x", tableId)
+}
+
+var tableNames = [...]string{
+	0x00: "PAT",
+	0x01: "CAT",
+	0x02: "PMT",
+	0x03: "TSDT",
+	0x40: "NIT",
+	0x41: "NIT",
+	0x42: "SDT",
+	0x46: "SDT",
+	0x4a: "BAT",
+	0x70: "TDT",
+	0x71: "RST",
+	0x72: "ST",
+	0x73: "TOT",
+	0x7e: "DIT",
+	0x7f: "SIT",
+
+	MPETableId:     "MPE",
+	MGTTableId:     "MGT",
+	TVCTTableId:    "TVCT",
+	CVCTTableId:    "CVCT",
+	RRTTableId:     "RRT",
+	ATSCEITTableId: "ATSCEIT",
+	ETTTableId:     "ETT",
+	STTTableId:     "STT",
+
+	SpliceInfoTableId: "SpliceInfo",
+}
+
+// DecodeSection decodes s into tree of nodes. It uses DecodeDescriptorNode to
+// decode all descriptor loops of known tables. Parts of s that can't be
+// decoded are returned as Error and Data fields.
+func DecodeSection(s Section) *Node {
+	n := &Node{Name: "Section"}
+	if len(s) < 3 || s.Len() < 3 || s.Len() > len(s) ||
+		s.GenericSyntax() && s.Len() < 3+5+4 {
+		n.fail(ErrSectionLength, s)
+		return n
+	}
+	n.Name = TableName(s.TableId())
+	n.Add("TableId", s.TableId())
+	n.Add("GenericSyntax", s.GenericSyntax())
+	n.Add("PrivateSyntax", s.PrivateSyntax())
+	n.Add("Len", s.Len())
+	if s.GenericSyntax() {
+		n.Add("TableIdExt", s.TableIdExt())
+		n.Add("Version", s.Version())
+		n.Add("Current", s.Current())
+		n.Add("Number", s.Number())
+		n.Add("LastNumber", s.LastNumber())
+		n.Add("CRC", s.CRC())
+		if !s.CheckCRC() {
+			n.fail(ErrSectionCRC, nil)
+			return n
+		}
+	}
+	data := s.Data()
+	switch id := s.TableId(); {
+	case id == 0x00:
+		decodePAT(n, data)
+	case id == 0x01 || id == 0x03:
+		decodeDescriptors(n, DescriptorList(data), 0)
+	case id == 0x02:
+		decodePMT(n, data)
+	case id == 0x40 || id == 0x41 || id == 0x4a:
+		decodeNIT(n, data)
+	case id == 0x42 || id == 0x46:
+		decodeSDT(n, data)
+	case id >= 0x4e && id <= 0x6f:
+		decodeEIT(n, data)
+	case id == 0x70:
+		t, err := ParseTDT(s)
+		if err != nil {
+			n.fail(err, data)
+			break
+		}
+		n.Add("UTC", t)
+	case id == 0x71:
+		rst, err := ParseRST(s)
+		if err != nil {
+			n.fail(err, data)
+			break
+		}
+		for i := 0; i < rst.Len(); i++ {
+			rn := &Node{Name: "RunningStatus"}
+			addStructFields(rn, reflect.ValueOf(rst.RunningStatus(i)))
+			n.Children = append(n.Children, rn)
+		}
+	case id == 0x72:
+		// Stuffing table.
+	case id == 0x73:
+		decodeTOT(n, s)
+	case id == 0x7e:
+		transition, err := ParseDIT(s)
+		if err != nil {
+			n.fail(err, data)
+			break
+		}
+		n.Add("Transition", transition)
+	case id == 0x7f:
+		decodeSIT(n, data)
+	case id == MGTTableId:
+		decodeMGT(n, s)
+	case id == TVCTTableId || id == CVCTTableId:
+		decodeVCT(n, s)
+	case id == RRTTableId:
+		decodeRRT(n, s)
+	case id == ATSCEITTableId:
+		decodeATSCEIT(n, s)
+	case id == ETTTableId:
+		decodeETT(n, s)
+	case id == STTTableId:
+		decodeSTT(n, s)
+	case id == SpliceInfoTableId:
+		si, err := ParseSpliceInfo(s)
+		if err != nil {
+			n.fail(err, s[3:])
+			break
+		}
+		addStructFields(n, reflect.ValueOf(*si))
+	default:
+		n.Add("Data", data)
+	}
+	return n
+}
+
+func decodePAT(n *Node, data []byte) {
+	for ; len(data) >= 4; data = data[4:] {
+		pn := &Node{Name: "Program"}
+		pn.Add("ProgId", decodeU16(data[0:2]))
+		pn.Add("Pid", int16(decodeU16(data[2:4])&0x1fff))
+		n.Children = append(n.Children, pn)
+	}
+	if len(data) != 0 {
+		n.fail(ErrSectionLoop, data)
+	}
+}
+
+func decodePMT(n *Node, data []byte) {
+	if len(data) < 4 {
+		n.fail(ErrPMTProgInfoLen, data)
+		return
+	}
+	n.Add("PidPCR", int16(decodeU16(data[0:2])&0x1fff))
+	dl, rest, ok := popLoop(data[2:])
+	if !ok {
+		n.fail(ErrPMTProgInfoLen, data)
+		return
+	}
+	// ATSC descriptors are allowed in PMT of programs registered as GA94.
+	var pds uint32
+	for l := dl; len(l) != 0; {
+		var d Descriptor
+		if d, l = l.Pop(); d == nil {
+			break
+		}
+		if rd, ok := ParseRegistrationDescriptor(d); ok && rd.FormatId == ATSCSpecifier {
+			pds = ATSCSpecifier
+		}
+	}
+	decodeDescriptors(n, dl, pds)
+	decodeEntries(n, rest, 5, pds, func(e []byte) *Node {
+		i := ESInfo(e)
+		en := &Node{Name: "ES"}
+		en.Add("Type", i.Type())
+		en.Add("Pid", i.Pid())
+		return en
+	})
+}
+
+func decodeNIT(n *Node, data []byte) {
+	dl, rest, ok := popLoop(data)
+	if !ok || len(rest) < 2 || loopLen(rest) != len(rest)-2 {
+		n.fail(ErrNITSectionLen, data)
+		return
+	}
+	decodeDescriptors(n, dl, 0)
+	decodeEntries(n, rest[2:], 6, 0, func(e []byte) *Node {
+		mi := MuxInfo(e)
+		en := &Node{Name: "Mux"}
+		en.Add("MuxId", mi.MuxId())
+		en.Add("OrgNetId", mi.OrgNetId())
+		return en
+	})
+}
+
+func decodeSDT(n *Node, data []byte) {
+	if len(data) < 3 {
+		n.fail(ErrSectionLoop, data)
+		return
+	}
+	n.Add("OrgNetId", decodeU16(data[0:2]))
+	decodeEntries(n, data[3:], 5, 0, func(e []byte) *Node {
+		si := ServiceInfo(e)
+		en := &Node{Name: "Service"}
+		en.Add("ServiceId", si.ServiceId())
+		en.Add("EITSchedule", si.EITSchedule())
+		en.Add("EITPresentFollowing", si.EITPresentFollowing())
+		en.Add("Status", si.Status())
+		en.Add("Scrambled", si.Scrambled())
+		return en
+	})
+}
+
+func decodeEIT(n *Node, data []byte) {
+	if len(data) < 6 {
+		n.fail(ErrSectionLoop, data)
+		return
+	}
+	n.Add("MuxId", decodeU16(data[0:2]))
+	n.Add("OrgNetId", decodeU16(data[2:4]))
+	n.Add("SegmentLastNumber", data[4])
+	n.Add("LastTableId", data[5])
+	decodeEntries(n, data[6:], 12, 0, func(e []byte) *Node {
+		en := &Node{Name: "Event"}
+		en.Add("EventId", decodeU16(e[0:2]))
+		if start, err := decodeMJDUTC(e[2:7]); err == nil {
+			en.Add("StartTime", start)
+		} else {
+			en.Add("StartTime", e[2:7])
+		}
+		en.Add("Duration", time.Duration(decodeBCD(e[7])*3600+
+			decodeBCD(e[8])*60+decodeBCD(e[9]))*time.Second)
+		en.Add("Status", ServiceStatus(e[10]>>5))
+		en.Add("Scrambled", e[10]&0x10 != 0)
+		return en
+	})
+}
+
+func decodeTOT(n *Node, s Section) {
+	utc, _, err := ParseTOT(s)
+	if err != nil {
+		n.fail(err, s[3:s.Len()])
+		return
+	}
+	n.Add("UTC", utc)
+	dl, _, _ := popLoop(s[8:])
+	decodeDescriptors(n, dl, 0)
+	n.Add("CRC", decodeU32(s[s.Len()-4:]))
+}
+
+func decodeSIT(n *Node, data []byte) {
+	dl, rest, ok := popLoop(data)
+	if !ok {
+		n.fail(ErrSectionLoop, data)
+		return
+	}
+	decodeDescriptors(n, dl, 0)
+	decodeEntries(n, rest, 4, 0, func(e []byte) *Node {
+		ss := SITService(e)
+		en := &Node{Name: "Service"}
+		en.Add("ServiceId", ss.ServiceId())
+		en.Add("Status", ss.Status())
+		return en
+	})
+}
+
+// decodeMS returns strings from ms or ms itself if it can't be decoded.
+func decodeMS(ms MultipleString) interface{} {
+	ls, err := ms.Strings()
+	if err != nil {
+		return []byte(ms)
+	}
+	return ls
+}
+
+// psipHeader checks PSIP section s, adds protocol_version to n and returns s
+// as one section table. Its loop count (at data offset 1, 16-bit if wide) is
+// returned in cnt.
+func psipHeader(n *Node, s Section, head int, wide bool) (t Table, cnt int, ok bool) {
+	data := s.Data()
+	if !s.GenericSyntax() || len(data) < head {
+		n.fail(ErrPSIPSectionSyntax, data)
+		return
+	}
+	n.Add("ProtocolVersion", data[0])
+	if wide {
+		cnt = int(decodeU16(data[1:3]))
+	} else if head > 1 {
+		cnt = int(data[1])
+	}
+	return Table{s}, cnt, true
+}
+
+func decodeMGT(n *Node, s Section) {
+	t, cnt, ok := psipHeader(n, s, 3, true)
+	if !ok {
+		return
+	}
+	tl := MGT(t).Tables()
+	for ; cnt > 0; cnt-- {
+		var mt MGTTable
+		if mt, tl = tl.Pop(); mt == nil {
+			n.fail(ErrSectionLoop, nil)
+			return
+		}
+		tn := &Node{Name: "Table"}
+		tn.Add("Type", mt.Type())
+		tn.Add("Pid", mt.Pid())
+		tn.Add("Version", mt.Version())
+		tn.Add("NumBytes", mt.NumBytes())
+		decodeDescriptors(tn, mt.Descriptors(), ATSCSpecifier)
+		n.Children = append(n.Children, tn)
+	}
+	decodeDescriptors(n, MGT(t).Descriptors(), ATSCSpecifier)
+}
+
+func decodeVCT(n *Node, s Section) {
+	t, cnt, ok := psipHeader(n, s, 2, false)
+	if !ok {
+		return
+	}
+	vct := VCT(t)
+	cl := vct.Channels()
+	for ; cnt > 0; cnt-- {
+		var c Channel
+		if c, cl = cl.Pop(); c == nil {
+			n.fail(ErrSectionLoop, nil)
+			return
+		}
+		cn := &Node{Name: "Channel"}
+		cn.Add("ShortName", c.ShortName())
+		cn.Add("Major", c.Major())
+		cn.Add("Minor", c.Minor())
+		cn.Add("ModulationMode", c.ModulationMode())
+		cn.Add("CarrierFrequency", c.CarrierFrequency())
+		cn.Add("MuxId", c.MuxId())
+		cn.Add("ProgramNumber", c.ProgramNumber())
+		cn.Add("ETMLocation", c.ETMLocation())
+		cn.Add("AccessControlled", c.AccessControlled())
+		cn.Add("Hidden", c.Hidden())
+		if vct.Cable() {
+			cn.Add("PathSelect", c.PathSelect())
+			cn.Add("OutOfBand", c.OutOfBand())
+		}
+		cn.Add("HideGuide", c.HideGuide())
+		cn.Add("ServiceType", c.ServiceType())
+		cn.Add("SourceId", c.SourceId())
+		decodeDescriptors(cn, c.Descriptors(), ATSCSpecifier)
+		n.Children = append(n.Children, cn)
+	}
+	decodeDescriptors(n, vct.Descriptors(), ATSCSpecifier)
+}
+
+func decodeRRT(n *Node, s Section) {
+	t, _, ok := psipHeader(n, s, 1, false)
+	if !ok {
+		return
+	}
+	rr, err := RRT(t).Decode()
+	if err != nil {
+		n.fail(err, s.Data())
+		return
+	}
+	n.Add("Name", decodeMS(rr.Name))
+	for _, dim := range rr.Dimensions {
+		dn := &Node{Name: "Dimension"}
+		dn.Add("Name", decodeMS(dim.Name))
+		dn.Add("Graduated", dim.Graduated)
+		for _, v := range dim.Values {
+			vn := &Node{Name: "Value"}
+			vn.Add("Abbrev", decodeMS(v.Abbrev))
+			vn.Add("Text", decodeMS(v.Text))
+			dn.Children = append(dn.Children, vn)
+		}
+		n.Children = append(n.Children, dn)
+	}
+	decodeDescriptors(n, rr.Descriptors, ATSCSpecifier)
+}
+
+func decodeATSCEIT(n *Node, s Section) {
+	t, cnt, ok := psipHeader(n, s, 2, false)
+	if !ok {
+		return
+	}
+	el := ATSCEIT(t).Events()
+	for ; cnt > 0; cnt-- {
+		var e ATSCEvent
+		if e, el = el.Pop(); e == nil {
+			n.fail(ErrSectionLoop, nil)
+			return
+		}
+		en := &Node{Name: "Event"}
+		en.Add("EventId", e.EventId())
+		en.Add("StartTime", e.StartTime())
+		en.Add("ETMLocation", e.ETMLocation())
+		en.Add("Duration", e.Duration())
+		en.Add("Title", decodeMS(e.Title()))
+		decodeDescriptors(en, e.Descriptors(), ATSCSpecifier)
+		n.Children = append(n.Children, en)
+	}
+}
+
+func decodeETT(n *Node, s Section) {
+	ett, err := ParseETT(s)
+	if err != nil {
+		n.fail(err, s.Data())
+		return
+	}
+	n.Add("ProtocolVersion", s.Data()[0])
+	n.Add("ETMId", ett.ETMId())
+	n.Add("SourceId", ett.SourceId())
+	if id, ok := ett.EventId(); ok {
+		n.Add("EventId", id)
+	}
+	n.Add("Text", decodeMS(ett.Text()))
+}
+
+func decodeSTT(n *Node, s Section) {
+	stt, err := ParseSTT(s)
+	if err != nil {
+		n.fail(err, s.Data())
+		return
+	}
+	n.Add("ProtocolVersion", s.Data()[0])
+	n.Add("SystemTime", stt.SystemTime())
+	n.Add("GPSUTCOffset", stt.GPSUTCOffset())
+	n.Add("UTC", stt.Time())
+	ds, day, hour := stt.DaylightSaving()
+	n.Add("DaylightSaving", ds)
+	n.Add("DSDayOfMonth", day)
+	n.Add("DSHour", hour)
+	decodeDescriptors(n, stt.Descriptors(), ATSCSpecifier)
+}
+
+// DumpText writes human readable representation of sections ss to w. Use
+// DumpText(w, t...) to dump whole table t.
+func DumpText(w io.Writer, ss ...Section) error {
+	tw := &textWriter{w: w}
+	for _, s := range ss {
+		tw.node(DecodeSection(s), 0)
+	}
+	return tw.err
+}
+
+// DumpJSON writes JSON array of decoded sections ss to w.
+func DumpJSON(w io.Writer, ss ...Section) error {
+	ns := make([]*Node, len(ss))
+	for i, s := range ss {
+		ns[i] = DecodeSection(s)
+	}
+	b, err := json.MarshalIndent(ns, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// WriteText writes human readable representation of n to w.
+func (n *Node) WriteText(w io.Writer) error {
+	tw := &textWriter{w: w}
+	tw.node(n, 0)
+	return tw.err
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	langType     = reflect.TypeOf(ISO639LangCode(0))
+	countryType  = reflect.TypeOf(CountryCode(0))
+	stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+type textWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (tw *textWriter) printf(indent int, format string, a ...interface{}) {
+	if tw.err != nil {
+		return
+	}
+	_, tw.err = io.WriteString(tw.w, strings.Repeat("  ", indent))
+	if tw.err == nil {
+		_, tw.err = fmt.Fprintf(tw.w, format, a...)
+	}
+}
+
+func (tw *textWriter) node(n *Node, indent int) {
+	tw.printf(indent, "%s\n", n.Name)
+	for _, f := range n.Fields {
+		tw.value(indent+1, f.Name, reflect.ValueOf(f.Value))
+	}
+	for _, c := range n.Children {
+		tw.node(c, indent+1)
+	}
+}
+
+func (tw *textWriter) value(indent int, name string, v reflect.Value) {
+	if s, ok := scalarText(v); ok {
+		tw.printf(indent, "%s: %s\n", name, s)
+		return
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			tw.printf(indent, "%s: nil\n", name)
+			return
+		}
+		tw.value(indent, name, v.Elem())
+	case reflect.Struct:
+		tw.printf(indent, "%s: %s\n", name, v.Type().Name())
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.PkgPath == "" {
+				tw.value(indent+1, f.Name, v.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			tw.printf(indent, "%s: []\n", name)
+			return
+		}
+		ss := make([]string, v.Len())
+		for i := range ss {
+			s, ok := scalarText(v.Index(i))
+			if !ok {
+				for i := 0; i < v.Len(); i++ {
+					tw.value(indent, fmt.Sprintf("%s[%d]", name, i), v.Index(i))
+				}
+				return
+			}
+			ss[i] = s
+		}
+		tw.printf(indent, "%s: [%s]\n", name, strings.Join(ss, ", "))
+	default:
+		tw.printf(indent, "%s: %v\n", name, v)
+	}
+}
+
+// bytesOf returns content of []byte or [n]byte value.
+func bytesOf(v reflect.Value) ([]byte, bool) {
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array ||
+		v.Type().Elem().Kind() != reflect.Uint8 {
+		return nil, false
+	}
+	b := make([]byte, v.Len())
+	reflect.Copy(reflect.ValueOf(b), v)
+	return b, true
+}
+
+func printable(b []byte) bool {
+	for _, c := range b {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return len(b) != 0
+}
+
+// scalarText formats v if it can be written in one line.
+func scalarText(v reflect.Value) (string, bool) {
+	if !v.IsValid() {
+		return "nil", true
+	}
+	switch v.Type() {
+	case timeType:
+		return v.Interface().(time.Time).Format(time.RFC3339), true
+	case durationType, langType, countryType:
+		return v.Interface().(fmt.Stringer).String(), true
+	}
+	if b, ok := bytesOf(v); ok {
+		s := hex.EncodeToString(b)
+		if printable(b) {
+			s += fmt.Sprintf(" %q", b)
+		}
+		return s, true
+	}
+	var name string
+	if v.Type().Implements(stringerType) && v.CanInterface() {
+		name = v.Interface().(fmt.Stringer).String()
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		if name != "" {
+			return fmt.Sprintf("%d (%s)", v.Int(), name), true
+		}
+		if v.Int() < 0 {
+			return fmt.Sprint(v.Int()), true
+		}
+		return fmt.Sprintf("%d (0x%x)", v.Int(), v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		if name != "" {
+			return fmt.Sprintf("%d (%s)", v.Uint(), name), true
+		}
+		return fmt.Sprintf("%d (0x%x)", v.Uint(), v.Uint()), true
+	case reflect.Bool, reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface()), true
+	case reflect.String:
+		return fmt.Sprintf("%q", v.String()), true
+	}
+	return "", false
+}
+
+// MarshalJSON implements json.Marshaler. Fields are encoded as JSON object
+// with keys in order of n.Fields. Byte slices are encoded as hex strings.
+func (n *Node) MarshalJSON() ([]byte, error) {
+	o := jsonObject{{"Name", n.Name}}
+	if len(n.Fields) != 0 {
+		fs := make(jsonObject, len(n.Fields))
+		for i, f := range n.Fields {
+			fs[i] = Field{f.Name, jsonValue(reflect.ValueOf(f.Value))}
+		}
+		o = append(o, Field{"Fields", fs})
+	}
+	if len(n.Children) != 0 {
+		o = append(o, Field{"Children", n.Children})
+	}
+	return o.MarshalJSON()
+}
+
+type jsonObject []Field
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(f.Name)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// jsonValue converts v to value that can be encoded by json.Marshal.
+func jsonValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	switch v.Type() {
+	case timeType:
+		return v.Interface()
+	case durationType, langType, countryType:
+		return v.Interface().(fmt.Stringer).String()
+	}
+	if b, ok := bytesOf(v); ok {
+		return hex.EncodeToString(b)
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return jsonValue(v.Elem())
+	case reflect.Struct:
+		var o jsonObject
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.PkgPath == "" {
+				o = append(o, Field{f.Name, jsonValue(v.Field(i))})
+			}
+		}
+		return o
+	case reflect.Slice, reflect.Array:
+		a := make([]interface{}, v.Len())
+		for i := range a {
+			a[i] = jsonValue(v.Index(i))
+		}
+		return a
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		return v.Uint()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,159 @@
+package psi_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ziutek/dvb/ts/psi"
+)
+
+type testPrivate struct {
+	Level byte
+}
+
+func TestDump(t *testing.T) {
+	const pds = 0x12345678
+	psi.RegisterDescriptor(pds, 0x90, func(d psi.Descriptor) (interface{}, bool) {
+		if d.Tag() != 0x90 || len(d.Data()) != 1 {
+			return nil, false
+		}
+		return testPrivate{d.Data()[0]}, true
+	})
+	priv := psi.MakeDescriptor(0x90, 1)
+	priv.Data()[0] = 7
+	si := psi.MakeServiceInfo()
+	si.SetServiceId(0x401)
+	si.SetStatus(psi.Running)
+	si.AppendDescriptors(
+		psi.MakeServiceDescriptor(1, "prov", "serv"),
+		priv, // Without private_data_specifier.
+		psi.MakePrivateDataSpecifierDescriptor(pds),
+		priv,
+	)
+	var sdt psi.SDT
+	sdt.Append(2, si)
+	sdt.Close(3, true, true, 4)
+
+	var buf bytes.Buffer
+	if err := psi.DumpText(&buf, sdt...); err != nil {
+		t.Fatal(err)
+	}
+	text := buf.String()
+	for _, s := range []string{
+		"SDT\n",
+		"  OrgNetId: 2 (0x2)\n",
+		"  Service\n",
+		"    ServiceId: 1025 (0x401)\n",
+		"    Status: 4 (running)\n",
+		"      ProviderName: \"prov\"\n",
+		"      Data: 07\n",
+		"      PrivateDataSpecifier: 305419896 (0x12345678)\n",
+		"      Level: 7 (0x7)\n",
+	} {
+		if !strings.Contains(text, s) {
+			t.Errorf("%q not found in:\n%s", s, text)
+		}
+	}
+
+	buf.Reset()
+	if err := psi.DumpJSON(&buf, sdt...); err != nil {
+		t.Fatal(err)
+	}
+	type node struct {
+		Name     string
+		Fields   map[string]interface{}
+		Children []node
+	}
+	var ns []node
+	if err := json.Unmarshal(buf.Bytes(), &ns); err != nil {
+		t.Fatal(err, buf.String())
+	}
+	if len(ns) != 1 || ns[0].Name != "SDT" || len(ns[0].Children) != 1 {
+		t.Fatalf("bad JSON: %s", buf.String())
+	}
+	ds := ns[0].Children[0].Children
+	if len(ds) != 4 {
+		t.Fatalf("bad JSON: %s", buf.String())
+	}
+	if ds[0].Fields["ServiceName"] != "serv" {
+		t.Error("ServiceName:", ds[0].Fields["ServiceName"])
+	}
+	if ds[1].Fields["Data"] != "07" {
+		t.Error("Data:", ds[1].Fields["Data"])
+	}
+	if ds[3].Fields["Level"] != 7.0 {
+		t.Error("Level:", ds[3].Fields["Level"])
+	}
+}
+
+func field(n *psi.Node, name string) (interface{}, bool) {
+	for _, f := range n.Fields {
+		if f.Name == name {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+func TestDumpScope(t *testing.T) {
+	caption := psi.Descriptor{0x86, 7, 0xe1, 'e', 'n', 'g', 0xc1, 0x3f, 0xff}
+	var lcd psi.LogicalChannelDescriptor
+	lcd.Append(psi.LogicalChannelInfo{Sid: 0x401, LCN: 5, Visible: true})
+	si := psi.MakeServiceInfo()
+	si.SetServiceId(0x401)
+	si.AppendDescriptors(
+		lcd.MakeDescriptor(), // Without private_data_specifier.
+		psi.MakePrivateDataSpecifierDescriptor(psi.EACEMSpecifier),
+		caption,
+		lcd.MakeDescriptor(),
+	)
+	var sdt psi.SDT
+	sdt.Append(2, si)
+	sdt.Close(3, true, true, 4)
+	ds := psi.DecodeSection(sdt[0]).Children[0].Children
+	if len(ds) != 4 {
+		t.Fatalf("%d descriptors", len(ds))
+	}
+	for i, decoded := range []bool{false, true, false, true} {
+		if _, ok := field(ds[i], "Value"); ok != decoded {
+			t.Errorf("descriptor %d: decoded %t", i, ok)
+		}
+	}
+
+	// The same caption_service_descriptor in TVCT.
+	ch := make([]byte, 32)
+	ch[31] = byte(len(caption))
+	ch = append(ch, caption...)
+	data := append([]byte{0, 1}, ch...)
+	data = append(data, 0xfc, 0x00)
+	s := psi.MakeEmptySection(psi.SectionMaxLen, true)
+	s.SetTableId(psi.TVCTTableId)
+	s.SetPrivateSyntax(true)
+	s.SetCurrent(true)
+	copy(s.Alloc(len(data), 0), data)
+	s.MakeCRC()
+	n := psi.DecodeSection(s)
+	if n.Name != "TVCT" || len(n.Children) != 1 || len(n.Children[0].Children) != 1 {
+		t.Fatalf("bad TVCT: %+v", n)
+	}
+	v, _ := field(n.Children[0].Children[0], "Value")
+	cs, ok := v.([]psi.CaptionService)
+	if !ok || len(cs) != 1 || !cs[0].Digital || cs[0].ServiceNumber != 1 {
+		t.Errorf("bad caption services: %+v", v)
+	}
+}
+
+func TestDumpBadLen(t *testing.T) {
+	for _, s := range []psi.Section{
+		{0x70, 0x7f, 0xff, 0, 0, 0, 0, 0},
+		{0xc8, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		{0x70, 0x00},
+	} {
+		n := psi.DecodeSection(s)
+		if _, ok := field(n, "Error"); !ok {
+			t.Errorf("% x: no error", []byte(s))
+		}
+	}
+}
@@ -0,0 +1,488 @@
+package psi
+
+import (
+	"fmt"
+)
+
+// StreamContent is a stream_content field of component descriptor.
+type StreamContent byte
+
+const (
+	MPEG2VideoContent    StreamContent = 0x1
+	MPEG1L2AudioContent  StreamContent = 0x2
+	SubtitleContent      StreamContent = 0x3 // EBU Teletext, VBI, DVB subtitles
+	AC3AudioContent      StreamContent = 0x4
+	H264VideoContent     StreamContent = 0x5
+	HEAACAudioContent    StreamContent = 0x6
+	DTSAudioContent      StreamContent = 0x7
+	SRMDataContent       StreamContent = 0x8
+	HEVCNGAContent       StreamContent = 0x9 // HEVC video (ext 0), NGA (ext 1)
+	ExtAttributesContent StreamContent = 0xb
+)
+
+type ComponentDescriptor struct {
+	StreamContentExt byte // 4 bits
+	StreamContent    StreamContent
+	Type             byte // component_type
+	Tag              byte // component_tag
+	Lang             ISO639LangCode
+	Text             []byte
+}
+
+func ParseComponentDescriptor(d Descriptor) (cd ComponentDescriptor, ok bool) {
+	if d.Tag() != ComponentTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 6 {
+		return
+	}
+	cd.StreamContentExt = data[0] >> 4
+	cd.StreamContent = StreamContent(data[0] & 0x0f)
+	cd.Type = data[1]
+	cd.Tag = data[2]
+	cd.Lang = ISO639LangCode(decodeU24(data[3:6]))
+	cd.Text = data[6:]
+	ok = true
+	return
+}
+
+func (cd ComponentDescriptor) MakeDescriptor() Descriptor {
+	d := MakeDescriptor(ComponentTag, 6+len(cd.Text))
+	data := d.Data()
+	data[0] = cd.StreamContentExt<<4 | byte(cd.StreamContent)&0x0f
+	data[1] = cd.Type
+	data[2] = cd.Tag
+	data[3] = byte(cd.Lang >> 16)
+	data[4] = byte(cd.Lang >> 8)
+	data[5] = byte(cd.Lang)
+	copy(data[6:], cd.Text)
+	return d
+}
+
+var (
+	videoAspect = []string{"4:3", "16:9 with pan vectors", "16:9", ">16:9"}
+	ac3Channels = []string{
+		"mono", "dual mono", "stereo", "surround encoded stereo",
+		"multichannel", "multichannel >5.1", "multiple substreams", "",
+	}
+	subtitleAspect = []string{"", " 4:3", " 16:9", " 2.21:1", " HD", " plano-stereoscopic"}
+)
+
+// videoClass decodes component_type used by MPEG-2 and H.264 video.
+func videoClass(codec string, t byte) string {
+	if t < 0x01 || t > 0x10 {
+		return ""
+	}
+	t--
+	res, rate := "SD", "25Hz"
+	if t >= 8 {
+		res = "HD"
+	}
+	if t&4 != 0 {
+		rate = "30Hz"
+	}
+	return codec + " " + res + " " + videoAspect[t&3] + " " + rate
+}
+
+// audioClass decodes component_type used by MPEG-1 Layer 2 and HE-AAC audio.
+func audioClass(codec string, t byte) string {
+	switch t {
+	case 0x01:
+		return codec + " mono"
+	case 0x02:
+		return codec + " dual mono"
+	case 0x03:
+		return codec + " stereo"
+	case 0x04:
+		return codec + " multi-lingual multichannel"
+	case 0x05:
+		return codec + " surround"
+	case 0x40:
+		return codec + " visual impaired description"
+	case 0x41:
+		return codec + " for the hard of hearing"
+	case 0x42:
+		return codec + " receiver-mix supplementary audio"
+	case 0x47:
+		return codec + " receiver-mix audio description"
+	case 0x48:
+		return codec + " broadcast-mix audio description"
+	}
+	return ""
+}
+
+// Class returns human-readable classification of component described by
+// stream_content, stream_content_ext and component_type fields (EN 300 468
+// table 26), e.g. "H.264 HD 16:9 25Hz" or "E-AC-3 stereo". It returns empty
+// string for reserved and user defined values.
+func (cd ComponentDescriptor) Class() string {
+	t := cd.Type
+	switch cd.StreamContent {
+	case MPEG2VideoContent:
+		return videoClass("MPEG-2", t)
+	case MPEG1L2AudioContent:
+		return audioClass("MPEG-1 Layer 2", t)
+	case SubtitleContent:
+		switch {
+		case t == 0x01:
+			return "EBU Teletext subtitles"
+		case t == 0x02:
+			return "associated EBU Teletext"
+		case t == 0x03:
+			return "VBI data"
+		case t >= 0x10 && t <= 0x15:
+			return "DVB subtitles" + subtitleAspect[t-0x10]
+		case t >= 0x20 && t <= 0x25:
+			return "DVB subtitles for the hard of hearing" + subtitleAspect[t-0x20]
+		}
+	case AC3AudioContent:
+		codec := "AC-3"
+		if t&0x80 != 0 {
+			codec = "E-AC-3"
+		}
+		if ch := ac3Channels[t&0x07]; ch != "" {
+			return codec + " " + ch
+		}
+		return codec
+	case H264VideoContent:
+		switch t {
+		case 0x80, 0x81:
+			return "H.264 plano-stereoscopic side by side"
+		case 0x82, 0x83:
+			return "H.264 plano-stereoscopic top and bottom"
+		case 0x84:
+			return "H.264 MVC"
+		case 0x02, 0x06, 0x09, 0x0a, 0x0d, 0x0e:
+			return "" // reserved for H.264
+		}
+		return videoClass("H.264", t)
+	case HEAACAudioContent:
+		switch t {
+		case 0x43:
+			return "HE-AAC v2 stereo"
+		case 0x44:
+			return "HE-AAC v2 visual impaired description"
+		case 0x45:
+			return "HE-AAC v2 for the hard of hearing"
+		case 0x46:
+			return "HE-AAC v2 receiver-mix supplementary audio"
+		case 0x49:
+			return "HE-AAC v2 receiver-mix audio description"
+		case 0x4a:
+			return "HE-AAC v2 broadcast-mix audio description"
+		}
+		return audioClass("HE-AAC", t)
+	case DTSAudioContent:
+		return "DTS"
+	case SRMDataContent:
+		return "DVB SRM data"
+	case HEVCNGAContent:
+		switch cd.StreamContentExt {
+		case 0x0:
+			switch t {
+			case 0x00:
+				return "HEVC HD Main 50Hz"
+			case 0x01:
+				return "HEVC HD Main10 50Hz"
+			case 0x02:
+				return "HEVC HD Main 60Hz"
+			case 0x03:
+				return "HEVC HD Main10 60Hz"
+			}
+			if t <= 0x08 {
+				return "HEVC UHD"
+			}
+		case 0x1:
+			return "NGA"
+		}
+	}
+	return ""
+}
+
+func (cd ComponentDescriptor) String() string {
+	class := cd.Class()
+	if class == "" {
+		class = fmt.Sprintf(
+			"stream_content %d/%d type 0x%02x",
+			cd.StreamContent, cd.StreamContentExt, cd.Type,
+		)
+	}
+	return class
+}
+
+// Genre is an EN 300 468 content_nibble_level_1 (4 MSB) and
+// content_nibble_level_2 (4 LSB) pair.
+type Genre byte
+
+const (
+	UndefinedGenre              Genre = 0x00
+	MovieGenre                  Genre = 0x10
+	NewsGenre                   Genre = 0x20
+	ShowGenre                   Genre = 0x30
+	SportsGenre                 Genre = 0x40
+	ChildrenGenre               Genre = 0x50
+	MusicGenre                  Genre = 0x60
+	ArtsGenre                   Genre = 0x70
+	SocialGenre                 Genre = 0x80
+	EducationGenre              Genre = 0x90
+	LeisureGenre                Genre = 0xa0
+	SpecialCharacteristicsGenre Genre = 0xb0
+	UserDefinedGenre            Genre = 0xf0
+)
+
+var genreNames = [12][]string{
+	{"undefined content"},
+	{
+		"movie/drama",
+		"detective/thriller",
+		"adventure/western/war",
+		"science fiction/fantasy/horror",
+		"comedy",
+		"soap/melodrama/folklore",
+		"romance",
+		"serious/classical/religious/historical movie/drama",
+		"adult movie/drama",
+	},
+	{
+		"news/current affairs",
+		"news/weather report",
+		"news magazine",
+		"documentary",
+		"discussion/interview/debate",
+	},
+	{
+		"show/game show",
+		"game show/quiz/contest",
+		"variety show",
+		"talk show",
+	},
+	{
+		"sports",
+		"special events",
+		"sports magazines",
+		"football/soccer",
+		"tennis/squash",
+		"team sports",
+		"athletics",
+		"motor sport",
+		"water sport",
+		"winter sports",
+		"equestrian",
+		"martial sports",
+	},
+	{
+		"children's/youth programmes",
+		"pre-school children's programmes",
+		"entertainment programmes for 6 to 14",
+		"entertainment programmes for 10 to 16",
+		"informational/educational/school programmes",
+		"cartoons/puppets",
+	},
+	{
+		"music/ballet/dance",
+		"rock/pop",
+		"serious music/classical music",
+		"folk/traditional music",
+		"jazz",
+		"musical/opera",
+		"ballet",
+	},
+	{
+		"arts/culture",
+		"performing arts",
+		"fine arts",
+		"religion",
+		"popular culture/traditional arts",
+		"literature",
+		"film/cinema",
+		"experimental film/video",
+		"broadcasting/press",
+		"new media",
+		"arts/culture magazines",
+		"fashion",
+	},
+	{
+		"social/political issues/economics",
+		"magazines/reports/documentary",
+		"economics/social advisory",
+		"remarkable people",
+	},
+	{
+		"education/science/factual topics",
+		"nature/animals/environment",
+		"technology/natural sciences",
+		"medicine/physiology/psychology",
+		"foreign countries/expeditions",
+		"social/spiritual sciences",
+		"further education",
+		"languages",
+	},
+	{
+		"leisure hobbies",
+		"tourism/travel",
+		"handicraft",
+		"motoring",
+		"fitness and health",
+		"cooking",
+		"advertisement/shopping",
+		"gardening",
+	},
+	{
+		"original language",
+		"black and white",
+		"unpublished",
+		"live broadcast",
+		"plano-stereoscopic",
+		"local or regional",
+	},
+}
+
+// Level1 returns genre with content_nibble_level_2 cleared.
+func (g Genre) Level1() Genre {
+	return g & 0xf0
+}
+
+// genreCategories contains names of content_nibble_level_1 categories.
+var genreCategories = [12]string{
+	"undefined content",
+	"movie/drama",
+	"news/current affairs",
+	"show/game show",
+	"sports",
+	"children's/youth programmes",
+	"music/ballet/dance",
+	"arts/culture",
+	"social/political issues/economics",
+	"education/science/factual topics",
+	"leisure hobbies",
+	"special characteristics",
+}
+
+// Category returns name of content_nibble_level_1 category.
+func (g Genre) Category() string {
+	l1 := int(g >> 4)
+	switch {
+	case l1 == 0xf:
+		return "user defined"
+	case l1 >= len(genreCategories):
+		return "reserved"
+	}
+	return genreCategories[l1]
+}
+
+func (g Genre) String() string {
+	l1, l2 := g>>4, g&0x0f
+	switch {
+	case l1 == 0:
+		return genreNames[0][0]
+	case l1 == 0xf || l2 == 0xf:
+		return "user defined"
+	case int(l1) >= len(genreNames) || int(l2) >= len(genreNames[l1]):
+		return "reserved"
+	}
+	return genreNames[l1][l2]
+}
+
+// Content is an entry of content descriptor.
+type Content struct {
+	Genre Genre
+	User  byte // user_byte
+}
+
+type ContentDescriptor []byte
+
+func ParseContentDescriptor(d Descriptor) (cd ContentDescriptor, ok bool) {
+	if d.Tag() != ContentTag {
+		return
+	}
+	data := d.Data()
+	if len(data)%2 != 0 {
+		return
+	}
+	return ContentDescriptor(data), true
+}
+
+// Pop returns first Content from d. Remaining entries are returned in rd.
+// If there is no more entries to read len(rd) == 0. If an error occurs
+// rd = nil.
+func (d ContentDescriptor) Pop() (c Content, rd ContentDescriptor) {
+	if len(d) < 2 {
+		return
+	}
+	c = Content{Genre(d[0]), d[1]}
+	rd = d[2:]
+	return
+}
+
+func (cd *ContentDescriptor) Append(c Content) {
+	*cd = append(*cd, byte(c.Genre), c.User)
+}
+
+func (cd ContentDescriptor) MakeDescriptor() Descriptor {
+	d := MakeDescriptor(ContentTag, len(cd))
+	copy(d.Data(), cd)
+	return d
+}
+
+// CountryCode is an ISO 3166 alpha-3 country code.
+type CountryCode uint32
+
+// String returns three letter country code.
+func (c CountryCode) String() string {
+	return string([]byte{byte(c >> 16), byte(c >> 8), byte(c)})
+}
+
+// ParentalRating is an entry of parental rating descriptor.
+type ParentalRating struct {
+	Country CountryCode
+	Rating  byte
+}
+
+// MinAge returns minimum age of viewer. It returns ok == false if rating is
+// undefined or defined by broadcaster.
+func (pr ParentalRating) MinAge() (age int, ok bool) {
+	if pr.Rating < 0x01 || pr.Rating > 0x0f {
+		return 0, false
+	}
+	return int(pr.Rating) + 3, true
+}
+
+type ParentalRatingDescriptor []byte
+
+func ParseParentalRatingDescriptor(d Descriptor) (prd ParentalRatingDescriptor, ok bool) {
+	if d.Tag() != ParentalRatingTag {
+		return
+	}
+	data := d.Data()
+	if len(data)%4 != 0 {
+		return
+	}
+	return ParentalRatingDescriptor(data), true
+}
+
+// Pop returns first ParentalRating from d. Remaining ratings are returned in
+// rd. If there is no more ratings to read len(rd) == 0. If an error occurs
+// rd = nil.
+func (d ParentalRatingDescriptor) Pop() (pr ParentalRating, rd ParentalRatingDescriptor) {
+	if len(d) < 4 {
+		return
+	}
+	pr.Country = CountryCode(decodeU24(d[0:3]))
+	pr.Rating = d[3]
+	rd = d[4:]
+	return
+}
+
+func (prd *ParentalRatingDescriptor) Append(pr ParentalRating) {
+	*prd = append(
+		*prd,
+		byte(pr.Country>>16), byte(pr.Country>>8), byte(pr.Country), pr.Rating,
+	)
+}
+
+func (prd ParentalRatingDescriptor) MakeDescriptor() Descriptor {
+	d := MakeDescriptor(ParentalRatingTag, len(prd))
+	copy(d.Data(), prd)
+	return d
+}
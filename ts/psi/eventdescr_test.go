@@ -0,0 +1,84 @@
+package psi_test
+
+import (
+	"testing"
+
+	"github.com/ziutek/dvb/ts/psi"
+)
+
+func TestComponentDescriptor(t *testing.T) {
+	cases := []struct {
+		sc  psi.StreamContent
+		ext byte
+		typ byte
+		s   string
+	}{
+		{psi.H264VideoContent, 0, 0x0b, "H.264 HD 16:9 25Hz"},
+		{psi.H264VideoContent, 0, 0x09, "stream_content 5/0 type 0x09"},
+		{psi.H264VideoContent, 0, 0x02, "stream_content 5/0 type 0x02"},
+		{psi.MPEG2VideoContent, 0, 0x01, "MPEG-2 SD 4:3 25Hz"},
+		{psi.AC3AudioContent, 0, 0xc2, "E-AC-3 stereo"},
+		{psi.HEAACAudioContent, 0, 0x43, "HE-AAC v2 stereo"},
+		{psi.SubtitleContent, 0, 0x22, "DVB subtitles for the hard of hearing 16:9"},
+		{psi.HEVCNGAContent, 0, 0x01, "HEVC HD Main10 50Hz"},
+		{0xc, 0, 0x01, "stream_content 12/0 type 0x01"},
+	}
+	for _, c := range cases {
+		cd := psi.ComponentDescriptor{
+			StreamContentExt: c.ext,
+			StreamContent:    c.sc,
+			Type:             c.typ,
+			Tag:              7,
+			Lang:             0x706f6c,
+			Text:             []byte("abc"),
+		}
+		rcd, ok := psi.ParseComponentDescriptor(cd.MakeDescriptor())
+		if !ok || rcd.Tag != 7 || rcd.Lang != cd.Lang || string(rcd.Text) != "abc" {
+			t.Fatalf("bad round trip: %+v", rcd)
+		}
+		if s := rcd.String(); s != c.s {
+			t.Errorf("%q != %q", s, c.s)
+		}
+	}
+}
+
+func TestContentDescriptor(t *testing.T) {
+	var cd psi.ContentDescriptor
+	cd.Append(psi.Content{Genre: 0x43, User: 1})
+	cd.Append(psi.Content{Genre: 0x1f})
+	rcd, ok := psi.ParseContentDescriptor(cd.MakeDescriptor())
+	if !ok {
+		t.Fatal("can't parse content descriptor")
+	}
+	c, rcd := rcd.Pop()
+	if c.Genre.String() != "football/soccer" || c.Genre.Category() != "sports" || c.User != 1 {
+		t.Errorf("bad content: %v %v %d", c.Genre, c.Genre.Category(), c.User)
+	}
+	c, rcd = rcd.Pop()
+	if c.Genre.String() != "user defined" || rcd == nil || len(rcd) != 0 {
+		t.Errorf("bad content: %v %v", c.Genre, rcd)
+	}
+	categories := map[psi.Genre]string{
+		0xb0: "special characteristics",
+		0xb1: "special characteristics",
+		0x70: "arts/culture",
+		0xc0: "reserved",
+		0xf3: "user defined",
+	}
+	for g, cat := range categories {
+		if g.Category() != cat {
+			t.Errorf("%#x: category %q != %q", byte(g), g.Category(), cat)
+		}
+	}
+
+	var prd psi.ParentalRatingDescriptor
+	prd.Append(psi.ParentalRating{Country: 0x504f4c, Rating: 0x09})
+	rprd, ok := psi.ParseParentalRatingDescriptor(prd.MakeDescriptor())
+	if !ok {
+		t.Fatal("can't parse parental rating descriptor")
+	}
+	pr, _ := rprd.Pop()
+	if age, ok := pr.MinAge(); !ok || age != 12 || pr.Country != 0x504f4c {
+		t.Errorf("bad parental rating: %+v", pr)
+	}
+}
@@ -0,0 +1,563 @@
+package psi
+
+type LinkageType byte
+
+const (
+	InformationLinkage        LinkageType = 0x01
+	EPGLinkage                LinkageType = 0x02
+	CAReplacementLinkage      LinkageType = 0x03
+	CompleteSILinkage         LinkageType = 0x04
+	ServiceReplacementLinkage LinkageType = 0x05
+	DataBroadcastLinkage      LinkageType = 0x06
+	RCSMapLinkage             LinkageType = 0x07
+	MobileHandOverLinkage     LinkageType = 0x08
+	SSULinkage                LinkageType = 0x09
+	SSUTableLinkage           LinkageType = 0x0a
+	IPMACLinkage              LinkageType = 0x0b
+	INTTableLinkage           LinkageType = 0x0c
+	EventLinkage              LinkageType = 0x0d
+	ExtEventLinkageFirst      LinkageType = 0x0e
+	ExtEventLinkageLast       LinkageType = 0x1f
+)
+
+var ltn = []string{
+	"information service",
+	"EPG service",
+	"CA replacement service",
+	"TS containing complete network/bouquet SI",
+	"service replacement service",
+	"data broadcast service",
+	"RCS map",
+	"mobile hand-over",
+	"system software update service",
+	"TS containing SSU BAT or NIT",
+	"IP/MAC notification service",
+	"TS containing INT BAT or NIT",
+	"event linkage",
+}
+
+func (t LinkageType) String() string {
+	switch {
+	case t == 0 || t == 0xff:
+		return "reserved"
+	case t <= EventLinkage:
+		return ltn[t-1]
+	case t <= ExtEventLinkageLast:
+		return "extended event linkage"
+	case t >= 0x80:
+		return "user defined"
+	}
+	return "reserved"
+}
+
+// MobileHandOver contains private fields of mobile hand-over linkage.
+type MobileHandOver struct {
+	Type             byte // 4 bits: 1 identical, 2 local variation, 3 associated
+	OriginSDT        bool // origin_type: true for SDT, false for NIT
+	NetId            uint16
+	InitialServiceId uint16
+}
+
+// LinkedEvent contains private fields of event linkage.
+type LinkedEvent struct {
+	EventId   uint16
+	Listed    bool
+	Simulcast bool
+}
+
+// ExtLinkedEvent is an element of extended event linkage loop.
+type ExtLinkedEvent struct {
+	EventId      uint16
+	Listed       bool
+	Simulcast    bool
+	LinkType     byte // 2 bits
+	TargetIdType byte // 2 bits
+	HasOrgNetId  bool
+	HasServiceId bool
+	UserId       uint16 // if TargetIdType == 3
+	MuxId        uint16 // if TargetIdType == 1
+	OrgNetId     uint16
+	ServiceId    uint16
+}
+
+// SSUSelector is an element of system software update linkage OUI loop.
+type SSUSelector struct {
+	OUI      uint32
+	Selector []byte
+}
+
+// PlatformName is a name of IP/MAC platform in one language.
+type PlatformName struct {
+	Lang ISO639LangCode
+	Name []byte
+}
+
+// Platform is an element of IP/MAC notification linkage platform loop.
+type Platform struct {
+	Id    uint32 // 24 bits
+	Names []PlatformName
+}
+
+// LinkageDescriptor represents linkage_descriptor. Fields specific to
+// linkage_type are valid only if Type has appropriate value.
+type LinkageDescriptor struct {
+	MuxId     uint16
+	OrgNetId  uint16
+	ServiceId uint16
+	Type      LinkageType
+
+	HandOver  MobileHandOver   // MobileHandOverLinkage
+	Event     LinkedEvent      // EventLinkage
+	ExtEvents []ExtLinkedEvent // ExtEventLinkageFirst...ExtEventLinkageLast
+	SSU       []SSUSelector    // SSULinkage
+	TableType byte             // SSUTableLinkage, INTTableLinkage
+	BouquetId uint16           // INTTableLinkage if TableType == 2
+	Platforms []Platform       // IPMACLinkage
+
+	PrivateData []byte
+}
+
+func ParseLinkageDescriptor(d Descriptor) (ld LinkageDescriptor, ok bool) {
+	if d.Tag() != LinkageTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 7 {
+		return
+	}
+	ld.MuxId = decodeU16(data[0:2])
+	ld.OrgNetId = decodeU16(data[2:4])
+	ld.ServiceId = decodeU16(data[4:6])
+	ld.Type = LinkageType(data[6])
+	data = data[7:]
+	switch t := ld.Type; {
+	case t == MobileHandOverLinkage:
+		if len(data) < 1 {
+			return
+		}
+		ho := &ld.HandOver
+		ho.Type = data[0] >> 4
+		ho.OriginSDT = data[0]&0x01 != 0
+		data = data[1:]
+		if ho.Type >= 1 && ho.Type <= 3 {
+			if len(data) < 2 {
+				return
+			}
+			ho.NetId = decodeU16(data[0:2])
+			data = data[2:]
+		}
+		if !ho.OriginSDT {
+			if len(data) < 2 {
+				return
+			}
+			ho.InitialServiceId = decodeU16(data[0:2])
+			data = data[2:]
+		}
+	case t == SSULinkage:
+		if len(data) < 1 || len(data) < 1+int(data[0]) {
+			return
+		}
+		oui := data[1 : 1+int(data[0])]
+		data = data[1+int(data[0]):]
+		for len(oui) > 0 {
+			if len(oui) < 4 || len(oui) < 4+int(oui[3]) {
+				return
+			}
+			n := 4 + int(oui[3])
+			ld.SSU = append(ld.SSU, SSUSelector{decodeU24(oui[0:3]), oui[4:n]})
+			oui = oui[n:]
+		}
+	case t == SSUTableLinkage:
+		if len(data) < 1 {
+			return
+		}
+		ld.TableType = data[0]
+		data = data[1:]
+	case t == IPMACLinkage:
+		if len(data) < 1 || len(data) < 1+int(data[0]) {
+			return
+		}
+		pl := data[1 : 1+int(data[0])]
+		data = data[1+int(data[0]):]
+		for len(pl) > 0 {
+			if len(pl) < 4 || len(pl) < 4+int(pl[3]) {
+				return
+			}
+			p := Platform{Id: decodeU24(pl[0:3])}
+			nl := pl[4 : 4+int(pl[3])]
+			pl = pl[4+int(pl[3]):]
+			for len(nl) > 0 {
+				if len(nl) < 4 || len(nl) < 4+int(nl[3]) {
+					return
+				}
+				p.Names = append(p.Names, PlatformName{
+					ISO639LangCode(decodeU24(nl[0:3])), nl[4 : 4+int(nl[3])],
+				})
+				nl = nl[4+int(nl[3]):]
+			}
+			ld.Platforms = append(ld.Platforms, p)
+		}
+	case t == INTTableLinkage:
+		if len(data) < 1 {
+			return
+		}
+		ld.TableType = data[0]
+		data = data[1:]
+		if ld.TableType == 0x02 {
+			if len(data) < 2 {
+				return
+			}
+			ld.BouquetId = decodeU16(data[0:2])
+			data = data[2:]
+		}
+	case t == EventLinkage:
+		if len(data) < 3 {
+			return
+		}
+		ld.Event = LinkedEvent{
+			decodeU16(data[0:2]), data[2]&0x80 != 0, data[2]&0x40 != 0,
+		}
+		data = data[3:]
+	case t >= ExtEventLinkageFirst && t <= ExtEventLinkageLast:
+		if len(data) < 1 || len(data) < 1+int(data[0]) {
+			return
+		}
+		el := data[1 : 1+int(data[0])]
+		data = data[1+int(data[0]):]
+		for len(el) > 0 {
+			if len(el) < 3 {
+				return
+			}
+			e := ExtLinkedEvent{
+				EventId:      decodeU16(el[0:2]),
+				Listed:       el[2]&0x80 != 0,
+				Simulcast:    el[2]&0x40 != 0,
+				LinkType:     el[2] >> 4 & 0x03,
+				TargetIdType: el[2] >> 2 & 0x03,
+				HasOrgNetId:  el[2]&0x02 != 0,
+				HasServiceId: el[2]&0x01 != 0,
+			}
+			el = el[3:]
+			var fs []*uint16
+			if e.TargetIdType == 3 {
+				fs = append(fs, &e.UserId)
+			} else {
+				if e.TargetIdType == 1 {
+					fs = append(fs, &e.MuxId)
+				}
+				if e.HasOrgNetId {
+					fs = append(fs, &e.OrgNetId)
+				}
+				if e.HasServiceId {
+					fs = append(fs, &e.ServiceId)
+				}
+			}
+			for _, f := range fs {
+				if len(el) < 2 {
+					return
+				}
+				*f = decodeU16(el[0:2])
+				el = el[2:]
+			}
+			ld.ExtEvents = append(ld.ExtEvents, e)
+		}
+	}
+	ld.PrivateData = data
+	ok = true
+	return
+}
+
+func (ld LinkageDescriptor) MakeDescriptor() Descriptor {
+	b := make([]byte, 0, 255)
+	b = appendU16(b, ld.MuxId)
+	b = appendU16(b, ld.OrgNetId)
+	b = appendU16(b, ld.ServiceId)
+	b = append(b, byte(ld.Type))
+	switch t := ld.Type; {
+	case t == MobileHandOverLinkage:
+		ho := ld.HandOver
+		flags := ho.Type<<4 | 0x0e
+		if ho.OriginSDT {
+			flags |= 0x01
+		}
+		b = append(b, flags)
+		if ho.Type >= 1 && ho.Type <= 3 {
+			b = appendU16(b, ho.NetId)
+		}
+		if !ho.OriginSDT {
+			b = appendU16(b, ho.InitialServiceId)
+		}
+	case t == SSULinkage:
+		n := len(b)
+		b = append(b, 0)
+		for _, s := range ld.SSU {
+			b = appendU24(b, s.OUI)
+			b = append(b, byte(len(s.Selector)))
+			b = append(b, s.Selector...)
+		}
+		b[n] = byte(len(b) - n - 1)
+	case t == SSUTableLinkage:
+		b = append(b, ld.TableType)
+	case t == IPMACLinkage:
+		n := len(b)
+		b = append(b, 0)
+		for _, p := range ld.Platforms {
+			b = appendU24(b, p.Id)
+			m := len(b)
+			b = append(b, 0)
+			for _, pn := range p.Names {
+				b = appendU24(b, uint32(pn.Lang))
+				b = append(b, byte(len(pn.Name)))
+				b = append(b, pn.Name...)
+			}
+			b[m] = byte(len(b) - m - 1)
+		}
+		b[n] = byte(len(b) - n - 1)
+	case t == INTTableLinkage:
+		b = append(b, ld.TableType)
+		if ld.TableType == 0x02 {
+			b = appendU16(b, ld.BouquetId)
+		}
+	case t == EventLinkage:
+		b = appendU16(b, ld.Event.EventId)
+		flags := byte(0x3f)
+		if ld.Event.Listed {
+			flags |= 0x80
+		}
+		if ld.Event.Simulcast {
+			flags |= 0x40
+		}
+		b = append(b, flags)
+	case t >= ExtEventLinkageFirst && t <= ExtEventLinkageLast:
+		n := len(b)
+		b = append(b, 0)
+		for _, e := range ld.ExtEvents {
+			b = appendU16(b, e.EventId)
+			flags := e.LinkType&0x03<<4 | e.TargetIdType&0x03<<2
+			if e.Listed {
+				flags |= 0x80
+			}
+			if e.Simulcast {
+				flags |= 0x40
+			}
+			if e.HasOrgNetId {
+				flags |= 0x02
+			}
+			if e.HasServiceId {
+				flags |= 0x01
+			}
+			b = append(b, flags)
+			if e.TargetIdType == 3 {
+				b = appendU16(b, e.UserId)
+				continue
+			}
+			if e.TargetIdType == 1 {
+				b = appendU16(b, e.MuxId)
+			}
+			if e.HasOrgNetId {
+				b = appendU16(b, e.OrgNetId)
+			}
+			if e.HasServiceId {
+				b = appendU16(b, e.ServiceId)
+			}
+		}
+		b[n] = byte(len(b) - n - 1)
+	}
+	b = append(b, ld.PrivateData...)
+	d := MakeDescriptor(LinkageTag, len(b))
+	copy(d.Data(), b)
+	return d
+}
+
+// NVODReference identifies one time-shifted service of NVOD reference
+// service.
+type NVODReference struct {
+	MuxId     uint16
+	OrgNetId  uint16
+	ServiceId uint16
+}
+
+type NVODReferenceDescriptor []byte
+
+func ParseNVODReferenceDescriptor(d Descriptor) (nd NVODReferenceDescriptor, ok bool) {
+	if d.Tag() != NVODReferenceTag {
+		return
+	}
+	data := d.Data()
+	if len(data)%6 != 0 {
+		return
+	}
+	return NVODReferenceDescriptor(data), true
+}
+
+// Pop returns first NVODReference from d. Remaining references are returned
+// in rd. If there is no more references to read len(rd) == 0. If an error
+// occurs rd = nil.
+func (d NVODReferenceDescriptor) Pop() (nr NVODReference, rd NVODReferenceDescriptor) {
+	if len(d) < 6 {
+		return
+	}
+	nr.MuxId = decodeU16(d[0:2])
+	nr.OrgNetId = decodeU16(d[2:4])
+	nr.ServiceId = decodeU16(d[4:6])
+	rd = d[6:]
+	return
+}
+
+func (nd *NVODReferenceDescriptor) Append(nr NVODReference) {
+	b := appendU16(*nd, nr.MuxId)
+	b = appendU16(b, nr.OrgNetId)
+	*nd = appendU16(b, nr.ServiceId)
+}
+
+func (nd NVODReferenceDescriptor) MakeDescriptor() Descriptor {
+	d := MakeDescriptor(NVODReferenceTag, len(nd))
+	copy(d.Data(), nd)
+	return d
+}
+
+// ParseTimeShiftedServiceDescriptor returns reference_service_id.
+func ParseTimeShiftedServiceDescriptor(d Descriptor) (refsid uint16, ok bool) {
+	if d.Tag() != TimeShiftedServiceTag {
+		return
+	}
+	data := d.Data()
+	if len(data) != 2 {
+		return
+	}
+	return decodeU16(data), true
+}
+
+func MakeTimeShiftedServiceDescriptor(refsid uint16) Descriptor {
+	d := MakeDescriptor(TimeShiftedServiceTag, 2)
+	encodeU16(d.Data(), refsid)
+	return d
+}
+
+type TimeShiftedEventDescriptor struct {
+	RefServiceId uint16
+	RefEventId   uint16
+}
+
+func ParseTimeShiftedEventDescriptor(d Descriptor) (ted TimeShiftedEventDescriptor, ok bool) {
+	if d.Tag() != TimeShiftedEventTag {
+		return
+	}
+	data := d.Data()
+	if len(data) != 4 {
+		return
+	}
+	ted.RefServiceId = decodeU16(data[0:2])
+	ted.RefEventId = decodeU16(data[2:4])
+	ok = true
+	return
+}
+
+func (ted TimeShiftedEventDescriptor) MakeDescriptor() Descriptor {
+	d := MakeDescriptor(TimeShiftedEventTag, 4)
+	data := d.Data()
+	encodeU16(data[0:2], ted.RefServiceId)
+	encodeU16(data[2:4], ted.RefEventId)
+	return d
+}
+
+// MosaicCell is a logical cell of mosaic.
+type MosaicCell struct {
+	Id               byte   // logical_cell_id, 6 bits
+	PresentationInfo byte   // 3 bits
+	ElementaryCells  []byte // elementary_cell_id list, 6 bits each
+	LinkageInfo      byte   // cell_linkage_info
+	BouquetId        uint16 // if LinkageInfo == 1
+	OrgNetId         uint16 // if LinkageInfo >= 2
+	MuxId            uint16 // if LinkageInfo >= 2
+	ServiceId        uint16 // if LinkageInfo >= 2
+	EventId          uint16 // if LinkageInfo == 4
+}
+
+type MosaicDescriptor struct {
+	EntryPoint bool
+	HCells     int // number of horizontal elementary cells (1..8)
+	VCells     int // number of vertical elementary cells (1..8)
+	Cells      []MosaicCell
+}
+
+func ParseMosaicDescriptor(d Descriptor) (md MosaicDescriptor, ok bool) {
+	if d.Tag() != MosaicTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 1 {
+		return
+	}
+	md.EntryPoint = data[0]&0x80 != 0
+	md.HCells = int(data[0]>>4&0x07) + 1
+	md.VCells = int(data[0]&0x07) + 1
+	data = data[1:]
+	for len(data) > 0 {
+		if len(data) < 3 || len(data) < 3+int(data[2])+1 {
+			return
+		}
+		c := MosaicCell{
+			Id:               data[0] >> 2,
+			PresentationInfo: data[1] & 0x07,
+		}
+		n := int(data[2])
+		c.ElementaryCells = make([]byte, n)
+		for i, e := range data[3 : 3+n] {
+			c.ElementaryCells[i] = e & 0x3f
+		}
+		c.LinkageInfo = data[3+n]
+		data = data[4+n:]
+		var fs []*uint16
+		switch c.LinkageInfo {
+		case 0x01:
+			fs = []*uint16{&c.BouquetId}
+		case 0x02, 0x03:
+			fs = []*uint16{&c.OrgNetId, &c.MuxId, &c.ServiceId}
+		case 0x04:
+			fs = []*uint16{&c.OrgNetId, &c.MuxId, &c.ServiceId, &c.EventId}
+		}
+		for _, f := range fs {
+			if len(data) < 2 {
+				return
+			}
+			*f = decodeU16(data[0:2])
+			data = data[2:]
+		}
+		md.Cells = append(md.Cells, c)
+	}
+	ok = true
+	return
+}
+
+func (md MosaicDescriptor) MakeDescriptor() Descriptor {
+	if md.HCells < 1 || md.HCells > 8 || md.VCells < 1 || md.VCells > 8 {
+		panic("psi: bad number of mosaic cells")
+	}
+	b := []byte{byte(md.HCells-1)<<4 | 0x08 | byte(md.VCells-1)}
+	if md.EntryPoint {
+		b[0] |= 0x80
+	}
+	for _, c := range md.Cells {
+		b = append(b, c.Id<<2|0x03, 0xf8|c.PresentationInfo&0x07)
+		b = append(b, byte(len(c.ElementaryCells)))
+		for _, e := range c.ElementaryCells {
+			b = append(b, 0xc0|e&0x3f)
+		}
+		b = append(b, c.LinkageInfo)
+		switch c.LinkageInfo {
+		case 0x01:
+			b = appendU16(b, c.BouquetId)
+		case 0x02, 0x03, 0x04:
+			b = appendU16(b, c.OrgNetId)
+			b = appendU16(b, c.MuxId)
+			b = appendU16(b, c.ServiceId)
+			if c.LinkageInfo == 0x04 {
+				b = appendU16(b, c.EventId)
+			}
+		}
+	}
+	d := MakeDescriptor(MosaicTag, len(b))
+	copy(d.Data(), b)
+	return d
+}
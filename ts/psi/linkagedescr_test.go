@@ -0,0 +1,131 @@
+package psi_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ziutek/dvb/ts/psi"
+)
+
+func TestLinkageDescriptor(t *testing.T) {
+	lds := []psi.LinkageDescriptor{
+		{
+			MuxId: 1, OrgNetId: 2, ServiceId: 3, Type: psi.EPGLinkage,
+			PrivateData: []byte{},
+		},
+		{
+			MuxId: 1, OrgNetId: 2, ServiceId: 3,
+			Type:        psi.MobileHandOverLinkage,
+			HandOver:    psi.MobileHandOver{Type: 2, NetId: 4, InitialServiceId: 5},
+			PrivateData: []byte{9},
+		},
+		{
+			Type:        psi.EventLinkage,
+			Event:       psi.LinkedEvent{EventId: 7, Simulcast: true},
+			PrivateData: []byte{},
+		},
+		{
+			Type: psi.ExtEventLinkageFirst,
+			ExtEvents: []psi.ExtLinkedEvent{
+				{EventId: 1, TargetIdType: 3, UserId: 8},
+				{
+					EventId: 2, Listed: true, LinkType: 1, TargetIdType: 1,
+					MuxId: 3, HasServiceId: true, ServiceId: 4,
+				},
+			},
+			PrivateData: []byte{},
+		},
+		{
+			Type: psi.SSULinkage,
+			SSU: []psi.SSUSelector{
+				{OUI: 0x15a, Selector: []byte{1, 2}},
+				{OUI: 0x15b, Selector: []byte{}},
+			},
+			PrivateData: []byte{},
+		},
+		{
+			Type: psi.IPMACLinkage,
+			Platforms: []psi.Platform{{
+				Id:    0x0102,
+				Names: []psi.PlatformName{{Lang: 0x706f6c, Name: []byte("ab")}},
+			}},
+			PrivateData: []byte{},
+		},
+		{
+			Type: psi.INTTableLinkage, TableType: 2, BouquetId: 0x1234,
+			PrivateData: []byte{},
+		},
+	}
+	for _, ld := range lds {
+		r, ok := psi.ParseLinkageDescriptor(ld.MakeDescriptor())
+		if !ok || !reflect.DeepEqual(r, ld) {
+			t.Errorf("%v: %+v != %+v", ld.Type, r, ld)
+		}
+	}
+}
+
+func TestMosaicDescriptor(t *testing.T) {
+	md := psi.MosaicDescriptor{
+		EntryPoint: true, HCells: 2, VCells: 1,
+		Cells: []psi.MosaicCell{
+			{Id: 1, ElementaryCells: []byte{0}, LinkageInfo: 1, BouquetId: 5},
+			{
+				Id: 2, PresentationInfo: 3, ElementaryCells: []byte{1},
+				LinkageInfo: 4, OrgNetId: 1, MuxId: 2, ServiceId: 3, EventId: 4,
+			},
+		},
+	}
+	if r, ok := psi.ParseMosaicDescriptor(md.MakeDescriptor()); !ok || !reflect.DeepEqual(r, md) {
+		t.Errorf("%+v != %+v", r, md)
+	}
+	var nd psi.NVODReferenceDescriptor
+	nd.Append(psi.NVODReference{MuxId: 1, OrgNetId: 2, ServiceId: 3})
+	rnd, ok := psi.ParseNVODReferenceDescriptor(nd.MakeDescriptor())
+	if nr, _ := rnd.Pop(); !ok || nr.ServiceId != 3 {
+		t.Errorf("bad NVOD reference: %+v", nr)
+	}
+	ted := psi.TimeShiftedEventDescriptor{RefServiceId: 1, RefEventId: 2}
+	if r, ok := psi.ParseTimeShiftedEventDescriptor(ted.MakeDescriptor()); !ok || r != ted {
+		t.Errorf("bad time-shifted event: %+v", r)
+	}
+}
+
+func TestLinkageMosaicWire(t *testing.T) {
+	ho := psi.LinkageDescriptor{
+		MuxId: 1, OrgNetId: 2, ServiceId: 3,
+		Type:        psi.MobileHandOverLinkage,
+		HandOver:    psi.MobileHandOver{Type: 2, NetId: 0x0102, InitialServiceId: 0x0304},
+		PrivateData: []byte{},
+	}
+	checkWire(t, "hand-over", ho.MakeDescriptor(), []byte{
+		0x4a, 0x0c, 0x00, 0x01, 0x00, 0x02, 0x00, 0x03, 0x08,
+		0x2e, 0x01, 0x02, 0x03, 0x04,
+	}, psi.ParseLinkageDescriptor, ho)
+
+	ee := psi.LinkageDescriptor{
+		MuxId: 1, OrgNetId: 2, ServiceId: 3,
+		Type: psi.ExtEventLinkageFirst,
+		ExtEvents: []psi.ExtLinkedEvent{{
+			EventId: 0x1234, Listed: true, LinkType: 1, TargetIdType: 1,
+			HasOrgNetId: true, MuxId: 0x55, OrgNetId: 0x66,
+		}},
+		PrivateData: []byte{0xaa},
+	}
+	checkWire(t, "extended event", ee.MakeDescriptor(), []byte{
+		0x4a, 0x10, 0x00, 0x01, 0x00, 0x02, 0x00, 0x03, 0x0e,
+		0x07, 0x12, 0x34, 0x96, 0x00, 0x55, 0x00, 0x66,
+		0xaa,
+	}, psi.ParseLinkageDescriptor, ee)
+
+	md := psi.MosaicDescriptor{
+		EntryPoint: true, HCells: 2, VCells: 3,
+		Cells: []psi.MosaicCell{{
+			Id: 5, PresentationInfo: 1, ElementaryCells: []byte{0, 1},
+			LinkageInfo: 2, OrgNetId: 0x0a, MuxId: 0x0b, ServiceId: 0x0c,
+		}},
+	}
+	checkWire(t, "mosaic", md.MakeDescriptor(), []byte{
+		0x51, 0x0d, 0x9a,
+		0x17, 0xf9, 0x02, 0xc0, 0xc1, 0x02, 0x00, 0x0a, 0x00, 0x0b, 0x00, 0x0c,
+	}, psi.ParseMosaicDescriptor, md)
+}
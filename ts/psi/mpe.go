@@ -0,0 +1,164 @@
+package psi
+
+import (
+	"net"
+
+	"github.com/ziutek/dvb"
+)
+
+const MPETableId = 0x3e
+
+var (
+	ErrMPESectionSyntax = dvb.TemporaryError("incorrect MPE section syntax")
+	ErrMPEFragment      = dvb.TemporaryError("lost MPE datagram fragment")
+)
+
+// MPE represents datagram_section used by multiprotocol encapsulation
+// (EN 301 192).
+type MPE Section
+
+// AsMPE returns s as MPE or error if s isn't datagram_section.
+func AsMPE(s Section) (MPE, error) {
+	l := s.Len()
+	if s.TableId() != MPETableId || l < 3+9+4 || l > len(s) {
+		return nil, ErrMPESectionSyntax
+	}
+	m := MPE(s[:l])
+	if m.LLCSNAP() && len(m.payload()) < 8 {
+		return nil, ErrMPESectionSyntax
+	}
+	return m, nil
+}
+
+// MAC returns destination MAC address.
+func (m MPE) MAC() net.HardwareAddr {
+	return net.HardwareAddr{m[11], m[10], m[9], m[8], m[4], m[3]}
+}
+
+// PayloadScrambling returns payload_scrambling_control field.
+func (m MPE) PayloadScrambling() int {
+	return int(m[5]>>4) & 3
+}
+
+// AddressScrambling returns address_scrambling_control field.
+func (m MPE) AddressScrambling() int {
+	return int(m[5]>>2) & 3
+}
+
+// LLCSNAP returns true if payload is encapsulated in LLC/SNAP.
+func (m MPE) LLCSNAP() bool {
+	return m[5]&0x02 != 0
+}
+
+func (m MPE) Current() bool {
+	return m[5]&0x01 != 0
+}
+
+func (m MPE) Number() byte {
+	return m[6]
+}
+
+func (m MPE) LastNumber() byte {
+	return m[7]
+}
+
+func (m MPE) payload() []byte {
+	return m[12 : len(m)-4]
+}
+
+// EtherType returns EtherType from LLC/SNAP header or EtherType that
+// corresponds to IP version of datagram if there is no LLC/SNAP.
+func (m MPE) EtherType() uint16 {
+	p := m.payload()
+	if m.LLCSNAP() {
+		return decodeU16(p[6:8])
+	}
+	if len(p) > 0 && p[0]>>4 == 6 {
+		return 0x86dd
+	}
+	return 0x0800
+}
+
+// Payload returns datagram bytes (without LLC/SNAP header). It can contain
+// stuffing bytes at end.
+func (m MPE) Payload() []byte {
+	p := m.payload()
+	if m.LLCSNAP() {
+		p = p[8:]
+	}
+	return p
+}
+
+// Datagram is a datagram received using multiprotocol encapsulation.
+type Datagram struct {
+	MAC       net.HardwareAddr
+	EtherType uint16
+	Data      []byte
+}
+
+// trimIP removes stuffing bytes using length from IPv4/IPv6 header.
+func trimIP(etype uint16, d []byte) []byte {
+	n := -1
+	switch {
+	case etype == 0x0800 && len(d) >= 20:
+		n = int(decodeU16(d[2:4]))
+	case etype == 0x86dd && len(d) >= 40:
+		n = 40 + int(decodeU16(d[4:6]))
+	}
+	if n >= 0 && n <= len(d) {
+		d = d[:n]
+	}
+	return d
+}
+
+// MPEDecoder reads datagram_sections from SectionReader and assembles
+// datagrams.
+type MPEDecoder struct {
+	r   SectionReader
+	s   Section
+	buf []byte
+	n   int // next expected section number
+}
+
+func NewMPEDecoder(r SectionReader) *MPEDecoder {
+	return &MPEDecoder{r: r, s: make(Section, SectionMaxLen)}
+}
+
+// ReadDatagram reads next datagram. Returned Data is valid until next
+// ReadDatagram call. Sections with other table_id are ignored.
+func (d *MPEDecoder) ReadDatagram() (Datagram, error) {
+	for {
+		if err := d.r.ReadSection(d.s); err != nil {
+			return Datagram{}, err
+		}
+		if d.s.TableId() != MPETableId {
+			continue
+		}
+		m, err := AsMPE(d.s)
+		if err != nil {
+			return Datagram{}, err
+		}
+		if !m.Current() {
+			continue
+		}
+		n, last := int(m.Number()), int(m.LastNumber())
+		if n == 0 {
+			d.buf = d.buf[:0]
+		} else if n != d.n {
+			d.n = 0
+			return Datagram{}, ErrMPEFragment
+		}
+		d.buf = append(d.buf, m.Payload()...)
+		if n < last {
+			d.n = n + 1
+			continue
+		}
+		d.n = 0
+		etype := m.EtherType()
+		return Datagram{
+			MAC:       m.MAC(),
+			EtherType: etype,
+			Data:      trimIP(etype, d.buf),
+		}, nil
+	}
+}
@@ -0,0 +1,56 @@
+package psi_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/ziutek/dvb/ts/psi"
+)
+
+type sectionList []psi.Section
+
+func (l *sectionList) ReadSection(s psi.Section) error {
+	if len(*l) == 0 {
+		return io.EOF
+	}
+	copy(s, (*l)[0])
+	*l = (*l)[1:]
+	return nil
+}
+
+func TestMPEDecoder(t *testing.T) {
+	ip := make([]byte, 28) // IPv4 header + 8 B
+	ip[0] = 0x45
+	ip[3] = byte(len(ip))
+	mpe := func(n, last byte, data []byte) psi.Section {
+		s := psi.MakeEmptySection(psi.ISOSectionMaxLen, true)
+		s.SetTableId(psi.MPETableId)
+		s.SetPrivateSyntax(true)
+		s.SetTableIdExt(0x0605)
+		s[5] |= 0x01 // current_next_indicator
+		s.SetNumber(n)
+		s.SetLastNumber(last)
+		copy(s.Alloc(4+len(data), 0), append([]byte{4, 3, 2, 1}, data...))
+		s.MakeCRC()
+		return s
+	}
+	stuffed := append(append([]byte{}, ip[20:]...), 0xff, 0xff)
+	l := sectionList{
+		mpe(0, 1, ip[:20]),
+		{0x42, 0xb0, 0x09, 0, 0, 0xc1, 0, 0, 0, 0, 0, 0},
+		mpe(1, 1, stuffed),
+	}
+	d := psi.NewMPEDecoder(&l)
+	dg, err := d.ReadDatagram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dg.MAC.String() != "01:02:03:04:05:06" || dg.EtherType != 0x0800 ||
+		!bytes.Equal(dg.Data, ip) {
+		t.Fatalf("bad datagram: %v %x %x", dg.MAC, dg.EtherType, dg.Data)
+	}
+	if _, err := d.ReadDatagram(); err != io.EOF {
+		t.Fatal("expected EOF, got", err)
+	}
+}
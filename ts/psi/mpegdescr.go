@@ -0,0 +1,634 @@
+package psi
+
+// ISO/IEC 13818-1 descriptors.
+
+type VideoStreamDescriptor struct {
+	MultipleFrameRate    bool
+	FrameRateCode        byte // 4 bits
+	MPEG1Only            bool
+	ConstrainedParameter bool
+	StillPicture         bool
+	// Following fields are valid only if !MPEG1Only.
+	ProfileAndLevel    byte
+	ChromaFormat       byte // 2 bits
+	FrameRateExtension bool
+}
+
+func ParseVideoStreamDescriptor(d Descriptor) (vd VideoStreamDescriptor, ok bool) {
+	if d.Tag() != VideoStreamTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 1 {
+		return
+	}
+	vd.MultipleFrameRate = data[0]&0x80 != 0
+	vd.FrameRateCode = data[0] >> 3 & 0x0f
+	vd.MPEG1Only = data[0]&0x04 != 0
+	vd.ConstrainedParameter = data[0]&0x02 != 0
+	vd.StillPicture = data[0]&0x01 != 0
+	if !vd.MPEG1Only {
+		if len(data) < 3 {
+			return
+		}
+		vd.ProfileAndLevel = data[1]
+		vd.ChromaFormat = data[2] >> 6
+		vd.FrameRateExtension = data[2]&0x20 != 0
+	}
+	ok = true
+	return
+}
+
+func (vd VideoStreamDescriptor) MakeDescriptor() Descriptor {
+	n := 3
+	if vd.MPEG1Only {
+		n = 1
+	}
+	d := MakeDescriptor(VideoStreamTag, n)
+	data := d.Data()
+	data[0] = vd.FrameRateCode & 0x0f << 3
+	if vd.MultipleFrameRate {
+		data[0] |= 0x80
+	}
+	if vd.MPEG1Only {
+		data[0] |= 0x04
+	}
+	if vd.ConstrainedParameter {
+		data[0] |= 0x02
+	}
+	if vd.StillPicture {
+		data[0] |= 0x01
+	}
+	if !vd.MPEG1Only {
+		data[1] = vd.ProfileAndLevel
+		data[2] = vd.ChromaFormat<<6 | 0x1f
+		if vd.FrameRateExtension {
+			data[2] |= 0x20
+		}
+	}
+	return d
+}
+
+type AudioStreamDescriptor struct {
+	FreeFormat   bool
+	Id           byte // 1 bit
+	Layer        byte // 2 bits
+	VariableRate bool
+}
+
+func ParseAudioStreamDescriptor(d Descriptor) (ad AudioStreamDescriptor, ok bool) {
+	if d.Tag() != AudioStreamTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 1 {
+		return
+	}
+	ad.FreeFormat = data[0]&0x80 != 0
+	ad.Id = data[0] >> 6 & 0x01
+	ad.Layer = data[0] >> 4 & 0x03
+	ad.VariableRate = data[0]&0x08 != 0
+	ok = true
+	return
+}
+
+func (ad AudioStreamDescriptor) MakeDescriptor() Descriptor {
+	d := MakeDescriptor(AudioStreamTag, 1)
+	b := ad.Id&0x01<<6 | ad.Layer&0x03<<4 | 0x07
+	if ad.FreeFormat {
+		b |= 0x80
+	}
+	if ad.VariableRate {
+		b |= 0x08
+	}
+	d.Data()[0] = b
+	return d
+}
+
+type HierarchyDescriptor struct {
+	NoViewScalability     bool
+	NoTemporalScalability bool
+	NoSpatialScalability  bool
+	NoQualityScalability  bool
+	Type                  byte // hierarchy_type, 4 bits
+	LayerIndex            byte // 6 bits
+	TRefPresent           bool
+	EmbeddedLayerIndex    byte // 6 bits
+	Channel               byte // 6 bits
+}
+
+func ParseHierarchyDescriptor(d Descriptor) (hd HierarchyDescriptor, ok bool) {
+	if d.Tag() != HierarchyTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 4 {
+		return
+	}
+	hd.NoViewScalability = data[0]&0x80 != 0
+	hd.NoTemporalScalability = data[0]&0x40 != 0
+	hd.NoSpatialScalability = data[0]&0x20 != 0
+	hd.NoQualityScalability = data[0]&0x10 != 0
+	hd.Type = data[0] & 0x0f
+	hd.LayerIndex = data[1] & 0x3f
+	hd.TRefPresent = data[2]&0x80 != 0
+	hd.EmbeddedLayerIndex = data[2] & 0x3f
+	hd.Channel = data[3] & 0x3f
+	ok = true
+	return
+}
+
+func (hd HierarchyDescriptor) MakeDescriptor() Descriptor {
+	d := MakeDescriptor(HierarchyTag, 4)
+	data := d.Data()
+	data[0] = hd.Type & 0x0f
+	for i, f := range []bool{
+		hd.NoViewScalability, hd.NoTemporalScalability,
+		hd.NoSpatialScalability, hd.NoQualityScalability,
+	} {
+		if f {
+			data[0] |= 0x80 >> uint(i)
+		}
+	}
+	data[1] = 0xc0 | hd.LayerIndex&0x3f
+	data[2] = 0x40 | hd.EmbeddedLayerIndex&0x3f
+	if hd.TRefPresent {
+		data[2] |= 0x80
+	}
+	data[3] = 0xc0 | hd.Channel&0x3f
+	return d
+}
+
+type RegistrationDescriptor struct {
+	FormatId       uint32
+	AdditionalInfo []byte
+}
+
+// Format returns format_identifier as four character string (e.g. "AC-3").
+func (rd RegistrationDescriptor) Format() string {
+	var b [4]byte
+	encodeU32(b[:], rd.FormatId)
+	return string(b[:])
+}
+
+func ParseRegistrationDescriptor(d Descriptor) (rd RegistrationDescriptor, ok bool) {
+	if d.Tag() != RegistrationTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 4 {
+		return
+	}
+	rd.FormatId = decodeU32(data[0:4])
+	rd.AdditionalInfo = data[4:]
+	ok = true
+	return
+}
+
+func (rd RegistrationDescriptor) MakeDescriptor() Descriptor {
+	d := MakeDescriptor(RegistrationTag, 4+len(rd.AdditionalInfo))
+	data := d.Data()
+	encodeU32(data[0:4], rd.FormatId)
+	copy(data[4:], rd.AdditionalInfo)
+	return d
+}
+
+// ParseDataStreamAlignmentDescriptor returns alignment_type.
+func ParseDataStreamAlignmentDescriptor(d Descriptor) (typ byte, ok bool) {
+	if d.Tag() != DataStreamAlignmentTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 1 {
+		return
+	}
+	return data[0], true
+}
+
+func MakeDataStreamAlignmentDescriptor(typ byte) Descriptor {
+	d := MakeDescriptor(DataStreamAlignmentTag, 1)
+	d.Data()[0] = typ
+	return d
+}
+
+type SystemClockDescriptor struct {
+	ExternalClockRef bool
+	AccuracyInt      byte // 6 bits
+	AccuracyExp      byte // 3 bits
+}
+
+// Accuracy returns clock accuracy in ppm.
+func (sd SystemClockDescriptor) Accuracy() float64 {
+	if sd.AccuracyInt == 0 {
+		return 30 // default accuracy
+	}
+	a := float64(sd.AccuracyInt)
+	for i := byte(0); i < sd.AccuracyExp; i++ {
+		a /= 10
+	}
+	return a
+}
+
+func ParseSystemClockDescriptor(d Descriptor) (sd SystemClockDescriptor, ok bool) {
+	if d.Tag() != SystemClockTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 2 {
+		return
+	}
+	sd.ExternalClockRef = data[0]&0x80 != 0
+	sd.AccuracyInt = data[0] & 0x3f
+	sd.AccuracyExp = data[1] >> 5
+	ok = true
+	return
+}
+
+func (sd SystemClockDescriptor) MakeDescriptor() Descriptor {
+	d := MakeDescriptor(SystemClockTag, 2)
+	data := d.Data()
+	data[0] = 0x40 | sd.AccuracyInt&0x3f
+	if sd.ExternalClockRef {
+		data[0] |= 0x80
+	}
+	data[1] = sd.AccuracyExp<<5 | 0x1f
+	return d
+}
+
+type MultiplexBufferUtilizationDescriptor struct {
+	BoundValid     bool
+	LTWOffsetLower uint16 // 15 bits
+	LTWOffsetUpper uint16 // 15 bits
+}
+
+func ParseMultiplexBufferUtilizationDescriptor(d Descriptor) (md MultiplexBufferUtilizationDescriptor, ok bool) {
+	if d.Tag() != MultiplexBufferUtilizationTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 4 {
+		return
+	}
+	md.BoundValid = data[0]&0x80 != 0
+	md.LTWOffsetLower = decodeU16(data[0:2]) & 0x7fff
+	md.LTWOffsetUpper = decodeU16(data[2:4]) & 0x7fff
+	ok = true
+	return
+}
+
+func (md MultiplexBufferUtilizationDescriptor) MakeDescriptor() Descriptor {
+	d := MakeDescriptor(MultiplexBufferUtilizationTag, 4)
+	data := d.Data()
+	lower := md.LTWOffsetLower & 0x7fff
+	if md.BoundValid {
+		lower |= 0x8000
+	}
+	encodeU16(data[0:2], lower)
+	encodeU16(data[2:4], 0x8000|md.LTWOffsetUpper&0x7fff)
+	return d
+}
+
+// ParseMaximumBitrateDescriptor returns maximum bitrate in bits per second.
+func ParseMaximumBitrateDescriptor(d Descriptor) (bitrate int, ok bool) {
+	if d.Tag() != MaximumBitrateTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 3 {
+		return
+	}
+	return int(decodeU24(data[0:3])&0x3fffff) * 50 * 8, true
+}
+
+// MakeMaximumBitrateDescriptor returns maximum_bitrate_descriptor for bitrate
+// in bits per second (rounded up to 400 b/s).
+func MakeMaximumBitrateDescriptor(bitrate int) Descriptor {
+	d := MakeDescriptor(MaximumBitrateTag, 3)
+	v := uint32(bitrate+399) / 400
+	if v > 0x3fffff {
+		panic("psi: bitrate too high for maximum_bitrate_descriptor")
+	}
+	encodeU24(d.Data(), 0xc00000|v)
+	return d
+}
+
+type SmoothingBufferDescriptor struct {
+	LeakRate int // sb_leak_rate in bits per second
+	Size     int // sb_size in bytes
+}
+
+func ParseSmoothingBufferDescriptor(d Descriptor) (sd SmoothingBufferDescriptor, ok bool) {
+	if d.Tag() != SmoothingBufferTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 6 {
+		return
+	}
+	sd.LeakRate = int(decodeU24(data[0:3])&0x3fffff) * 400
+	sd.Size = int(decodeU24(data[3:6]) & 0x3fffff)
+	ok = true
+	return
+}
+
+func (sd SmoothingBufferDescriptor) MakeDescriptor() Descriptor {
+	rate := uint32(sd.LeakRate+399) / 400
+	if rate > 0x3fffff || uint(sd.Size) > 0x3fffff {
+		panic("psi: bad smoothing buffer parameters")
+	}
+	d := MakeDescriptor(SmoothingBufferTag, 6)
+	data := d.Data()
+	encodeU24(data[0:3], 0xc00000|rate)
+	encodeU24(data[3:6], 0xc00000|uint32(sd.Size))
+	return d
+}
+
+// ParseSTDDescriptor returns leak_valid_flag.
+func ParseSTDDescriptor(d Descriptor) (leakValid bool, ok bool) {
+	if d.Tag() != STDTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 1 {
+		return
+	}
+	return data[0]&0x01 != 0, true
+}
+
+func MakeSTDDescriptor(leakValid bool) Descriptor {
+	d := MakeDescriptor(STDTag, 1)
+	d.Data()[0] = 0xfe
+	if leakValid {
+		d.Data()[0] = 0xff
+	}
+	return d
+}
+
+type AVCVideoDescriptor struct {
+	ProfileIdc                byte
+	ConstraintFlags           byte // constraint_set0..5 flags and AVC_compatible_flags
+	LevelIdc                  byte
+	StillPresent              bool
+	Picture24Hour             bool
+	FramePackingSEINotPresent bool
+}
+
+func ParseAVCVideoDescriptor(d Descriptor) (ad AVCVideoDescriptor, ok bool) {
+	if d.Tag() != AVCVideoTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 4 {
+		return
+	}
+	ad.ProfileIdc = data[0]
+	ad.ConstraintFlags = data[1]
+	ad.LevelIdc = data[2]
+	ad.StillPresent = data[3]&0x80 != 0
+	ad.Picture24Hour = data[3]&0x40 != 0
+	ad.FramePackingSEINotPresent = data[3]&0x20 != 0
+	ok = true
+	return
+}
+
+func (ad AVCVideoDescriptor) MakeDescriptor() Descriptor {
+	d := MakeDescriptor(AVCVideoTag, 4)
+	data := d.Data()
+	data[0] = ad.ProfileIdc
+	data[1] = ad.ConstraintFlags
+	data[2] = ad.LevelIdc
+	data[3] = 0x1f
+	if ad.StillPresent {
+		data[3] |= 0x80
+	}
+	if ad.Picture24Hour {
+		data[3] |= 0x40
+	}
+	if ad.FramePackingSEINotPresent {
+		data[3] |= 0x20
+	}
+	return d
+}
+
+type AVCTimingHRDDescriptor struct {
+	HRDManagementValid bool
+	HasTimingInfo      bool
+	// Following fields are valid only if HasTimingInfo.
+	Clock90kHz                 bool
+	N, K                       uint32 // system clock frequency is 27MHz * N / K if !Clock90kHz
+	NumUnitsInTick             uint32
+	FixedFrameRate             bool
+	TemporalPOC                bool
+	PictureToDisplayConversion bool
+}
+
+func ParseAVCTimingHRDDescriptor(d Descriptor) (td AVCTimingHRDDescriptor, ok bool) {
+	if d.Tag() != AVCTimingHRDTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 2 {
+		return
+	}
+	td.HRDManagementValid = data[0]&0x80 != 0
+	td.HasTimingInfo = data[0]&0x01 != 0
+	data = data[1:]
+	if td.HasTimingInfo {
+		if len(data) < 1 {
+			return
+		}
+		td.Clock90kHz = data[0]&0x80 != 0
+		data = data[1:]
+		if !td.Clock90kHz {
+			if len(data) < 8 {
+				return
+			}
+			td.N = decodeU32(data[0:4])
+			td.K = decodeU32(data[4:8])
+			data = data[8:]
+		}
+		if len(data) < 4 {
+			return
+		}
+		td.NumUnitsInTick = decodeU32(data[0:4])
+		data = data[4:]
+	}
+	if len(data) < 1 {
+		return
+	}
+	td.FixedFrameRate = data[0]&0x80 != 0
+	td.TemporalPOC = data[0]&0x40 != 0
+	td.PictureToDisplayConversion = data[0]&0x20 != 0
+	ok = true
+	return
+}
+
+func (td AVCTimingHRDDescriptor) MakeDescriptor() Descriptor {
+	b := []byte{0x7e}
+	if td.HRDManagementValid {
+		b[0] |= 0x80
+	}
+	if td.HasTimingInfo {
+		b[0] |= 0x01
+		if td.Clock90kHz {
+			b = append(b, 0xff)
+		} else {
+			b = append(b, 0x7f)
+			b = appendU32(b, td.N)
+			b = appendU32(b, td.K)
+		}
+		b = appendU32(b, td.NumUnitsInTick)
+	}
+	flags := byte(0x1f)
+	if td.FixedFrameRate {
+		flags |= 0x80
+	}
+	if td.TemporalPOC {
+		flags |= 0x40
+	}
+	if td.PictureToDisplayConversion {
+		flags |= 0x20
+	}
+	b = append(b, flags)
+	d := MakeDescriptor(AVCTimingHRDTag, len(b))
+	copy(d.Data(), b)
+	return d
+}
+
+type MPEG4AudioExtensionDescriptor struct {
+	ProfileLevels       []byte // audioProfileLevelIndication list (max 15)
+	AudioSpecificConfig []byte // nil if not present
+}
+
+func ParseMPEG4AudioExtensionDescriptor(d Descriptor) (md MPEG4AudioExtensionDescriptor, ok bool) {
+	if d.Tag() != MPEG4AudioExtensionTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 1 || len(data) < 1+int(data[0]&0x0f) {
+		return
+	}
+	asc := data[0]&0x80 != 0
+	n := int(data[0] & 0x0f)
+	md.ProfileLevels = data[1 : 1+n]
+	data = data[1+n:]
+	if asc {
+		if len(data) < 1 || len(data) < 1+int(data[0]) {
+			return
+		}
+		md.AudioSpecificConfig = data[1 : 1+int(data[0])]
+	}
+	ok = true
+	return
+}
+
+func (md MPEG4AudioExtensionDescriptor) MakeDescriptor() Descriptor {
+	if len(md.ProfileLevels) > 15 {
+		panic("psi: too many audio profile levels")
+	}
+	b := []byte{0x70 | byte(len(md.ProfileLevels))}
+	b = append(b, md.ProfileLevels...)
+	if md.AudioSpecificConfig != nil {
+		b[0] |= 0x80
+		b = append(b, byte(len(md.AudioSpecificConfig)))
+		b = append(b, md.AudioSpecificConfig...)
+	}
+	d := MakeDescriptor(MPEG4AudioExtensionTag, len(b))
+	copy(d.Data(), b)
+	return d
+}
+
+type HEVCVideoDescriptor struct {
+	ProfileSpace              byte // 2 bits
+	Tier                      bool
+	ProfileIdc                byte // 5 bits
+	ProfileCompatibility      uint32
+	ProgressiveSource         bool
+	InterlacedSource          bool
+	NonPackedConstraint       bool
+	FrameOnlyConstraint       bool
+	Copied44Bits              uint64 // 44 bits
+	LevelIdc                  byte
+	StillPresent              bool
+	Picture24Hour             bool
+	SubPicHRDParamsNotPresent bool
+	HDRWCGIdc                 byte // 2 bits
+	HasTemporalLayerSubset    bool
+	TemporalIdMin             byte // 3 bits
+	TemporalIdMax             byte // 3 bits
+}
+
+func ParseHEVCVideoDescriptor(d Descriptor) (hd HEVCVideoDescriptor, ok bool) {
+	if d.Tag() != HEVCVideoTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 13 {
+		return
+	}
+	hd.ProfileSpace = data[0] >> 6
+	hd.Tier = data[0]&0x20 != 0
+	hd.ProfileIdc = data[0] & 0x1f
+	hd.ProfileCompatibility = decodeU32(data[1:5])
+	v := uint64(decodeU16(data[5:7]))<<32 | uint64(decodeU32(data[7:11]))
+	hd.ProgressiveSource = v&(1<<47) != 0
+	hd.InterlacedSource = v&(1<<46) != 0
+	hd.NonPackedConstraint = v&(1<<45) != 0
+	hd.FrameOnlyConstraint = v&(1<<44) != 0
+	hd.Copied44Bits = v & (1<<44 - 1)
+	hd.LevelIdc = data[11]
+	hd.HasTemporalLayerSubset = data[12]&0x80 != 0
+	hd.StillPresent = data[12]&0x40 != 0
+	hd.Picture24Hour = data[12]&0x20 != 0
+	hd.SubPicHRDParamsNotPresent = data[12]&0x10 != 0
+	hd.HDRWCGIdc = data[12] & 0x03
+	if hd.HasTemporalLayerSubset {
+		if len(data) < 15 {
+			return
+		}
+		hd.TemporalIdMin = data[13] >> 5
+		hd.TemporalIdMax = data[14] >> 5
+	}
+	ok = true
+	return
+}
+
+func (hd HEVCVideoDescriptor) MakeDescriptor() Descriptor {
+	n := 13
+	if hd.HasTemporalLayerSubset {
+		n += 2
+	}
+	d := MakeDescriptor(HEVCVideoTag, n)
+	data := d.Data()
+	data[0] = hd.ProfileSpace<<6 | hd.ProfileIdc&0x1f
+	if hd.Tier {
+		data[0] |= 0x20
+	}
+	encodeU32(data[1:5], hd.ProfileCompatibility)
+	v := hd.Copied44Bits & (1<<44 - 1)
+	for i, f := range []bool{
+		hd.ProgressiveSource, hd.InterlacedSource,
+		hd.NonPackedConstraint, hd.FrameOnlyConstraint,
+	} {
+		if f {
+			v |= 1 << uint(47-i)
+		}
+	}
+	encodeU16(data[5:7], uint16(v>>32))
+	encodeU32(data[7:11], uint32(v))
+	data[11] = hd.LevelIdc
+	data[12] = 0x0c | hd.HDRWCGIdc&0x03
+	for i, f := range []bool{
+		hd.HasTemporalLayerSubset, hd.StillPresent,
+		hd.Picture24Hour, hd.SubPicHRDParamsNotPresent,
+	} {
+		if f {
+			data[12] |= 0x80 >> uint(i)
+		}
+	}
+	if hd.HasTemporalLayerSubset {
+		data[13] = hd.TemporalIdMin<<5 | 0x1f
+		data[14] = hd.TemporalIdMax<<5 | 0x1f
+	}
+	return d
+}
@@ -0,0 +1,113 @@
+package psi_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/ziutek/dvb/ts/psi"
+)
+
+func TestMPEGDescriptors(t *testing.T) {
+	check := func(name string, d psi.Descriptor, parse, want interface{}) {
+		r := reflect.ValueOf(parse).Call([]reflect.Value{reflect.ValueOf(d)})
+		if !r[1].Bool() {
+			t.Errorf("%s: can't parse % x", name, d)
+			return
+		}
+		if got := r[0].Interface(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: %+v != %+v", name, got, want)
+		}
+	}
+	vd := psi.VideoStreamDescriptor{
+		FrameRateCode: 3, ProfileAndLevel: 0x48, ChromaFormat: 1,
+	}
+	check("video", vd.MakeDescriptor(), psi.ParseVideoStreamDescriptor, vd)
+	hd := psi.HierarchyDescriptor{
+		NoTemporalScalability: true, Type: 3, LayerIndex: 1,
+		TRefPresent: true, EmbeddedLayerIndex: 2, Channel: 5,
+	}
+	check("hierarchy", hd.MakeDescriptor(), psi.ParseHierarchyDescriptor, hd)
+	rd := psi.RegistrationDescriptor{FormatId: 0x48455643, AdditionalInfo: []byte{}}
+	if rd.Format() != "HEVC" {
+		t.Error("bad format:", rd.Format())
+	}
+	check("registration", rd.MakeDescriptor(), psi.ParseRegistrationDescriptor, rd)
+	check(
+		"max bitrate", psi.MakeMaximumBitrateDescriptor(15e6),
+		psi.ParseMaximumBitrateDescriptor, int(15e6),
+	)
+	sb := psi.SmoothingBufferDescriptor{LeakRate: 8000, Size: 1024}
+	check("smoothing buffer", sb.MakeDescriptor(), psi.ParseSmoothingBufferDescriptor, sb)
+	avc := psi.AVCVideoDescriptor{
+		ProfileIdc: 100, ConstraintFlags: 0x0c, LevelIdc: 40,
+		FramePackingSEINotPresent: true,
+	}
+	check("AVC", avc.MakeDescriptor(), psi.ParseAVCVideoDescriptor, avc)
+	hrd := psi.AVCTimingHRDDescriptor{
+		HasTimingInfo: true, N: 1, K: 300, NumUnitsInTick: 1800,
+		FixedFrameRate: true,
+	}
+	check("AVC timing", hrd.MakeDescriptor(), psi.ParseAVCTimingHRDDescriptor, hrd)
+	m4a := psi.MPEG4AudioExtensionDescriptor{
+		ProfileLevels: []byte{0x58}, AudioSpecificConfig: []byte{0x13, 0x10},
+	}
+	check("MPEG-4 audio", m4a.MakeDescriptor(), psi.ParseMPEG4AudioExtensionDescriptor, m4a)
+	hevc := psi.HEVCVideoDescriptor{
+		ProfileIdc: 2, ProfileCompatibility: 0x20000000,
+		ProgressiveSource: true, FrameOnlyConstraint: true, Copied44Bits: 5,
+		LevelIdc: 150, HDRWCGIdc: 2,
+		HasTemporalLayerSubset: true, TemporalIdMax: 1,
+	}
+	check("HEVC", hevc.MakeDescriptor(), psi.ParseHEVCVideoDescriptor, hevc)
+}
+
+// checkWire checks that d has exactly the wire format bytes b and that b
+// parses to want.
+func checkWire(t *testing.T, name string, d psi.Descriptor, b []byte, parse, want interface{}) {
+	if !bytes.Equal(d, b) {
+		t.Errorf("%s: % x\nexpected % x", name, []byte(d), b)
+	}
+	r := reflect.ValueOf(parse).Call([]reflect.Value{reflect.ValueOf(psi.Descriptor(b))})
+	if !r[1].Bool() {
+		t.Errorf("%s: can't parse % x", name, b)
+		return
+	}
+	if got := r[0].Interface(); !reflect.DeepEqual(got, want) {
+		t.Errorf("%s: %+v != %+v", name, got, want)
+	}
+}
+
+func TestMPEGDescriptorsWire(t *testing.T) {
+	// ITU-T H.222.0 2.6.95
+	hevc := psi.HEVCVideoDescriptor{
+		ProfileIdc: 2, ProfileCompatibility: 0x20000000,
+		ProgressiveSource: true, FrameOnlyConstraint: true, Copied44Bits: 5,
+		LevelIdc: 150, HDRWCGIdc: 2,
+		HasTemporalLayerSubset: true, TemporalIdMin: 2, TemporalIdMax: 1,
+	}
+	checkWire(t, "HEVC", hevc.MakeDescriptor(), []byte{
+		0x38, 15,
+		0x02,                   // profile_space, tier_flag, profile_idc
+		0x20, 0x00, 0x00, 0x00, // profile_compatibility_indication
+		0x90, 0x00, 0x00, 0x00, 0x00, 0x05, // source flags, copied_44bits
+		0x96,       // level_idc
+		0x8e,       // temporal_layer_subset_flag, ..., HDR_WCG_idc
+		0x5f, 0x3f, // temporal_id_min, temporal_id_max
+	}, psi.ParseHEVCVideoDescriptor, hevc)
+
+	// ITU-T H.222.0 2.6.66
+	hrd := psi.AVCTimingHRDDescriptor{
+		HasTimingInfo: true, N: 1, K: 300, NumUnitsInTick: 1800,
+		FixedFrameRate: true,
+	}
+	checkWire(t, "AVC timing", hrd.MakeDescriptor(), []byte{
+		0x2a, 15,
+		0x7f,                   // hrd_management_valid_flag, picture_and_timing_info_present
+		0x7f,                   // 90kHz_flag
+		0x00, 0x00, 0x00, 0x01, // N
+		0x00, 0x00, 0x01, 0x2c, // K
+		0x00, 0x00, 0x07, 0x08, // num_units_in_tick
+		0x9f, // fixed_frame_rate_flag, temporal_poc_flag, ...
+	}, psi.ParseAVCTimingHRDDescriptor, hrd)
+}
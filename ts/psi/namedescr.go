@@ -0,0 +1,241 @@
+package psi
+
+// MultilingualNameDescriptor represents multilingual_network_name_descriptor
+// or multilingual_bouquet_name_descriptor.
+type MultilingualNameDescriptor []byte
+
+func ParseMultilingualNetworkNameDescriptor(d Descriptor) (mnd MultilingualNameDescriptor, ok bool) {
+	if d.Tag() != MultilingualNetworkNameTag {
+		return
+	}
+	return MultilingualNameDescriptor(d.Data()), true
+}
+
+func ParseMultilingualBouquetNameDescriptor(d Descriptor) (mnd MultilingualNameDescriptor, ok bool) {
+	if d.Tag() != MultilingualBouquetNameTag {
+		return
+	}
+	return MultilingualNameDescriptor(d.Data()), true
+}
+
+// popLangString decodes ISO_639_language_code, length and text.
+func popLangString(b []byte) (ls LangString, rb []byte, ok bool) {
+	if len(b) < 4 || len(b) < 4+int(b[3]) {
+		return
+	}
+	ls.Lang = ISO639LangCode(decodeU24(b[0:3]))
+	ls.Text = DecodeText(b[4 : 4+int(b[3])])
+	return ls, b[4+int(b[3]):], true
+}
+
+func appendText(b []byte, s string) []byte {
+	t := EncodeText(s)
+	if len(t) > 255 {
+		panic(descrDataTooLong)
+	}
+	b = append(b, byte(len(t)))
+	return append(b, t...)
+}
+
+// Pop returns first name from d. Remaining names are returned in rd. If there
+// is no more names to read len(rd) == 0. If an error occurs rd = nil.
+func (d MultilingualNameDescriptor) Pop() (name LangString, rd MultilingualNameDescriptor) {
+	name, b, ok := popLangString(d)
+	if !ok {
+		return
+	}
+	return name, MultilingualNameDescriptor(b)
+}
+
+func (mnd *MultilingualNameDescriptor) Append(name LangString) {
+	*mnd = appendText(appendU24(*mnd, uint32(name.Lang)), name.Text)
+}
+
+func makeMultilingualNameDescriptor(tag DescriptorTag, names []LangString) Descriptor {
+	var mnd MultilingualNameDescriptor
+	for _, name := range names {
+		mnd.Append(name)
+	}
+	d := MakeDescriptor(tag, len(mnd))
+	copy(d.Data(), mnd)
+	return d
+}
+
+func MakeMultilingualNetworkNameDescriptor(names ...LangString) Descriptor {
+	return makeMultilingualNameDescriptor(MultilingualNetworkNameTag, names)
+}
+
+func MakeMultilingualBouquetNameDescriptor(names ...LangString) Descriptor {
+	return makeMultilingualNameDescriptor(MultilingualBouquetNameTag, names)
+}
+
+// LangServiceName is a provider and service name in one language.
+type LangServiceName struct {
+	Lang     ISO639LangCode
+	Provider string
+	Service  string
+}
+
+type MultilingualServiceNameDescriptor []byte
+
+func ParseMultilingualServiceNameDescriptor(d Descriptor) (msd MultilingualServiceNameDescriptor, ok bool) {
+	if d.Tag() != MultilingualServiceNameTag {
+		return
+	}
+	return MultilingualServiceNameDescriptor(d.Data()), true
+}
+
+// Pop returns first LangServiceName from d. Remaining names are returned in
+// rd. If there is no more names to read len(rd) == 0. If an error occurs
+// rd = nil.
+func (d MultilingualServiceNameDescriptor) Pop() (name LangServiceName, rd MultilingualServiceNameDescriptor) {
+	p, b, ok := popLangString(d)
+	if !ok || len(b) < 1 || len(b) < 1+int(b[0]) {
+		return
+	}
+	name.Lang = p.Lang
+	name.Provider = p.Text
+	name.Service = DecodeText(b[1 : 1+int(b[0])])
+	rd = b[1+int(b[0]):]
+	return
+}
+
+func (msd *MultilingualServiceNameDescriptor) Append(name LangServiceName) {
+	b := appendText(appendU24(*msd, uint32(name.Lang)), name.Provider)
+	*msd = appendText(b, name.Service)
+}
+
+func (msd MultilingualServiceNameDescriptor) MakeDescriptor() Descriptor {
+	d := MakeDescriptor(MultilingualServiceNameTag, len(msd))
+	copy(d.Data(), msd)
+	return d
+}
+
+type MultilingualComponentDescriptor struct {
+	ComponentTag byte
+	Texts        []LangString
+}
+
+func ParseMultilingualComponentDescriptor(d Descriptor) (mcd MultilingualComponentDescriptor, ok bool) {
+	if d.Tag() != MultilingualComponentTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 1 {
+		return
+	}
+	mcd.ComponentTag = data[0]
+	data = data[1:]
+	for len(data) > 0 {
+		var ls LangString
+		if ls, data, ok = popLangString(data); !ok {
+			return
+		}
+		mcd.Texts = append(mcd.Texts, ls)
+	}
+	ok = true
+	return
+}
+
+func (mcd MultilingualComponentDescriptor) MakeDescriptor() Descriptor {
+	b := []byte{mcd.ComponentTag}
+	for _, ls := range mcd.Texts {
+		b = appendText(appendU24(b, uint32(ls.Lang)), ls.Text)
+	}
+	d := MakeDescriptor(MultilingualComponentTag, len(b))
+	copy(d.Data(), b)
+	return d
+}
+
+type CountryAvailabilityDescriptor struct {
+	// Available reports whether service is available (true) or not
+	// available (false) in Countries.
+	Available bool
+	Countries []CountryCode
+}
+
+func ParseCountryAvailabilityDescriptor(d Descriptor) (cad CountryAvailabilityDescriptor, ok bool) {
+	if d.Tag() != CountryAvailabilityTag {
+		return
+	}
+	data := d.Data()
+	if len(data) < 1 || (len(data)-1)%3 != 0 {
+		return
+	}
+	cad.Available = data[0]&0x80 != 0
+	data = data[1:]
+	cad.Countries = make([]CountryCode, len(data)/3)
+	for i := range cad.Countries {
+		cad.Countries[i] = CountryCode(decodeU24(data[i*3:]))
+	}
+	ok = true
+	return
+}
+
+// AvailableIn returns true if service is available in country c.
+func (cad CountryAvailabilityDescriptor) AvailableIn(c CountryCode) bool {
+	for _, cc := range cad.Countries {
+		if cc == c {
+			return cad.Available
+		}
+	}
+	return !cad.Available
+}
+
+func (cad CountryAvailabilityDescriptor) MakeDescriptor() Descriptor {
+	d := MakeDescriptor(CountryAvailabilityTag, 1+len(cad.Countries)*3)
+	data := d.Data()
+	data[0] = 0x7f
+	if cad.Available {
+		data[0] = 0xff
+	}
+	for i, c := range cad.Countries {
+		encodeU24(data[1+i*3:], uint32(c))
+	}
+	return d
+}
+
+// Name returns service name in first available language from langs. If there
+// is no multilingual_service_name_descriptor with any of langs Name returns
+// name from service_descriptor or, if there is no service_descriptor, first
+// multilingual name.
+func (si ServiceInfo) Name(langs ...ISO639LangCode) string {
+	var (
+		names []LangServiceName
+		name  string
+		found bool
+	)
+	dl := si.Descriptors()
+	for len(dl) != 0 {
+		var d Descriptor
+		if d, dl = dl.Pop(); d == nil {
+			break
+		}
+		if sd, ok := ParseServiceDescriptor(d); ok && !found {
+			name, found = DecodeText(sd.ServiceName), true
+			continue
+		}
+		msd, ok := ParseMultilingualServiceNameDescriptor(d)
+		if !ok {
+			continue
+		}
+		for len(msd) != 0 {
+			var n LangServiceName
+			if n, msd = msd.Pop(); msd == nil {
+				break
+			}
+			names = append(names, n)
+		}
+	}
+	for _, lang := range langs {
+		for _, n := range names {
+			if n.Lang == lang {
+				return n.Service
+			}
+		}
+	}
+	if !found && len(names) > 0 {
+		return names[0].Service
+	}
+	return name
+}
@@ -0,0 +1,69 @@
+package psi_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ziutek/dvb/ts/psi"
+)
+
+const (
+	langPol psi.ISO639LangCode = 0x706f6c
+	langEng psi.ISO639LangCode = 0x656e67
+)
+
+func TestServiceInfoName(t *testing.T) {
+	var msd psi.MultilingualServiceNameDescriptor
+	msd.Append(psi.LangServiceName{Lang: langEng, Provider: "P", Service: "One"})
+	msd.Append(psi.LangServiceName{Lang: langPol, Provider: "P", Service: "Jeden"})
+
+	si := psi.MakeServiceInfo()
+	si.AppendDescriptors(psi.MakeServiceDescriptor(1, "P", "1"))
+	si.AppendDescriptors(msd.MakeDescriptor())
+	cases := []struct {
+		langs []psi.ISO639LangCode
+		name  string
+	}{
+		{[]psi.ISO639LangCode{langPol, langEng}, "Jeden"},
+		{[]psi.ISO639LangCode{0x646575, langEng}, "One"},
+		{nil, "1"},
+	}
+	for _, c := range cases {
+		if name := si.Name(c.langs...); name != c.name {
+			t.Errorf("%v: %q != %q", c.langs, name, c.name)
+		}
+	}
+	si.ClearDescriptors()
+	si.AppendDescriptors(msd.MakeDescriptor())
+	if name := si.Name(); name != "One" {
+		t.Errorf("%q != One", name)
+	}
+}
+
+func TestMultilingualDescriptors(t *testing.T) {
+	names := []psi.LangString{{Lang: langPol, Text: "Siec"}, {Lang: langEng, Text: "Net"}}
+	mnd, ok := psi.ParseMultilingualNetworkNameDescriptor(
+		psi.MakeMultilingualNetworkNameDescriptor(names...),
+	)
+	if !ok {
+		t.Fatal("can't parse multilingual network name descriptor")
+	}
+	for _, n := range names {
+		var rn psi.LangString
+		if rn, mnd = mnd.Pop(); rn != n {
+			t.Errorf("%+v != %+v", rn, n)
+		}
+	}
+	if mnd == nil || len(mnd) != 0 {
+		t.Error("bad descriptor end")
+	}
+	mcd := psi.MultilingualComponentDescriptor{ComponentTag: 3, Texts: names}
+	if r, ok := psi.ParseMultilingualComponentDescriptor(mcd.MakeDescriptor()); !ok || !reflect.DeepEqual(r, mcd) {
+		t.Errorf("%+v != %+v", r, mcd)
+	}
+	cad := psi.CountryAvailabilityDescriptor{Countries: []psi.CountryCode{0x504f4c}}
+	r, ok := psi.ParseCountryAvailabilityDescriptor(cad.MakeDescriptor())
+	if !ok || !reflect.DeepEqual(r, cad) || r.AvailableIn(0x504f4c) || !r.AvailableIn(0x444555) {
+		t.Errorf("bad country availability: %+v", r)
+	}
+}
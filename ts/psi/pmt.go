@@ -1,6 +1,8 @@
 package psi
 
 import (
+	"errors"
+
 	"github.com/ziutek/dvb"
 )
 
@@ -60,8 +62,33 @@ func (p PMT) ESInfo() ESInfoList {
 var (
 	ErrPMTSectionSyntax = dvb.TemporaryError("incorrect PMT section syntax")
 	ErrPMTProgInfoLen   = dvb.TemporaryError("incorrect PMT program info length")
+	ErrPMTSpace         = errors.New("no free space in PMT section")
 )
 
+// AddProgramDescriptor appends d to the list of program descriptors. It
+// moves elementary stream information to make room for d, so p should have
+// free space after p.Len() bytes. AddProgramDescriptor invalidates CRC sum.
+// Use MakeCRC to recalculate it.
+func (p PMT) AddProgramDescriptor(d Descriptor) error {
+	s := Section(p)
+	l := s.Len()
+	if l+len(d) > len(s) {
+		return ErrPMTSpace
+	}
+	pil := p.progInfoLen()
+	if pil+len(d) > 0x3ff {
+		return ErrPMTProgInfoLen
+	}
+	off := 3 + 5 + 4 + pil // Offset of ES info.
+	copy(s[off+len(d):l+len(d)], s[off:l])
+	copy(s[off:], d)
+	s.setLen(l + len(d))
+	data := s.Data()
+	data[2] = data[2]&0xf0 | byte((pil+len(d))>>8)
+	data[3] = byte(pil + len(d))
+	return nil
+}
+
 // AsPMT returns s as PMT or error if s isn't PMT section. This works because
 // PMT should fit in one section (other tables occupy multiple sections.
 func AsPMT(s Section) (PMT, error) {
@@ -0,0 +1,333 @@
+package psi
+
+import (
+	"reflect"
+	"sync"
+)
+
+// DescriptorDecoder decodes descriptor d into typed value. It returns
+// ok == false if d can't be decoded.
+type DescriptorDecoder func(d Descriptor) (v interface{}, ok bool)
+
+// Scopes of private descriptors (values of pds argument of RegisterDescriptor
+// and DecodeDescriptor).
+const (
+	// EACEMSpecifier is private_data_specifier of EACEM/EICTA (it defines
+	// logical_channel_descriptor).
+	EACEMSpecifier uint32 = 0x00000028
+
+	// ATSCSpecifier isn't a real private_data_specifier. It is the ATSC
+	// format_identifier ("GA94") used as scope of descriptors carried in
+	// ATSC PSIP tables and in PMTs registered as GA94.
+	ATSCSpecifier uint32 = 0x47413934
+)
+
+type descrKey struct {
+	pds uint32
+	tag DescriptorTag
+	ext ExtDescriptorTag
+}
+
+var (
+	descrMu       sync.RWMutex
+	descrDecoders = make(map[descrKey]DescriptorDecoder)
+)
+
+// RegisterDescriptor registers decoder for descriptors with tag. pds is the
+// value of private_data_specifier that must preceed descriptor in descriptor
+// loop or 0 for descriptors that don't depend on private_data_specifier.
+// RegisterDescriptor replaces previously registered decoder. It can be used
+// by applications to add support for their own private descriptors.
+func RegisterDescriptor(pds uint32, tag DescriptorTag, dec DescriptorDecoder) {
+	descrMu.Lock()
+	descrDecoders[descrKey{pds: pds, tag: tag}] = dec
+	descrMu.Unlock()
+}
+
+// RegisterExtDescriptor registers decoder for extension descriptors with
+// descriptor_tag_extension equal to tag.
+func RegisterExtDescriptor(tag ExtDescriptorTag, dec DescriptorDecoder) {
+	descrMu.Lock()
+	descrDecoders[descrKey{tag: ExtensionTag, ext: tag}] = dec
+	descrMu.Unlock()
+}
+
+// DecodeDescriptor decodes d using registered decoder. pds is the value of
+// private_data_specifier in scope of d (0 if there is no one). Decoders
+// registered for pds take precedence over decoders registered for 0. Private
+// descriptors (tag >= 0x80) in scope of pds != 0 are never decoded by
+// decoders registered for 0.
+func DecodeDescriptor(d Descriptor, pds uint32) (v interface{}, ok bool) {
+	if len(d) < 2 || len(d) < 2+int(d[1]) {
+		return nil, false
+	}
+	var dec DescriptorDecoder
+	descrMu.RLock()
+	if ext, isExt := d.ExtTag(); isExt {
+		dec = descrDecoders[descrKey{tag: ExtensionTag, ext: ext}]
+	} else {
+		if pds != 0 {
+			dec = descrDecoders[descrKey{pds: pds, tag: d.Tag()}]
+		}
+		if dec == nil && (pds == 0 || d.Tag() < 0x80) {
+			dec = descrDecoders[descrKey{tag: d.Tag()}]
+		}
+	}
+	descrMu.RUnlock()
+	if dec == nil {
+		return nil, false
+	}
+	return dec(d)
+}
+
+// ServiceListEntry is an element of service_list_descriptor.
+type ServiceListEntry struct {
+	ServiceId uint16
+	Type      ServiceType
+}
+
+// ISO639Lang is an element of ISO_639_language_descriptor.
+type ISO639Lang struct {
+	Lang      ISO639LangCode
+	AudioType AudioType
+}
+
+// popAll pops all elements from list (a descriptor with Pop method) and
+// returns them as a slice of type []T where T is type of elem. If Pop returns
+// more than one value before the remaining list, these values are assigned to
+// consecutive fields of T.
+func popAll(list, elem interface{}, ok bool) (interface{}, bool) {
+	et := reflect.TypeOf(elem)
+	es := reflect.Zero(reflect.SliceOf(et))
+	l := reflect.ValueOf(list)
+	for ok && l.Len() != 0 {
+		r := l.MethodByName("Pop").Call(nil)
+		e := r[0]
+		if len(r) > 2 {
+			e = reflect.New(et).Elem()
+			for i, v := range r[:len(r)-1] {
+				e.Field(i).Set(v)
+			}
+		}
+		es = reflect.Append(es, e)
+		l = r[len(r)-1]
+		ok = !l.IsNil()
+	}
+	return es.Interface(), ok
+}
+
+func init() {
+	for tag, dec := range map[DescriptorTag]DescriptorDecoder{
+		VideoStreamTag: func(d Descriptor) (interface{}, bool) {
+			return ParseVideoStreamDescriptor(d)
+		},
+		AudioStreamTag: func(d Descriptor) (interface{}, bool) {
+			return ParseAudioStreamDescriptor(d)
+		},
+		HierarchyTag: func(d Descriptor) (interface{}, bool) {
+			return ParseHierarchyDescriptor(d)
+		},
+		RegistrationTag: func(d Descriptor) (interface{}, bool) {
+			return ParseRegistrationDescriptor(d)
+		},
+		DataStreamAlignmentTag: func(d Descriptor) (interface{}, bool) {
+			return ParseDataStreamAlignmentDescriptor(d)
+		},
+		CATag: func(d Descriptor) (interface{}, bool) {
+			return ParseCADescriptor(d)
+		},
+		ISO639LangTag: func(d Descriptor) (interface{}, bool) {
+			ld, ok := ParseISO639LangDescriptor(d)
+			return popAll(ld, ISO639Lang{}, ok)
+		},
+		SystemClockTag: func(d Descriptor) (interface{}, bool) {
+			return ParseSystemClockDescriptor(d)
+		},
+		MultiplexBufferUtilizationTag: func(d Descriptor) (interface{}, bool) {
+			return ParseMultiplexBufferUtilizationDescriptor(d)
+		},
+		MaximumBitrateTag: func(d Descriptor) (interface{}, bool) {
+			return ParseMaximumBitrateDescriptor(d)
+		},
+		SmoothingBufferTag: func(d Descriptor) (interface{}, bool) {
+			return ParseSmoothingBufferDescriptor(d)
+		},
+		STDTag: func(d Descriptor) (interface{}, bool) {
+			return ParseSTDDescriptor(d)
+		},
+		AVCVideoTag: func(d Descriptor) (interface{}, bool) {
+			return ParseAVCVideoDescriptor(d)
+		},
+		AVCTimingHRDTag: func(d Descriptor) (interface{}, bool) {
+			return ParseAVCTimingHRDDescriptor(d)
+		},
+		MPEG4AudioExtensionTag: func(d Descriptor) (interface{}, bool) {
+			return ParseMPEG4AudioExtensionDescriptor(d)
+		},
+		HEVCVideoTag: func(d Descriptor) (interface{}, bool) {
+			return ParseHEVCVideoDescriptor(d)
+		},
+
+		NetworkNameTag: func(d Descriptor) (interface{}, bool) {
+			nnd, ok := ParseNetworkNameDescriptor(d)
+			return DecodeText(nnd), ok
+		},
+		ServiceListTag: func(d Descriptor) (interface{}, bool) {
+			sld, ok := ParseServiceListDescriptor(d)
+			return popAll(sld, ServiceListEntry{}, ok)
+		},
+		ServiceTag: func(d Descriptor) (interface{}, bool) {
+			sd, ok := ParseServiceDescriptor(d)
+			return struct {
+				Type         ServiceType
+				ProviderName string
+				ServiceName  string
+			}{sd.Type, DecodeText(sd.ProviderName), DecodeText(sd.ServiceName)}, ok
+		},
+		CountryAvailabilityTag: func(d Descriptor) (interface{}, bool) {
+			return ParseCountryAvailabilityDescriptor(d)
+		},
+		LinkageTag: func(d Descriptor) (interface{}, bool) {
+			return ParseLinkageDescriptor(d)
+		},
+		NVODReferenceTag: func(d Descriptor) (interface{}, bool) {
+			nd, ok := ParseNVODReferenceDescriptor(d)
+			return popAll(nd, NVODReference{}, ok)
+		},
+		TimeShiftedServiceTag: func(d Descriptor) (interface{}, bool) {
+			return ParseTimeShiftedServiceDescriptor(d)
+		},
+		TimeShiftedEventTag: func(d Descriptor) (interface{}, bool) {
+			return ParseTimeShiftedEventDescriptor(d)
+		},
+		ComponentTag: func(d Descriptor) (interface{}, bool) {
+			cd, ok := ParseComponentDescriptor(d)
+			return struct {
+				StreamContentExt byte
+				StreamContent    StreamContent
+				Type             byte
+				Tag              byte
+				Lang             ISO639LangCode
+				Text             string
+			}{
+				cd.StreamContentExt, cd.StreamContent, cd.Type, cd.Tag,
+				cd.Lang, DecodeText(cd.Text),
+			}, ok
+		},
+		MosaicTag: func(d Descriptor) (interface{}, bool) {
+			return ParseMosaicDescriptor(d)
+		},
+		StreamIdentifierTag: func(d Descriptor) (interface{}, bool) {
+			return ParseStreamIdentifierDescriptor(d)
+		},
+		ContentTag: func(d Descriptor) (interface{}, bool) {
+			cd, ok := ParseContentDescriptor(d)
+			return popAll(cd, Content{}, ok)
+		},
+		ParentalRatingTag: func(d Descriptor) (interface{}, bool) {
+			prd, ok := ParseParentalRatingDescriptor(d)
+			return popAll(prd, ParentalRating{}, ok)
+		},
+		TeletextTag:    decodeTeletext,
+		VBITeletextTag: decodeTeletext,
+		VBIDataTag: func(d Descriptor) (interface{}, bool) {
+			vd, ok := ParseVBIDataDescriptor(d)
+			return popAll(vd, VBIData{}, ok)
+		},
+		LocalTimeOffsetTag: func(d Descriptor) (interface{}, bool) {
+			tod, ok := ParseLocalTimeOffsetDescriptor(d)
+			return popAll(tod, LocalTimeOffset{}, ok)
+		},
+		SubtitlingTag: func(d Descriptor) (interface{}, bool) {
+			sd, ok := ParseSubtitlingDescriptor(d)
+			return popAll(sd, Subtitling{}, ok)
+		},
+		TerrestrialDeliverySystemTag: func(d Descriptor) (interface{}, bool) {
+			return ParseTerrestrialDeliverySystemDescriptor(d)
+		},
+		MultilingualNetworkNameTag: func(d Descriptor) (interface{}, bool) {
+			mnd, ok := ParseMultilingualNetworkNameDescriptor(d)
+			return popAll(mnd, LangString{}, ok)
+		},
+		MultilingualBouquetNameTag: func(d Descriptor) (interface{}, bool) {
+			mnd, ok := ParseMultilingualBouquetNameDescriptor(d)
+			return popAll(mnd, LangString{}, ok)
+		},
+		MultilingualServiceNameTag: func(d Descriptor) (interface{}, bool) {
+			msd, ok := ParseMultilingualServiceNameDescriptor(d)
+			return popAll(msd, LangServiceName{}, ok)
+		},
+		MultilingualComponentTag: func(d Descriptor) (interface{}, bool) {
+			return ParseMultilingualComponentDescriptor(d)
+		},
+		PrivateDataSpecifierTag: func(d Descriptor) (interface{}, bool) {
+			return ParsePrivateDataSpecifier(d)
+		},
+		AC3Tag: func(d Descriptor) (interface{}, bool) {
+			return ParseAC3Descriptor(d)
+		},
+		EnhancedAC3Tag: func(d Descriptor) (interface{}, bool) {
+			return ParseEAC3Descriptor(d)
+		},
+		DTSTag: func(d Descriptor) (interface{}, bool) {
+			return ParseDTSDescriptor(d)
+		},
+		AACTag: func(d Descriptor) (interface{}, bool) {
+			return ParseAACDescriptor(d)
+		},
+	} {
+		RegisterDescriptor(0, tag, dec)
+	}
+	RegisterDescriptor(
+		EACEMSpecifier, LogicalChannelTag,
+		func(d Descriptor) (interface{}, bool) {
+			lcd, ok := ParseLogicalChannelDescriptor(d)
+			return popAll(lcd, LogicalChannelInfo{}, ok)
+		},
+	)
+	for tag, dec := range map[DescriptorTag]DescriptorDecoder{
+		ATSCAC3AudioTag: func(d Descriptor) (interface{}, bool) {
+			return ParseATSCAC3Descriptor(d)
+		},
+		CaptionServiceTag: func(d Descriptor) (interface{}, bool) {
+			csd, ok := ParseCaptionServiceDescriptor(d)
+			return popAll(csd, CaptionService{}, ok)
+		},
+		ContentAdvisoryTag: func(d Descriptor) (interface{}, bool) {
+			cad, ok := ParseContentAdvisoryDescriptor(d)
+			return popAll(cad, RegionRating{}, ok)
+		},
+		ExtendedChannelNameTag: func(d Descriptor) (interface{}, bool) {
+			ms, ok := ParseExtendedChannelNameDescriptor(d)
+			if !ok {
+				return nil, false
+			}
+			ls, err := ms.Strings()
+			return ls, err == nil
+		},
+		ServiceLocationTag: func(d Descriptor) (interface{}, bool) {
+			sld, ok := ParseServiceLocationDescriptor(d)
+			if !ok {
+				return nil, false
+			}
+			es := sld.Elements()
+			return struct {
+				PCRPid   int16
+				Elements []ServiceLocationElement
+			}{sld.PCRPid(), es}, es != nil
+		},
+	} {
+		RegisterDescriptor(ATSCSpecifier, tag, dec)
+	}
+	RegisterExtDescriptor(
+		SupplementaryAudioExtTag,
+		func(d Descriptor) (interface{}, bool) {
+			return ParseSupplementaryAudioDescriptor(d)
+		},
+	)
+}
+
+func decodeTeletext(d Descriptor) (interface{}, bool) {
+	td, ok := ParseTeletextDescriptor(d)
+	return popAll(td, TeletextPage{}, ok)
+}
@@ -0,0 +1,129 @@
+package psi
+
+import (
+	"github.com/ziutek/dvb"
+)
+
+var (
+	ErrRSTSectionSyntax = dvb.TemporaryError("incorrect RST section syntax")
+	ErrDITSectionSyntax = dvb.TemporaryError("incorrect DIT section syntax")
+)
+
+// RunningStatus is an entry of Running Status Table. It informs about status
+// of event EventId in service ServiceId.
+type RunningStatus struct {
+	MuxId     uint16
+	OrgNetId  uint16
+	ServiceId uint16
+	EventId   uint16
+	Status    ServiceStatus
+}
+
+const runningStatusLen = 9
+
+// RST represents Running Status Table section.
+type RST Section
+
+// ParseRST returns s as RST or error if s isn't correct RST section.
+func ParseRST(s Section) (RST, error) {
+	l := s.Len()
+	if s.TableId() != 0x71 || s.GenericSyntax() || l > len(s) || (l-3)%runningStatusLen != 0 {
+		return nil, ErrRSTSectionSyntax
+	}
+	return RST(s[:l]), nil
+}
+
+// MakeRST creates RST that contains rs.
+func MakeRST(rs ...RunningStatus) RST {
+	n := len(rs) * runningStatusLen
+	if 3+n > ISOSectionMaxLen {
+		panic("psi: too many running statuses for RST")
+	}
+	s := MakeEmptySection(3+n, false)
+	s.SetTableId(0x71)
+	s.SetPrivateSyntax(true)
+	data := s.Alloc(n, 0)
+	for _, r := range rs {
+		encodeU16(data[0:2], r.MuxId)
+		encodeU16(data[2:4], r.OrgNetId)
+		encodeU16(data[4:6], r.ServiceId)
+		encodeU16(data[6:8], r.EventId)
+		data[8] = 0xf8 | byte(r.Status)&0x07
+		data = data[runningStatusLen:]
+	}
+	return RST(s)
+}
+
+// Len returns number of running status entries in rst.
+func (rst RST) Len() int {
+	return (Section(rst).Len() - 3) / runningStatusLen
+}
+
+// RunningStatus returns i-th running status entry.
+func (rst RST) RunningStatus(i int) RunningStatus {
+	b := rst[3+i*runningStatusLen:]
+	return RunningStatus{
+		MuxId:     decodeU16(b[0:2]),
+		OrgNetId:  decodeU16(b[2:4]),
+		ServiceId: decodeU16(b[4:6]),
+		EventId:   decodeU16(b[6:8]),
+		Status:    ServiceStatus(b[8] & 0x07),
+	}
+}
+
+// MakeST creates stuffing section (Stuffing Table) that contains n stuffing
+// bytes. Stuffing sections should be ignored by decoders.
+func MakeST(n int) Section {
+	if 3+n > ISOSectionMaxLen {
+		panic("psi: bad ST length")
+	}
+	s := MakeEmptySection(3+n, false)
+	s.SetTableId(0x72)
+	s.SetPrivateSyntax(true)
+	s.Alloc(n, 0)
+	return s
+}
+
+// IsST returns true if s is stuffing section.
+func IsST(s Section) bool {
+	return s.TableId() == 0x72
+}
+
+// DIT represents Discontinuity Information Table section used in partial
+// transport streams.
+type DIT Section
+
+const DITSectionLen = 3 + 1
+
+// ParseDIT returns the value of transition_flag field.
+func ParseDIT(s Section) (transition bool, err error) {
+	l := s.Len()
+	if s.TableId() != 0x7e || s.GenericSyntax() || l != DITSectionLen ||
+		l > len(s) {
+		return false, ErrDITSectionSyntax
+	}
+	return s[3]&0x80 != 0, nil
+}
+
+func MakeDIT(transition bool) DIT {
+	s := MakeEmptySection(DITSectionLen, false)
+	s.SetTableId(0x7e)
+	s.SetPrivateSyntax(true)
+	s.Alloc(1, 0)
+	dit := DIT(s)
+	dit.SetTransition(transition)
+	return dit
+}
+
+// Transition returns the value of transition_flag field.
+func (dit DIT) Transition() bool {
+	return dit[3]&0x80 != 0
+}
+
+func (dit DIT) SetTransition(b bool) {
+	if b {
+		dit[3] = 0xff
+	} else {
+		dit[3] = 0x7f
+	}
+}
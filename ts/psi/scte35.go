@@ -0,0 +1,609 @@
+package psi
+
+import (
+	"errors"
+
+	"github.com/ziutek/dvb"
+)
+
+// SCTE 35 splice_info_section support.
+//
+// Splice info sections use section_syntax_indicator == 0 and sap_type in
+// place of reserved bits, so use SectionDecoder with check == false to read
+// them. ParseSpliceInfo checks CRC itself.
+
+const SpliceInfoTableId = 0xfc
+
+// CUEIdentifier is a value of identifier field of SCTE 35 splice descriptors
+// ("CUEI").
+const CUEIdentifier = 0x43554549
+
+const ptsMask = 1<<33 - 1
+
+var (
+	ErrSpliceInfoSyntax = dvb.TemporaryError("incorrect splice_info_section syntax")
+	ErrSpliceInfoSpace  = errors.New("no space for splice_info_section")
+	ErrSpliceCommand    = errors.New("unknown splice command")
+)
+
+type SpliceCommandType byte
+
+const (
+	SpliceNullType           SpliceCommandType = 0x00
+	SpliceScheduleType       SpliceCommandType = 0x04
+	SpliceInsertType         SpliceCommandType = 0x05
+	TimeSignalType           SpliceCommandType = 0x06
+	BandwidthReservationType SpliceCommandType = 0x07
+	PrivateCommandType       SpliceCommandType = 0xff
+)
+
+func (t SpliceCommandType) String() string {
+	switch t {
+	case SpliceNullType:
+		return "splice_null"
+	case SpliceScheduleType:
+		return "splice_schedule"
+	case SpliceInsertType:
+		return "splice_insert"
+	case TimeSignalType:
+		return "time_signal"
+	case BandwidthReservationType:
+		return "bandwidth_reservation"
+	case PrivateCommandType:
+		return "private_command"
+	}
+	return "unknown"
+}
+
+// SpliceCommand is implemented by all splice commands.
+type SpliceCommand interface {
+	Type() SpliceCommandType
+
+	// append appends encoded command to b.
+	append(b []byte) []byte
+}
+
+// SpliceTime represents splice_time() structure.
+type SpliceTime struct {
+	Specified bool
+	PTS       uint64 // 33 bit PTS (valid if Specified)
+}
+
+func decodePTS(b []byte) uint64 {
+	return uint64(b[0]&1)<<32 | uint64(decodeU32(b[1:5]))
+}
+
+func appendPTS(b []byte, flags byte, pts uint64) []byte {
+	return append(
+		b, flags|byte(pts>>32)&1,
+		byte(pts>>24), byte(pts>>16), byte(pts>>8), byte(pts),
+	)
+}
+
+func decodeSpliceTime(b []byte) (t SpliceTime, n int, ok bool) {
+	if len(b) < 1 {
+		return
+	}
+	if b[0]&0x80 == 0 {
+		return t, 1, true
+	}
+	if len(b) < 5 {
+		return
+	}
+	return SpliceTime{true, decodePTS(b)}, 5, true
+}
+
+func (t SpliceTime) append(b []byte) []byte {
+	if !t.Specified {
+		return append(b, 0x7f)
+	}
+	return appendPTS(b, 0xfe, t.PTS)
+}
+
+// BreakDuration represents break_duration() structure.
+type BreakDuration struct {
+	AutoReturn bool
+	Duration   uint64 // in 90 kHz ticks
+}
+
+func decodeBreakDuration(b []byte) (*BreakDuration, bool) {
+	if len(b) < 5 {
+		return nil, false
+	}
+	return &BreakDuration{b[0]&0x80 != 0, decodePTS(b)}, true
+}
+
+func (d *BreakDuration) append(b []byte) []byte {
+	flags := byte(0x7e)
+	if d.AutoReturn {
+		flags |= 0x80
+	}
+	return appendPTS(b, flags, d.Duration)
+}
+
+// SpliceNull represents splice_null command.
+type SpliceNull struct{}
+
+func (SpliceNull) Type() SpliceCommandType {
+	return SpliceNullType
+}
+
+func (SpliceNull) append(b []byte) []byte {
+	return b
+}
+
+// BandwidthReservation represents bandwidth_reservation command.
+type BandwidthReservation struct{}
+
+func (BandwidthReservation) Type() SpliceCommandType {
+	return BandwidthReservationType
+}
+
+func (BandwidthReservation) append(b []byte) []byte {
+	return b
+}
+
+// TimeSignal represents time_signal command.
+type TimeSignal struct {
+	Time SpliceTime
+}
+
+func (*TimeSignal) Type() SpliceCommandType {
+	return TimeSignalType
+}
+
+func (c *TimeSignal) append(b []byte) []byte {
+	return c.Time.append(b)
+}
+
+// PrivateCommand represents private_command.
+type PrivateCommand struct {
+	Identifier uint32
+	Data       []byte
+}
+
+func (*PrivateCommand) Type() SpliceCommandType {
+	return PrivateCommandType
+}
+
+func (c *PrivateCommand) append(b []byte) []byte {
+	b = append(b, 0, 0, 0, 0)
+	encodeU32(b[len(b)-4:], c.Identifier)
+	return append(b, c.Data...)
+}
+
+// SpliceComponent describes splice of one component (elementary stream).
+type SpliceComponent struct {
+	Tag  byte
+	Time SpliceTime // not used if splice is immediate
+}
+
+// SpliceInsert represents splice_insert command.
+type SpliceInsert struct {
+	EventId         uint32
+	Cancel          bool
+	OutOfNetwork    bool
+	ProgramSplice   bool
+	Immediate       bool
+	Time            SpliceTime        // program splice time if !Immediate
+	Components      []SpliceComponent // used if !ProgramSplice
+	BreakDuration   *BreakDuration    // optional
+	UniqueProgramId uint16
+	AvailNum        byte
+	AvailsExpected  byte
+}
+
+func (*SpliceInsert) Type() SpliceCommandType {
+	return SpliceInsertType
+}
+
+func decodeSpliceInsert(b []byte) (*SpliceInsert, int, bool) {
+	if len(b) < 5 {
+		return nil, 0, false
+	}
+	c := &SpliceInsert{EventId: decodeU32(b), Cancel: b[4]&0x80 != 0}
+	n := 5
+	if c.Cancel {
+		return c, n, true
+	}
+	if len(b) < n+1 {
+		return nil, 0, false
+	}
+	flags := b[n]
+	n++
+	c.OutOfNetwork = flags&0x80 != 0
+	c.ProgramSplice = flags&0x40 != 0
+	duration := flags&0x20 != 0
+	c.Immediate = flags&0x10 != 0
+	if c.ProgramSplice {
+		if !c.Immediate {
+			t, k, ok := decodeSpliceTime(b[n:])
+			if !ok {
+				return nil, 0, false
+			}
+			c.Time = t
+			n += k
+		}
+	} else {
+		if len(b) < n+1 {
+			return nil, 0, false
+		}
+		cnt := int(b[n])
+		n++
+		c.Components = make([]SpliceComponent, cnt)
+		for i := range c.Components {
+			if len(b) < n+1 {
+				return nil, 0, false
+			}
+			c.Components[i].Tag = b[n]
+			n++
+			if !c.Immediate {
+				t, k, ok := decodeSpliceTime(b[n:])
+				if !ok {
+					return nil, 0, false
+				}
+				c.Components[i].Time = t
+				n += k
+			}
+		}
+	}
+	if duration {
+		d, ok := decodeBreakDuration(b[n:])
+		if !ok {
+			return nil, 0, false
+		}
+		c.BreakDuration = d
+		n += 5
+	}
+	if len(b) < n+4 {
+		return nil, 0, false
+	}
+	c.UniqueProgramId = decodeU16(b[n:])
+	c.AvailNum = b[n+2]
+	c.AvailsExpected = b[n+3]
+	return c, n + 4, true
+}
+
+func (c *SpliceInsert) append(b []byte) []byte {
+	b = append(b, 0, 0, 0, 0, 0x7f)
+	encodeU32(b[len(b)-5:], c.EventId)
+	if c.Cancel {
+		b[len(b)-1] = 0xff
+		return b
+	}
+	flags := byte(0x0f)
+	if c.OutOfNetwork {
+		flags |= 0x80
+	}
+	if c.ProgramSplice {
+		flags |= 0x40
+	}
+	if c.BreakDuration != nil {
+		flags |= 0x20
+	}
+	if c.Immediate {
+		flags |= 0x10
+	}
+	b = append(b, flags)
+	if c.ProgramSplice {
+		if !c.Immediate {
+			b = c.Time.append(b)
+		}
+	} else {
+		b = append(b, byte(len(c.Components)))
+		for _, sc := range c.Components {
+			b = append(b, sc.Tag)
+			if !c.Immediate {
+				b = sc.Time.append(b)
+			}
+		}
+	}
+	if c.BreakDuration != nil {
+		b = c.BreakDuration.append(b)
+	}
+	b = append(b, byte(c.UniqueProgramId>>8), byte(c.UniqueProgramId))
+	return append(b, c.AvailNum, c.AvailsExpected)
+}
+
+// ScheduleComponent describes scheduled splice of one component.
+type ScheduleComponent struct {
+	Tag     byte
+	UTCTime uint32 // seconds since 1980-01-06 00:00:00 UTC (GPS epoch)
+}
+
+// ScheduleEvent describes one event of splice_schedule command.
+type ScheduleEvent struct {
+	EventId         uint32
+	Cancel          bool
+	OutOfNetwork    bool
+	ProgramSplice   bool
+	UTCTime         uint32 // used if ProgramSplice
+	Components      []ScheduleComponent
+	BreakDuration   *BreakDuration
+	UniqueProgramId uint16
+	AvailNum        byte
+	AvailsExpected  byte
+}
+
+// SpliceSchedule represents splice_schedule command.
+type SpliceSchedule struct {
+	Events []ScheduleEvent
+}
+
+func (*SpliceSchedule) Type() SpliceCommandType {
+	return SpliceScheduleType
+}
+
+func decodeSpliceSchedule(b []byte) (*SpliceSchedule, int, bool) {
+	if len(b) < 1 {
+		return nil, 0, false
+	}
+	c := &SpliceSchedule{Events: make([]ScheduleEvent, b[0])}
+	n := 1
+	for i := range c.Events {
+		e := &c.Events[i]
+		if len(b) < n+5 {
+			return nil, 0, false
+		}
+		e.EventId = decodeU32(b[n:])
+		e.Cancel = b[n+4]&0x80 != 0
+		n += 5
+		if e.Cancel {
+			continue
+		}
+		if len(b) < n+1 {
+			return nil, 0, false
+		}
+		flags := b[n]
+		n++
+		e.OutOfNetwork = flags&0x80 != 0
+		e.ProgramSplice = flags&0x40 != 0
+		if e.ProgramSplice {
+			if len(b) < n+4 {
+				return nil, 0, false
+			}
+			e.UTCTime = decodeU32(b[n:])
+			n += 4
+		} else {
+			if len(b) < n+1 {
+				return nil, 0, false
+			}
+			cnt := int(b[n])
+			n++
+			if len(b) < n+5*cnt {
+				return nil, 0, false
+			}
+			e.Components = make([]ScheduleComponent, cnt)
+			for k := range e.Components {
+				e.Components[k].Tag = b[n]
+				e.Components[k].UTCTime = decodeU32(b[n+1:])
+				n += 5
+			}
+		}
+		if flags&0x20 != 0 {
+			d, ok := decodeBreakDuration(b[n:])
+			if !ok {
+				return nil, 0, false
+			}
+			e.BreakDuration = d
+			n += 5
+		}
+		if len(b) < n+4 {
+			return nil, 0, false
+		}
+		e.UniqueProgramId = decodeU16(b[n:])
+		e.AvailNum = b[n+2]
+		e.AvailsExpected = b[n+3]
+		n += 4
+	}
+	return c, n, true
+}
+
+func (c *SpliceSchedule) append(b []byte) []byte {
+	b = append(b, byte(len(c.Events)))
+	for i := range c.Events {
+		e := &c.Events[i]
+		b = appendU32(b, e.EventId)
+		if e.Cancel {
+			b = append(b, 0xff)
+			continue
+		}
+		b = append(b, 0x7f)
+		flags := byte(0x1f)
+		if e.OutOfNetwork {
+			flags |= 0x80
+		}
+		if e.ProgramSplice {
+			flags |= 0x40
+		}
+		if e.BreakDuration != nil {
+			flags |= 0x20
+		}
+		b = append(b, flags)
+		if e.ProgramSplice {
+			b = appendU32(b, e.UTCTime)
+		} else {
+			b = append(b, byte(len(e.Components)))
+			for _, sc := range e.Components {
+				b = appendU32(append(b, sc.Tag), sc.UTCTime)
+			}
+		}
+		if e.BreakDuration != nil {
+			b = e.BreakDuration.append(b)
+		}
+		b = append(b, byte(e.UniqueProgramId>>8), byte(e.UniqueProgramId))
+		b = append(b, e.AvailNum, e.AvailsExpected)
+	}
+	return b
+}
+
+func decodeSpliceCommand(t SpliceCommandType, b []byte) (SpliceCommand, int, bool) {
+	switch t {
+	case SpliceNullType:
+		return SpliceNull{}, 0, true
+	case SpliceScheduleType:
+		return decodeSpliceSchedule(b)
+	case SpliceInsertType:
+		return decodeSpliceInsert(b)
+	case TimeSignalType:
+		st, n, ok := decodeSpliceTime(b)
+		return &TimeSignal{st}, n, ok
+	case BandwidthReservationType:
+		return BandwidthReservation{}, 0, true
+	case PrivateCommandType:
+		if len(b) < 4 {
+			return nil, 0, false
+		}
+		return &PrivateCommand{decodeU32(b), b[4:]}, len(b), true
+	}
+	return nil, 0, false
+}
+
+// SpliceInfo represents content of splice_info_section.
+type SpliceInfo struct {
+	SAPType             byte // 2 bits, 3 means not specified
+	ProtocolVersion     byte
+	Encrypted           bool
+	EncryptionAlgorithm byte   // 6 bits
+	PTSAdjustment       uint64 // 33 bits
+	CWIndex             byte
+	Tier                uint16 // 12 bits
+
+	// Command and Descriptors are nil if Encrypted is true.
+	Command     SpliceCommand
+	Descriptors []SpliceDescriptor
+
+	// EncryptedData contains encrypted part of section (from
+	// splice_command_type to E_CRC_32) if Encrypted is true.
+	EncryptedData []byte
+}
+
+// AdjustPTS adds PTSAdjustment to pts (modulo 2^33). Use it to obtain PTS
+// of splice point from SpliceTime.PTS.
+func (si *SpliceInfo) AdjustPTS(pts uint64) uint64 {
+	return (pts + si.PTSAdjustment) & ptsMask
+}
+
+// ParseSpliceInfo parses splice_info_section. Returned SpliceInfo refers to
+// data in s.
+func ParseSpliceInfo(s Section) (*SpliceInfo, error) {
+	l := s.Len()
+	if s.TableId() != SpliceInfoTableId || s.GenericSyntax() || l < 3+11+2+4 ||
+		l > len(s) {
+		return nil, ErrSpliceInfoSyntax
+	}
+	if mpegCRC32(s[:l-4]) != decodeU32(s[l-4:l]) {
+		return nil, ErrSectionCRC
+	}
+	si := &SpliceInfo{
+		SAPType:             s[1] >> 4 & 3,
+		ProtocolVersion:     s[3],
+		Encrypted:           s[4]&0x80 != 0,
+		EncryptionAlgorithm: s[4] >> 1 & 0x3f,
+		PTSAdjustment:       decodePTS(s[4:9]),
+		CWIndex:             s[9],
+		Tier:                decodeU16(s[10:12]) >> 4,
+	}
+	cmdLen := int(decodeU16(s[11:13]) & 0xfff)
+	if si.Encrypted {
+		si.EncryptedData = s[13 : l-4]
+		return si, nil
+	}
+	b := s[14 : l-4]
+	if cmdLen != 0xfff && cmdLen > len(b) {
+		return nil, ErrSpliceInfoSyntax
+	}
+	cb := b
+	if cmdLen != 0xfff {
+		cb = b[:cmdLen]
+	}
+	cmd, n, ok := decodeSpliceCommand(SpliceCommandType(s[13]), cb)
+	if !ok {
+		return nil, ErrSpliceInfoSyntax
+	}
+	if cmdLen == 0xfff {
+		cmdLen = n
+	}
+	si.Command = cmd
+	b = b[cmdLen:]
+	if len(b) < 2 {
+		return nil, ErrSpliceInfoSyntax
+	}
+	dl := int(decodeU16(b))
+	b = b[2:]
+	if dl > len(b) {
+		return nil, ErrSpliceInfoSyntax
+	}
+	b = b[:dl]
+	for len(b) != 0 {
+		if len(b) < 2 || len(b) < 2+int(b[1]) {
+			return nil, ErrSpliceInfoSyntax
+		}
+		d, ok := decodeSpliceDescriptor(b[:2+int(b[1])])
+		if !ok {
+			return nil, ErrSpliceInfoSyntax
+		}
+		si.Descriptors = append(si.Descriptors, d)
+		b = b[2+int(b[1]):]
+	}
+	return si, nil
+}
+
+// MakeSection returns splice_info_section that contains si. If si.Encrypted
+// is true it uses si.EncryptedData in place of command and descriptors.
+func (si *SpliceInfo) MakeSection() (Section, error) {
+	b := make([]byte, 14, 64)
+	b[0] = SpliceInfoTableId
+	b[1] = si.SAPType & 3 << 4
+	b[3] = si.ProtocolVersion
+	var flags byte
+	if si.Encrypted {
+		flags = 0x80
+	}
+	flags |= si.EncryptionAlgorithm & 0x3f << 1
+	appendPTS(b[:4], flags, si.PTSAdjustment) // fills b[4:9]
+	b[9] = si.CWIndex
+	if si.Encrypted {
+		b = append(b[:13], si.EncryptedData...)
+		encodeU16(b[10:12], si.Tier<<4|0xf)
+		b[12] = 0xff // unknown splice_command_length
+	} else {
+		if si.Command == nil {
+			return nil, ErrSpliceCommand
+		}
+		b[13] = byte(si.Command.Type())
+		b = si.Command.append(b)
+		cmdLen := len(b) - 14
+		if cmdLen >= 0xfff {
+			return nil, ErrSpliceInfoSpace
+		}
+		encodeU16(b[10:12], si.Tier<<4|uint16(cmdLen>>8))
+		b[12] = byte(cmdLen)
+		b = append(b, 0, 0)
+		dl := len(b)
+		for _, d := range si.Descriptors {
+			k := len(b)
+			b = d.append(append(b, byte(d.Tag()), 0, 0, 0, 0, 0))
+			encodeU32(b[k+2:], d.Identifier())
+			if len(b)-k-2 > 255 {
+				return nil, ErrSpliceInfoSpace
+			}
+			b[k+1] = byte(len(b) - k - 2)
+		}
+		encodeU16(b[dl-2:dl], uint16(len(b)-dl))
+	}
+	b = append(b, 0, 0, 0, 0)
+	if len(b) > SectionMaxLen {
+		return nil, ErrSpliceInfoSpace
+	}
+	s := Section(b)
+	s.setLen(len(b))
+	s.makeCRC()
+	return s, nil
+}
+
+// makeCRC works like MakeCRC but doesn't require generic syntax.
+func (s Section) makeCRC() {
+	l := s.Len()
+	encodeU32(s[l-4:l], mpegCRC32(s[:l-4]))
+}
@@ -0,0 +1,105 @@
+package psi_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/ziutek/dvb/ts/psi"
+)
+
+func TestSpliceInfo(t *testing.T) {
+	vectors := []string{
+		// splice_insert with avail_descriptor
+		"/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=",
+		// time_signal with segmentation_descriptor
+		"/DA0AAAAAAAA///wBQb+cr0AUAAeAhxDVUVJSAAAjn/PAAGlmbAICAAAAAAsoKGKNAIAmsnRfg==",
+	}
+	var infos []*psi.SpliceInfo
+	for i, v := range vectors {
+		b, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		si, err := psi.ParseSpliceInfo(psi.Section(b))
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		s, err := si.MakeSection()
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if !bytes.Equal(s, b) {
+			t.Fatalf("%d: bad encoding:\n%x\nexpected:\n%x", i, []byte(s), b)
+		}
+		infos = append(infos, si)
+	}
+
+	si := infos[0]
+	c, ok := si.Command.(*psi.SpliceInsert)
+	if !ok || c.EventId != 0x4800008f || !c.OutOfNetwork || !c.ProgramSplice ||
+		c.Immediate || c.Time.PTS != 0x07369c02e || c.BreakDuration == nil ||
+		!c.BreakDuration.AutoReturn || c.BreakDuration.Duration != 0x52ccf5 {
+		t.Fatalf("bad splice_insert: %+v", si.Command)
+	}
+	if len(si.Descriptors) != 1 {
+		t.Fatal("bad number of descriptors:", len(si.Descriptors))
+	}
+	if d, ok := si.Descriptors[0].(*psi.AvailDescriptor); !ok ||
+		d.ProviderAvailId != 0x135 {
+		t.Fatalf("bad avail_descriptor: %+v", si.Descriptors[0])
+	}
+
+	si = infos[1]
+	ts, ok := si.Command.(*psi.TimeSignal)
+	if !ok || !ts.Time.Specified || ts.Time.PTS != 0x072bd0050 {
+		t.Fatalf("bad time_signal: %+v", si.Command)
+	}
+	d, ok := si.Descriptors[0].(*psi.SegmentationDescriptor)
+	if !ok || d.EventId != 0x4800008e || !d.ProgramSegmentation ||
+		!d.HasDuration || d.Duration != 0x1a599b0 || d.UPIDType != 8 ||
+		len(d.UPID) != 8 || d.TypeId != 0x34 || d.SegmentNum != 2 ||
+		d.HasSubSegments || !d.ArchiveAllowed || d.DeviceRestrictions != 3 {
+		t.Fatalf("bad segmentation_descriptor: %+v", si.Descriptors[0])
+	}
+
+	si.PTSAdjustment = 1<<33 - 1 // -1 modulo 2^33
+	if si.AdjustPTS(ts.Time.PTS) != ts.Time.PTS-1 {
+		t.Fatal("bad PTS adjustment")
+	}
+	si.Command = &psi.SpliceSchedule{Events: []psi.ScheduleEvent{
+		{EventId: 1, Cancel: true},
+		{
+			EventId: 2, OutOfNetwork: true,
+			Components: []psi.ScheduleComponent{
+				{Tag: 1, UTCTime: 1000}, {Tag: 2, UTCTime: 2000},
+			},
+			BreakDuration: &psi.BreakDuration{Duration: 90000},
+		},
+	}}
+	si.Descriptors = append(si.Descriptors,
+		&psi.DTMFDescriptor{Preroll: 50, Chars: "121#"},
+		&psi.TimeDescriptor{TAISeconds: 1 << 40, TAINanoseconds: 5, UTCOffset: 37},
+		&psi.RawSpliceDescriptor{SpliceTag: 4, Id: 0x12345678, Data: []byte{1, 2}},
+	)
+	s, err := si.MakeSection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	si2, err := psi.ParseSpliceInfo(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := si2.MakeSection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(s, s2) || len(si2.Descriptors) != 4 {
+		t.Fatal("splice_schedule round trip failed")
+	}
+	ev := si2.Command.(*psi.SpliceSchedule).Events
+	if len(ev) != 2 || !ev[0].Cancel || ev[1].Components[1].UTCTime != 2000 ||
+		ev[1].BreakDuration.Duration != 90000 {
+		t.Fatalf("bad splice_schedule: %+v", ev)
+	}
+}
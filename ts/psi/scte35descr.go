@@ -0,0 +1,297 @@
+package psi
+
+// SpliceDescriptorTag is a tag of SCTE 35 splice descriptor. Splice
+// descriptors use their own tag space.
+type SpliceDescriptorTag byte
+
+const (
+	AvailTag        SpliceDescriptorTag = 0x00
+	DTMFTag         SpliceDescriptorTag = 0x01
+	SegmentationTag SpliceDescriptorTag = 0x02
+	TimeTag         SpliceDescriptorTag = 0x03
+)
+
+// SpliceDescriptor is implemented by all splice descriptors.
+type SpliceDescriptor interface {
+	Tag() SpliceDescriptorTag
+	Identifier() uint32
+
+	// append appends descriptor data (that follows identifier) to b.
+	append(b []byte) []byte
+}
+
+func decodeSpliceDescriptor(b []byte) (SpliceDescriptor, bool) {
+	if len(b) < 6 {
+		return nil, false
+	}
+	tag := SpliceDescriptorTag(b[0])
+	id := decodeU32(b[2:6])
+	data := b[6:]
+	if id == CUEIdentifier {
+		switch tag {
+		case AvailTag:
+			if len(data) < 4 {
+				return nil, false
+			}
+			return &AvailDescriptor{decodeU32(data)}, true
+		case DTMFTag:
+			if len(data) < 2 || len(data) < 2+int(data[1]>>5) {
+				return nil, false
+			}
+			return &DTMFDescriptor{data[0], string(data[2 : 2+data[1]>>5])}, true
+		case SegmentationTag:
+			return decodeSegmentationDescriptor(data)
+		case TimeTag:
+			if len(data) < 12 {
+				return nil, false
+			}
+			return &TimeDescriptor{
+				TAISeconds: uint64(decodeU16(data))<<32 |
+					uint64(decodeU32(data[2:])),
+				TAINanoseconds: decodeU32(data[6:]),
+				UTCOffset:      decodeU16(data[10:]),
+			}, true
+		}
+	}
+	return &RawSpliceDescriptor{tag, id, data}, true
+}
+
+// RawSpliceDescriptor represents splice descriptor of unknown type.
+type RawSpliceDescriptor struct {
+	SpliceTag SpliceDescriptorTag
+	Id        uint32
+	Data      []byte
+}
+
+func (d *RawSpliceDescriptor) Tag() SpliceDescriptorTag {
+	return d.SpliceTag
+}
+
+func (d *RawSpliceDescriptor) Identifier() uint32 {
+	return d.Id
+}
+
+func (d *RawSpliceDescriptor) append(b []byte) []byte {
+	return append(b, d.Data...)
+}
+
+// AvailDescriptor represents avail_descriptor.
+type AvailDescriptor struct {
+	ProviderAvailId uint32
+}
+
+func (*AvailDescriptor) Tag() SpliceDescriptorTag {
+	return AvailTag
+}
+
+func (*AvailDescriptor) Identifier() uint32 {
+	return CUEIdentifier
+}
+
+func (d *AvailDescriptor) append(b []byte) []byte {
+	return appendU32(b, d.ProviderAvailId)
+}
+
+// DTMFDescriptor represents DTMF_descriptor.
+type DTMFDescriptor struct {
+	Preroll byte   // in 1/10 s
+	Chars   string // up to 7 DTMF characters
+}
+
+func (*DTMFDescriptor) Tag() SpliceDescriptorTag {
+	return DTMFTag
+}
+
+func (*DTMFDescriptor) Identifier() uint32 {
+	return CUEIdentifier
+}
+
+func (d *DTMFDescriptor) append(b []byte) []byte {
+	chars := d.Chars
+	if len(chars) > 7 {
+		chars = chars[:7]
+	}
+	b = append(b, d.Preroll, byte(len(chars))<<5|0x1f)
+	return append(b, chars...)
+}
+
+// TimeDescriptor represents time_descriptor.
+type TimeDescriptor struct {
+	TAISeconds     uint64 // 48 bits
+	TAINanoseconds uint32
+	UTCOffset      uint16
+}
+
+func (*TimeDescriptor) Tag() SpliceDescriptorTag {
+	return TimeTag
+}
+
+func (*TimeDescriptor) Identifier() uint32 {
+	return CUEIdentifier
+}
+
+func (d *TimeDescriptor) append(b []byte) []byte {
+	b = append(b, byte(d.TAISeconds>>40), byte(d.TAISeconds>>32))
+	b = appendU32(b, uint32(d.TAISeconds))
+	b = appendU32(b, d.TAINanoseconds)
+	return append(b, byte(d.UTCOffset>>8), byte(d.UTCOffset))
+}
+
+// SegmentationComponent describes segmentation of one component.
+type SegmentationComponent struct {
+	Tag       byte
+	PTSOffset uint64 // 33 bits
+}
+
+// SegmentationDescriptor represents segmentation_descriptor.
+type SegmentationDescriptor struct {
+	EventId               uint32
+	Cancel                bool
+	ProgramSegmentation   bool
+	DeliveryNotRestricted bool
+
+	// Valid if !DeliveryNotRestricted.
+	WebDeliveryAllowed bool
+	NoRegionalBlackout bool
+	ArchiveAllowed     bool
+	DeviceRestrictions byte // 2 bits
+
+	Components  []SegmentationComponent // used if !ProgramSegmentation
+	HasDuration bool
+	Duration    uint64 // 40 bits, in 90 kHz ticks
+
+	UPIDType         byte
+	UPID             []byte
+	TypeId           byte
+	SegmentNum       byte
+	SegmentsExpected byte
+
+	// Sub-segment fields should be present for Provider/Distributor
+	// Placement Opportunity Start and Provider/Distributor Advertisement
+	// Start segmentation types (0x34, 0x36, 0x38, 0x3a). Older encoders omit
+	// them.
+	HasSubSegments      bool
+	SubSegmentNum       byte
+	SubSegmentsExpected byte
+}
+
+func (*SegmentationDescriptor) Tag() SpliceDescriptorTag {
+	return SegmentationTag
+}
+
+func (*SegmentationDescriptor) Identifier() uint32 {
+	return CUEIdentifier
+}
+
+func decodeSegmentationDescriptor(b []byte) (*SegmentationDescriptor, bool) {
+	if len(b) < 5 {
+		return nil, false
+	}
+	d := &SegmentationDescriptor{EventId: decodeU32(b), Cancel: b[4]&0x80 != 0}
+	n := 5
+	if d.Cancel {
+		return d, true
+	}
+	if len(b) < n+1 {
+		return nil, false
+	}
+	flags := b[n]
+	n++
+	d.ProgramSegmentation = flags&0x80 != 0
+	d.HasDuration = flags&0x40 != 0
+	d.DeliveryNotRestricted = flags&0x20 != 0
+	if !d.DeliveryNotRestricted {
+		d.WebDeliveryAllowed = flags&0x10 != 0
+		d.NoRegionalBlackout = flags&0x08 != 0
+		d.ArchiveAllowed = flags&0x04 != 0
+		d.DeviceRestrictions = flags & 0x03
+	}
+	if !d.ProgramSegmentation {
+		if len(b) < n+1 {
+			return nil, false
+		}
+		cnt := int(b[n])
+		n++
+		if len(b) < n+6*cnt {
+			return nil, false
+		}
+		d.Components = make([]SegmentationComponent, cnt)
+		for i := range d.Components {
+			d.Components[i].Tag = b[n]
+			d.Components[i].PTSOffset = decodePTS(b[n+1:])
+			n += 6
+		}
+	}
+	if d.HasDuration {
+		if len(b) < n+5 {
+			return nil, false
+		}
+		d.Duration = uint64(b[n])<<32 | uint64(decodeU32(b[n+1:]))
+		n += 5
+	}
+	if len(b) < n+2 || len(b) < n+2+int(b[n+1]) {
+		return nil, false
+	}
+	d.UPIDType = b[n]
+	d.UPID = b[n+2 : n+2+int(b[n+1])]
+	n += 2 + int(b[n+1])
+	if len(b) < n+3 {
+		return nil, false
+	}
+	d.TypeId = b[n]
+	d.SegmentNum = b[n+1]
+	d.SegmentsExpected = b[n+2]
+	n += 3
+	if len(b) >= n+2 {
+		d.HasSubSegments = true
+		d.SubSegmentNum = b[n]
+		d.SubSegmentsExpected = b[n+1]
+	}
+	return d, true
+}
+
+func (d *SegmentationDescriptor) append(b []byte) []byte {
+	b = appendU32(b, d.EventId)
+	if d.Cancel {
+		return append(b, 0xff)
+	}
+	b = append(b, 0x7f)
+	var flags byte
+	if d.ProgramSegmentation {
+		flags |= 0x80
+	}
+	if d.HasDuration {
+		flags |= 0x40
+	}
+	if d.DeliveryNotRestricted {
+		flags |= 0x3f
+	} else {
+		if d.WebDeliveryAllowed {
+			flags |= 0x10
+		}
+		if d.NoRegionalBlackout {
+			flags |= 0x08
+		}
+		if d.ArchiveAllowed {
+			flags |= 0x04
+		}
+		flags |= d.DeviceRestrictions & 3
+	}
+	b = append(b, flags)
+	if !d.ProgramSegmentation {
+		b = append(b, byte(len(d.Components)))
+		for _, c := range d.Components {
+			b = appendPTS(append(b, c.Tag), 0xfe, c.PTSOffset)
+		}
+	}
+	if d.HasDuration {
+		b = appendU32(append(b, byte(d.Duration>>32)), uint32(d.Duration))
+	}
+	b = append(b, d.UPIDType, byte(len(d.UPID)))
+	b = append(b, d.UPID...)
+	b = append(b, d.TypeId, d.SegmentNum, d.SegmentsExpected)
+	if d.HasSubSegments {
+		b = append(b, d.SubSegmentNum, d.SubSegmentsExpected)
+	}
+	return b
+}
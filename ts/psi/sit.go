@@ -0,0 +1,179 @@
+package psi
+
+import (
+	"github.com/ziutek/dvb"
+)
+
+// SIT represents Selection Information Table used in partial transport
+// streams.
+type SIT Table
+
+func (sit SIT) Version() int8 {
+	return Table(sit).Version()
+}
+
+func (sit SIT) Current() bool {
+	return Table(sit).Current()
+}
+
+var ErrSITSectionLen = dvb.TemporaryError("incorrect SIT section length")
+
+// Update reads next SIT from r.
+func (sit *SIT) Update(r SectionReader, current bool) error {
+	t := (*Table)(sit)
+	err := t.Update(r, 0x7f, true, current, SectionMaxLen)
+	if err != nil {
+		return err
+	}
+	for _, s := range *t {
+		data := s.Data()
+		if len(data) < 2 || len(data) < 2+loopLen(data[0:2]) {
+			t.Reset()
+			return ErrSITSectionLen
+		}
+	}
+	return nil
+}
+
+// Descriptors returns transmission info descriptors.
+func (sit SIT) Descriptors() TableDescriptors {
+	return Table(sit).Descriptors(0)
+}
+
+// Services returns list of services in partial transport stream.
+func (sit SIT) Services() SITServiceList {
+	return SITServiceList{Table(sit).Cursor()}
+}
+
+func (sit *SIT) SetEmpty() {
+	(*Table)(sit).SetEmpty()
+}
+
+var (
+	sitCfgInfo = &TableConfig{
+		TableId:       0x7f,
+		GenericSyntax: true,
+		PrivateSyntax: true,
+		SectionMaxLen: SectionMaxLen,
+		NumLenFields:  1,
+	}
+	// Service loop has no length field. Every new section starts with empty
+	// transmission_info_loop.
+	sitCfgServices = &TableConfig{
+		TableId:        0x7f,
+		GenericSyntax:  true,
+		PrivateSyntax:  true,
+		SectionMaxLen:  SectionMaxLen,
+		SectionHeadLen: 2,
+	}
+)
+
+// AppendDescriptor appends transmission info descriptors ds to sit. It can be
+// called only before first AppendService call.
+func (sit *SIT) AppendDescriptor(ds ...Descriptor) {
+	for _, d := range ds {
+		data := (*Table)(sit).Alloc(len(d), sitCfgInfo, 0, nil)
+		copy(data, d)
+	}
+}
+
+// AppendService appends information about services to sit.
+func (sit *SIT) AppendService(ss ...SITService) {
+	head := []byte{0xf0, 0}
+	for _, s := range ss {
+		data := (*Table)(sit).Alloc(len(s), sitCfgServices, 0, head)
+		copy(data, s)
+	}
+}
+
+func (sit SIT) Close(current bool, version int8) {
+	Table(sit).Close(sitCfgInfo, 0xffff, current, version)
+}
+
+type SITServiceList struct {
+	TableCursor
+}
+
+// Pop returns first SITService element from sl. If there is no more data to
+// read Pop returns empty SITServiceList. If an error occurs it returns nil
+// SITService.
+func (sl SITServiceList) Pop() (SITService, SITServiceList) {
+	if len(sl.Data) == 0 {
+		if len(sl.Tab) == 0 {
+			return nil, sl
+		}
+		sl.TableCursor = sl.NextSection()
+		// Skip transmission info descriptors.
+		if len(sl.Data) < 2 {
+			return nil, sl
+		}
+		n := loopLen(sl.Data[0:2]) + 2
+		if len(sl.Data) < n {
+			return nil, sl
+		}
+		sl.Data = sl.Data[n:]
+		if len(sl.Data) == 0 {
+			return sl.Pop()
+		}
+	}
+	if len(sl.Data) < 4 {
+		return nil, sl
+	}
+	n := loopLen(sl.Data[2:4]) + 4
+	if len(sl.Data) < n {
+		return nil, sl
+	}
+	data := sl.Data[:n]
+	sl.Data = sl.Data[n:]
+	return data, sl
+}
+
+type SITService []byte
+
+func MakeSITService() SITService {
+	return SITService{0, 0, 0x80, 0}
+}
+
+func (ss SITService) ServiceId() uint16 {
+	return decodeU16(ss[0:2])
+}
+
+func (ss SITService) SetServiceId(sid uint16) {
+	encodeU16(ss[0:2], sid)
+}
+
+// Status returns the value of running_status field.
+func (ss SITService) Status() ServiceStatus {
+	return ServiceStatus(ss[2] >> 4 & 0x07)
+}
+
+// SetStatus sets running_status field.
+func (ss SITService) SetStatus(s ServiceStatus) {
+	ss[2] = ss[2]&0x8f | byte(s&0x07)<<4
+}
+
+func (ss SITService) descrLoopLen() int {
+	return loopLen(ss[2:4])
+}
+
+func (ss SITService) setDescrLoopLen(n int) {
+	setLoopLen(ss[2:4], n)
+}
+
+func (ss SITService) Descriptors() DescriptorList {
+	return DescriptorList(ss[4 : 4+ss.descrLoopLen()])
+}
+
+// ClearDescriptors clears service_loop_length field.
+func (ss SITService) ClearDescriptors() {
+	ss.setDescrLoopLen(0)
+}
+
+func (ss *SITService) AppendDescriptors(ds ...Descriptor) {
+	n := ss.descrLoopLen()
+	for _, d := range ds {
+		*ss = append((*ss)[:4+n], d...)
+		n += len(d)
+	}
+	ss.setDescrLoopLen(n)
+}
@@ -0,0 +1,91 @@
+package psi_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ziutek/dvb/ts/psi"
+)
+
+func TestSIT(t *testing.T) {
+	var sit psi.SIT
+	sit.AppendDescriptor(psi.MakePrivateDataSpecifierDescriptor(0x28))
+	for i := 0; i < 400; i++ {
+		ss := psi.MakeSITService()
+		ss.SetServiceId(uint16(i))
+		ss.SetStatus(psi.Running)
+		ss.AppendDescriptors(psi.MakeServiceDescriptor(1, "prov", "serv"))
+		sit.AppendService(ss)
+	}
+	sit.Close(true, 3)
+	if len(sit) < 2 {
+		t.Fatal("expected more than one section, got", len(sit))
+	}
+	l := sectionList(sit)
+	var rsit psi.SIT
+	if err := rsit.Update(&l, true); err != nil {
+		t.Fatal(err)
+	}
+	if rsit.Version() != 3 {
+		t.Error("version:", rsit.Version())
+	}
+	d, _ := rsit.Descriptors().Pop()
+	if d.Tag() != psi.PrivateDataSpecifierTag || !bytes.Equal(d.Data(), []byte{0, 0, 0, 0x28}) {
+		t.Error("bad transmission info descriptor:", d)
+	}
+	sl := rsit.Services()
+	n := 0
+	for !sl.IsEmpty() {
+		var ss psi.SITService
+		ss, sl = sl.Pop()
+		if ss == nil {
+			t.Fatal("bad service list")
+		}
+		if ss.ServiceId() != uint16(n) || ss.Status() != psi.Running {
+			t.Fatal("bad service:", ss.ServiceId(), ss.Status())
+		}
+		if d, _ := ss.Descriptors().Pop(); d == nil || d.Tag() != psi.ServiceTag {
+			t.Fatal("bad service descriptor")
+		}
+		n++
+	}
+	if n != 400 {
+		t.Error("services:", n)
+	}
+}
+
+func TestRST(t *testing.T) {
+	rs := []psi.RunningStatus{
+		{MuxId: 1, OrgNetId: 2, ServiceId: 3, EventId: 4, Status: psi.Running},
+		{MuxId: 5, OrgNetId: 6, ServiceId: 7, EventId: 8, Status: psi.Pausing},
+	}
+	rst, err := psi.ParseRST(psi.Section(psi.MakeRST(rs...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rst.Len() != len(rs) {
+		t.Fatal("len:", rst.Len())
+	}
+	for i, r := range rs {
+		if rst.RunningStatus(i) != r {
+			t.Errorf("%d: %+v != %+v", i, rst.RunningStatus(i), r)
+		}
+	}
+	for _, tr := range []bool{false, true} {
+		if v, err := psi.ParseDIT(psi.Section(psi.MakeDIT(tr))); err != nil || v != tr {
+			t.Error("DIT:", v, err)
+		}
+	}
+}
+
+func TestSITServiceDefault(t *testing.T) {
+	if s := psi.MakeSITService().Status(); s != 0 {
+		t.Error("default running status:", s)
+	}
+}
+
+func TestParseDITShort(t *testing.T) {
+	if _, err := psi.ParseDIT(psi.Section{0x7e, 0x70, 0x01}); err == nil {
+		t.Error("short DIT accepted")
+	}
+}
@@ -0,0 +1,250 @@
+package psi
+
+// Subtitling describes one DVB subtitle service (EN 300 743).
+type Subtitling struct {
+	Lang              ISO639LangCode
+	Type              byte // subtitling_type (component_type for stream_content 0x3)
+	CompositionPageId uint16
+	AncillaryPageId   uint16
+}
+
+// HardOfHearing returns true if subtitles are intended for the hard of hearing.
+func (s Subtitling) HardOfHearing() bool {
+	return s.Type >= 0x20 && s.Type <= 0x25
+}
+
+type SubtitlingDescriptor []byte
+
+func ParseSubtitlingDescriptor(d Descriptor) (sd SubtitlingDescriptor, ok bool) {
+	if d.Tag() != SubtitlingTag {
+		return
+	}
+	data := d.Data()
+	if len(data)%8 != 0 {
+		return
+	}
+	return SubtitlingDescriptor(data), true
+}
+
+// Pop returns first Subtitling from d. Remaining entries are returned in rd.
+// If there is no more entries to read len(rd) == 0. If an error occurs
+// rd = nil.
+func (d SubtitlingDescriptor) Pop() (s Subtitling, rd SubtitlingDescriptor) {
+	if len(d) < 8 {
+		return
+	}
+	s.Lang = ISO639LangCode(decodeU24(d[0:3]))
+	s.Type = d[3]
+	s.CompositionPageId = decodeU16(d[4:6])
+	s.AncillaryPageId = decodeU16(d[6:8])
+	rd = d[8:]
+	return
+}
+
+func (sd *SubtitlingDescriptor) Append(s Subtitling) {
+	var el [8]byte
+	el[0] = byte(s.Lang >> 16)
+	el[1] = byte(s.Lang >> 8)
+	el[2] = byte(s.Lang)
+	el[3] = s.Type
+	encodeU16(el[4:6], s.CompositionPageId)
+	encodeU16(el[6:8], s.AncillaryPageId)
+	*sd = append(*sd, el[:]...)
+}
+
+func (sd SubtitlingDescriptor) MakeDescriptor() Descriptor {
+	d := MakeDescriptor(SubtitlingTag, len(sd))
+	copy(d.Data(), sd)
+	return d
+}
+
+type TeletextType byte
+
+const (
+	TeletextInitialPage TeletextType = iota + 1
+	TeletextSubtitlePage
+	TeletextAdditionalInfoPage
+	TeletextSchedulePage
+	TeletextHearingImpairedSubtitlePage
+)
+
+var ttn = []string{
+	"initial page",
+	"subtitle page",
+	"additional information page",
+	"programme schedule page",
+	"subtitle page for hearing impaired",
+}
+
+func (t TeletextType) String() string {
+	if t == 0 || t > TeletextHearingImpairedSubtitlePage {
+		return "reserved"
+	}
+	return ttn[t-1]
+}
+
+// TeletextPage describes one Teletext page.
+type TeletextPage struct {
+	Lang     ISO639LangCode
+	Type     TeletextType
+	Magazine byte // 3 bits, 0 means magazine 8
+	Page     byte // page number in BCD
+}
+
+// Number returns three digit page number as displayed by receivers (e.g. 888)
+// or -1 if Page isn't a valid BCD number.
+func (p TeletextPage) Number() int {
+	n := decodeBCD(p.Page)
+	if n < 0 {
+		return -1
+	}
+	m := int(p.Magazine & 0x07)
+	if m == 0 {
+		m = 8
+	}
+	return m*100 + n
+}
+
+// SetNumber sets Magazine and Page using three digit page number n
+// (100 <= n <= 899).
+func (p *TeletextPage) SetNumber(n int) {
+	if n < 100 || n > 899 {
+		panic("psi: bad Teletext page number")
+	}
+	p.Magazine = byte(n/100) & 0x07
+	p.Page = encodeBCD(n % 100)
+}
+
+// TeletextDescriptor represents teletext_descriptor or
+// VBI_teletext_descriptor.
+type TeletextDescriptor []byte
+
+func ParseTeletextDescriptor(d Descriptor) (td TeletextDescriptor, ok bool) {
+	if d.Tag() != TeletextTag && d.Tag() != VBITeletextTag {
+		return
+	}
+	data := d.Data()
+	if len(data)%5 != 0 {
+		return
+	}
+	return TeletextDescriptor(data), true
+}
+
+// Pop returns first TeletextPage from d. Remaining pages are returned in rd.
+// If there is no more pages to read len(rd) == 0. If an error occurs
+// rd = nil.
+func (d TeletextDescriptor) Pop() (p TeletextPage, rd TeletextDescriptor) {
+	if len(d) < 5 {
+		return
+	}
+	p.Lang = ISO639LangCode(decodeU24(d[0:3]))
+	p.Type = TeletextType(d[3] >> 3)
+	p.Magazine = d[3] & 0x07
+	p.Page = d[4]
+	rd = d[5:]
+	return
+}
+
+func (td *TeletextDescriptor) Append(p TeletextPage) {
+	*td = append(
+		*td,
+		byte(p.Lang>>16), byte(p.Lang>>8), byte(p.Lang),
+		byte(p.Type)<<3|p.Magazine&0x07, p.Page,
+	)
+}
+
+func (td TeletextDescriptor) MakeDescriptor() Descriptor {
+	d := MakeDescriptor(TeletextTag, len(td))
+	copy(d.Data(), td)
+	return d
+}
+
+type VBIDataService byte
+
+const (
+	VBIEBUTeletext         VBIDataService = 0x01
+	VBIInvertedTeletext    VBIDataService = 0x02
+	VBIVPS                 VBIDataService = 0x04
+	VBIWSS                 VBIDataService = 0x05
+	VBIClosedCaptioning    VBIDataService = 0x06
+	VBIMonochrome422Sample VBIDataService = 0x07
+)
+
+// VBILine identifies one VBI line.
+type VBILine struct {
+	FieldParity bool // true for first (odd) field
+	LineOffset  byte // 5 bits, 0 means unspecified line
+}
+
+// VBIData is an entry of VBI_data_descriptor.
+type VBIData struct {
+	Service VBIDataService
+	Lines   []VBILine // nil for services that don't use line descriptions
+}
+
+type VBIDataDescriptor []byte
+
+func ParseVBIDataDescriptor(d Descriptor) (vd VBIDataDescriptor, ok bool) {
+	if d.Tag() != VBIDataTag {
+		return
+	}
+	return VBIDataDescriptor(d.Data()), true
+}
+
+// Pop returns first VBIData from d. Remaining entries are returned in rd.
+// If there is no more entries to read len(rd) == 0. If an error occurs
+// rd = nil.
+func (d VBIDataDescriptor) Pop() (vd VBIData, rd VBIDataDescriptor) {
+	if len(d) < 2 {
+		return
+	}
+	n := int(d[1]) + 2
+	if len(d) < n {
+		return
+	}
+	vd.Service = VBIDataService(d[0])
+	switch vd.Service {
+	case VBIEBUTeletext, VBIInvertedTeletext, VBIVPS, VBIWSS,
+		VBIClosedCaptioning, VBIMonochrome422Sample:
+
+		vd.Lines = make([]VBILine, n-2)
+		for i, b := range d[2:n] {
+			vd.Lines[i] = VBILine{b&0x20 != 0, b & 0x1f}
+		}
+	}
+	rd = d[n:]
+	return
+}
+
+// IsSubtitles returns true if i describes DVB subtitles or Teletext stream
+// that contains subtitle pages.
+func (i ESInfo) IsSubtitles() bool {
+	if i.Type() != PrivPES {
+		return false
+	}
+	dl := i.Descriptors()
+	for len(dl) != 0 {
+		var d Descriptor
+		if d, dl = dl.Pop(); d == nil {
+			break
+		}
+		if _, ok := ParseSubtitlingDescriptor(d); ok {
+			return true
+		}
+		td, ok := ParseTeletextDescriptor(d)
+		if !ok {
+			continue
+		}
+		for len(td) != 0 {
+			var p TeletextPage
+			if p, td = td.Pop(); td == nil {
+				break
+			}
+			if p.Type == TeletextSubtitlePage ||
+				p.Type == TeletextHearingImpairedSubtitlePage {
+				return true
+			}
+		}
+	}
+	return false
+}
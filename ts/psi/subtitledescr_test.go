@@ -0,0 +1,52 @@
+package psi_test
+
+import (
+	"testing"
+
+	"github.com/ziutek/dvb/ts/psi"
+)
+
+func TestSubtitles(t *testing.T) {
+	var td psi.TeletextDescriptor
+	p := psi.TeletextPage{Lang: 0x706f6c, Type: psi.TeletextSubtitlePage}
+	p.SetNumber(888)
+	td.Append(p)
+	rtd, ok := psi.ParseTeletextDescriptor(td.MakeDescriptor())
+	if !ok {
+		t.Fatal("can't parse teletext descriptor")
+	}
+	if rp, _ := rtd.Pop(); rp != p || rp.Number() != 888 || rp.Magazine != 0 {
+		t.Errorf("bad teletext page: %+v", rp)
+	}
+
+	var sd psi.SubtitlingDescriptor
+	s := psi.Subtitling{
+		Lang: 0x706f6c, Type: 0x24, CompositionPageId: 1, AncillaryPageId: 2,
+	}
+	sd.Append(s)
+	rsd, ok := psi.ParseSubtitlingDescriptor(sd.MakeDescriptor())
+	if !ok {
+		t.Fatal("can't parse subtitling descriptor")
+	}
+	if rs, _ := rsd.Pop(); rs != s || !rs.HardOfHearing() {
+		t.Errorf("bad subtitling: %+v", rs)
+	}
+
+	for _, d := range []psi.Descriptor{td.MakeDescriptor(), sd.MakeDescriptor()} {
+		es := psi.ESInfo{byte(psi.PrivPES), 0xe1, 0x00, 0xf0, byte(len(d))}
+		es = append(es, d...)
+		if !es.IsSubtitles() {
+			t.Errorf("%v: not subtitles", d.Tag())
+		}
+	}
+
+	vd := psi.VBIDataDescriptor{0x01, 2, 0x35, 0x15, 0x03, 1, 0xff}
+	v, rvd := vd.Pop()
+	if v.Service != psi.VBIEBUTeletext || len(v.Lines) != 2 ||
+		v.Lines[0] != (psi.VBILine{FieldParity: true, LineOffset: 0x15}) {
+		t.Errorf("bad VBI data: %+v", v)
+	}
+	if v, rvd = rvd.Pop(); v.Service != 0x03 || v.Lines != nil || len(rvd) != 0 {
+		t.Errorf("bad VBI data: %+v", v)
+	}
+}
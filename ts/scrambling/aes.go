@@ -0,0 +1,74 @@
+package scrambling
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+)
+
+// IV defined by DVB-CISSA (ETSI TS 103 127).
+var cissaIV = [aes.BlockSize]byte{
+	'D', 'V', 'B', 'T', 'M', 'C', 'P', 'T', 'A', 'E', 'S', 'C', 'I', 'S', 'S', 'A',
+}
+
+// aesKey implements AES-128 CBC based scrambling modes. If idsa is false it
+// implements DVB-CISSA: CBC with fixed IV, residue left in clear. If idsa is
+// true it implements ATIS IDSA: CBC with zero IV, residue encrypted by XOR
+// with encrypted last full cipher block (or IV) as in ANSI/SCTE 52.
+type aesKey struct {
+	b    cipher.Block
+	iv   [aes.BlockSize]byte
+	idsa bool
+}
+
+func newAESKey(cw []byte, idsa bool) (*aesKey, error) {
+	b, err := aes.NewCipher(cw)
+	if err != nil {
+		return nil, err
+	}
+	k := &aesKey{b: b, idsa: idsa}
+	if !idsa {
+		k.iv = cissaIV
+	}
+	return k, nil
+}
+
+func xorBlock(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+func (k *aesKey) residue(res, last []byte) {
+	if !k.idsa || len(res) == 0 {
+		return
+	}
+	var ks [aes.BlockSize]byte
+	k.b.Encrypt(ks[:], last)
+	xorBlock(res, ks[:len(res)])
+}
+
+func (k *aesKey) decrypt(buf []byte) {
+	n := len(buf) / aes.BlockSize * aes.BlockSize
+	prev := k.iv
+	var c [aes.BlockSize]byte
+	for i := 0; i < n; i += aes.BlockSize {
+		blk := buf[i : i+aes.BlockSize]
+		copy(c[:], blk)
+		k.b.Decrypt(blk, blk)
+		xorBlock(blk, prev[:])
+		prev = c
+	}
+	k.residue(buf[n:], prev[:])
+}
+
+func (k *aesKey) encrypt(buf []byte) {
+	n := len(buf) / aes.BlockSize * aes.BlockSize
+	prev := k.iv[:]
+	for i := 0; i < n; i += aes.BlockSize {
+		blk := buf[i : i+aes.BlockSize]
+		xorBlock(blk, prev)
+		k.b.Encrypt(blk, blk)
+		prev = blk
+	}
+	k.residue(buf[n:], prev)
+}
@@ -0,0 +1,312 @@
+package scrambling
+
+// DVB Common Scrambling Algorithm (CSA1). Scrambled payload is a chain of 8
+// byte blocks encrypted by block cipher in reverse CBC mode and then
+// additionally encrypted by stream cipher initialized by first block. Residue
+// (last len%8 bytes) is encrypted by stream cipher only.
+
+var csaBlockSbox = [256]byte{
+	0x3a, 0xea, 0x68, 0xfe, 0x33, 0xe9, 0x88, 0x1a,
+	0x83, 0xcf, 0xe1, 0x7f, 0xba, 0xe2, 0x38, 0x12,
+	0xe8, 0x27, 0x61, 0x95, 0x0c, 0x36, 0xe5, 0x70,
+	0xa2, 0x06, 0x82, 0x7c, 0x17, 0xa3, 0x26, 0x49,
+	0xbe, 0x7a, 0x6d, 0x47, 0xc1, 0x51, 0x8f, 0xf3,
+	0xcc, 0x5b, 0x67, 0xbd, 0xcd, 0x18, 0x08, 0xc9,
+	0xff, 0x69, 0xef, 0x03, 0x4e, 0x48, 0x4a, 0x84,
+	0x3f, 0xb4, 0x10, 0x04, 0xdc, 0xf5, 0x5c, 0xc6,
+	0x16, 0xab, 0xac, 0x4c, 0xf1, 0x6a, 0x2f, 0x3c,
+	0x3b, 0xd4, 0xd5, 0x94, 0xd0, 0xc4, 0x63, 0x62,
+	0x71, 0xa1, 0xf9, 0x4f, 0x2e, 0xaa, 0xc5, 0x56,
+	0xe3, 0x39, 0x93, 0xce, 0x65, 0x64, 0xe4, 0x58,
+	0x6c, 0x19, 0x42, 0x79, 0xdd, 0xee, 0x96, 0xf6,
+	0x8a, 0xec, 0x1e, 0x85, 0x53, 0x45, 0xde, 0xbb,
+	0x7e, 0x0a, 0x9a, 0x13, 0x2a, 0x9d, 0xc2, 0x5e,
+	0x5a, 0x1f, 0x32, 0x35, 0x9c, 0xa8, 0x73, 0x30,
+	0x29, 0x3d, 0xe7, 0x92, 0x87, 0x1b, 0x2b, 0x4b,
+	0xa5, 0x57, 0x97, 0x40, 0x15, 0xe6, 0xbc, 0x0e,
+	0xeb, 0xc3, 0x34, 0x2d, 0xb8, 0x44, 0x25, 0xa4,
+	0x1c, 0xc7, 0x23, 0xed, 0x90, 0x6e, 0x50, 0x00,
+	0x99, 0x9e, 0x4d, 0xd9, 0xda, 0x8d, 0x6f, 0x5f,
+	0x3e, 0xd7, 0x21, 0x74, 0x86, 0xdf, 0x6b, 0x05,
+	0x8e, 0x5d, 0x37, 0x11, 0xd2, 0x28, 0x75, 0xd6,
+	0xa7, 0x77, 0x24, 0xbf, 0xf0, 0xb0, 0x02, 0xb7,
+	0xf8, 0xfc, 0x81, 0x09, 0xb1, 0x01, 0x76, 0x91,
+	0x7d, 0x0f, 0xc8, 0xa0, 0xf2, 0xcb, 0x78, 0x60,
+	0xd1, 0xf7, 0xe0, 0xb5, 0x98, 0x22, 0xb3, 0x20,
+	0x1d, 0xa6, 0xdb, 0x7b, 0x59, 0x9f, 0xae, 0x31,
+	0xfb, 0xd3, 0xb6, 0xca, 0x43, 0x72, 0x07, 0xf4,
+	0xd8, 0x41, 0x14, 0x55, 0x0d, 0x54, 0x8b, 0xb9,
+	0xad, 0x46, 0x0b, 0xaf, 0x80, 0x52, 0x2c, 0xfa,
+	0x8c, 0x89, 0x66, 0xfd, 0xb2, 0xa9, 0x9b, 0xc0,
+}
+
+// Key schedule bit permutation (1-based bit numbers).
+var csaKeyPerm = [64]byte{
+	0x12, 0x24, 0x09, 0x07, 0x2a, 0x31, 0x1d, 0x15,
+	0x1c, 0x36, 0x3e, 0x32, 0x13, 0x21, 0x3b, 0x40,
+	0x18, 0x14, 0x25, 0x27, 0x02, 0x35, 0x1b, 0x01,
+	0x22, 0x04, 0x0d, 0x0e, 0x39, 0x28, 0x1a, 0x29,
+	0x33, 0x23, 0x34, 0x0c, 0x16, 0x30, 0x1e, 0x3a,
+	0x2d, 0x1f, 0x08, 0x19, 0x17, 0x2f, 0x3d, 0x11,
+	0x3c, 0x05, 0x38, 0x2b, 0x0b, 0x06, 0x0a, 0x2c,
+	0x20, 0x3f, 0x2e, 0x0f, 0x03, 0x26, 0x10, 0x37,
+}
+
+var csaStreamSbox = [7][32]byte{
+	{
+		2, 0, 1, 1, 2, 3, 3, 0, 3, 2, 2, 0, 1, 1, 0, 3,
+		0, 3, 3, 0, 2, 2, 1, 1, 2, 2, 0, 3, 1, 1, 3, 0,
+	},
+	{
+		3, 1, 0, 2, 2, 3, 3, 0, 1, 3, 2, 1, 0, 0, 1, 2,
+		3, 1, 0, 3, 3, 2, 0, 2, 0, 0, 1, 2, 2, 1, 3, 1,
+	},
+	{
+		2, 0, 1, 2, 2, 3, 3, 1, 1, 1, 0, 3, 3, 0, 2, 0,
+		1, 3, 0, 1, 3, 0, 2, 2, 2, 0, 1, 2, 0, 3, 3, 1,
+	},
+	{
+		3, 1, 2, 3, 0, 2, 1, 2, 1, 2, 0, 1, 3, 0, 0, 3,
+		1, 0, 3, 1, 2, 3, 0, 3, 0, 3, 2, 0, 1, 2, 2, 1,
+	},
+	{
+		2, 0, 0, 1, 3, 2, 3, 2, 0, 1, 3, 3, 1, 0, 2, 1,
+		2, 3, 2, 0, 0, 3, 1, 1, 1, 0, 3, 2, 3, 1, 0, 2,
+	},
+	{
+		0, 1, 2, 3, 1, 2, 2, 0, 0, 1, 3, 0, 2, 3, 1, 3,
+		2, 3, 0, 2, 3, 0, 1, 1, 2, 1, 1, 2, 0, 3, 3, 0,
+	},
+	{
+		0, 3, 2, 2, 3, 0, 0, 1, 3, 0, 1, 3, 1, 2, 2, 1,
+		1, 0, 3, 3, 0, 1, 1, 2, 2, 3, 1, 0, 2, 3, 0, 2,
+	},
+}
+
+func csaBlockPerm(s byte) byte {
+	return s&0x01<<1 | s&0x02<<6 | s&0x04<<3 | s&0x08<<1 |
+		s&0x10>>2 | s&0x20<<1 | s&0x40>>6 | s&0x80>>4
+}
+
+// csaKey contains control word and expanded block cipher key.
+type csaKey struct {
+	cw [8]byte
+	kk [56]byte
+}
+
+func newCSAKey(cw []byte) *csaKey {
+	k := new(csaKey)
+	copy(k.cw[:], cw)
+	kb := k.cw
+	for i := 6; i >= 0; i-- {
+		for j := 0; j < 8; j++ {
+			k.kk[i*8+j] = kb[j] ^ byte(i)
+		}
+		var nb [8]byte
+		for n := 0; n < 64; n++ {
+			bit := kb[n/8] >> (7 - uint(n%8)) & 1
+			m := int(csaKeyPerm[n]) - 1
+			nb[m/8] |= bit << (7 - uint(m%8))
+		}
+		kb = nb
+	}
+	return k
+}
+
+func (k *csaKey) blockDecrypt(w *[8]byte) {
+	for i := 55; i >= 0; i-- {
+		s := csaBlockSbox[k.kk[i]^w[6]]
+		l := w[7] ^ s
+		w[7] = w[6]
+		w[6] = w[5] ^ csaBlockPerm(s)
+		w[5] = w[4]
+		w[4] = w[3] ^ l
+		w[3] = w[2] ^ l
+		w[2] = w[1] ^ l
+		w[1] = w[0]
+		w[0] = l
+	}
+}
+
+func (k *csaKey) blockEncrypt(w *[8]byte) {
+	for i := 0; i < 56; i++ {
+		l := w[0]
+		w[0] = w[1]
+		w[1] = w[2] ^ l
+		w[2] = w[3] ^ l
+		w[3] = w[4] ^ l
+		w[4] = w[5]
+		s := csaBlockSbox[k.kk[i]^w[7]]
+		w[5] = w[6] ^ csaBlockPerm(s)
+		w[6] = w[7]
+		w[7] = l ^ s
+	}
+}
+
+// csaStream is a state of CSA stream cipher.
+type csaStream struct {
+	a, b    [11]byte // a[1:], b[1:] are 4-bit registers
+	x, y, z byte
+	d, e, f byte
+	p, q, r byte
+}
+
+func bit(v byte, n uint) byte {
+	return v >> n & 1
+}
+
+// clock runs stream cipher for one cycle. If init is true in1 and in2 are
+// input nibbles for A and B registers. It returns two bits of keystream.
+func (c *csaStream) clock(init bool, in1, in2 byte) byte {
+	a, b := &c.a, &c.b
+	sb := &csaStreamSbox
+	s1 := sb[0][bit(a[4], 0)<<4|bit(a[1], 2)<<3|bit(a[6], 1)<<2|bit(a[7], 3)<<1|bit(a[9], 0)]
+	s2 := sb[1][bit(a[2], 1)<<4|bit(a[3], 2)<<3|bit(a[6], 3)<<2|bit(a[7], 0)<<1|bit(a[9], 1)]
+	s3 := sb[2][bit(a[1], 3)<<4|bit(a[2], 0)<<3|bit(a[5], 1)<<2|bit(a[5], 3)<<1|bit(a[6], 2)]
+	s4 := sb[3][bit(a[3], 3)<<4|bit(a[1], 1)<<3|bit(a[2], 3)<<2|bit(a[4], 2)<<1|bit(a[8], 0)]
+	s5 := sb[4][bit(a[5], 2)<<4|bit(a[4], 3)<<3|bit(a[6], 0)<<2|bit(a[8], 1)<<1|bit(a[9], 2)]
+	s6 := sb[5][bit(a[3], 1)<<4|bit(a[4], 1)<<3|bit(a[5], 0)<<2|bit(a[7], 2)<<1|bit(a[9], 3)]
+	s7 := sb[6][bit(a[2], 2)<<4|bit(a[3], 0)<<3|bit(a[7], 1)<<2|bit(a[8], 2)<<1|bit(a[8], 3)]
+
+	extraB := (b[3]&1<<3 ^ b[6]&2<<2 ^ b[7]&4<<1 ^ b[9]&8) |
+		(b[6]&1<<2 ^ b[8]&2<<1 ^ b[3]&8>>1 ^ b[4]&4) |
+		(b[5]&8>>2 ^ b[8]&4>>1 ^ b[4]&1<<1 ^ b[5]&2) |
+		(b[9]&4>>2 ^ b[6]&8>>3 ^ b[3]&2>>1 ^ b[8]&1)
+
+	nextA1 := a[10] ^ c.x
+	nextB1 := b[7] ^ b[10] ^ c.y
+	if init {
+		nextA1 ^= c.d ^ in1
+		nextB1 ^= in2
+	}
+	if c.p != 0 {
+		nextB1 = (nextB1<<1 | nextB1>>3&1) & 0x0f
+	}
+
+	c.d = c.e ^ c.z ^ extraB
+
+	nextE := c.f
+	if c.q != 0 {
+		f := c.z + c.e + c.r
+		c.r = f >> 4 & 1
+		c.f = f & 0x0f
+	} else {
+		c.f = c.e
+	}
+	c.e = nextE
+
+	copy(a[2:], a[1:10])
+	copy(b[2:], b[1:10])
+	a[1] = nextA1
+	b[1] = nextB1
+
+	c.x = s4&1<<3 | s3&1<<2 | s2&2 | s1&2>>1
+	c.y = s6&1<<3 | s5&1<<2 | s4&2 | s3&2>>1
+	c.z = s2&1<<3 | s1&1<<2 | s7&2 | s6&2>>1
+	c.p = s7 & 2 >> 1
+	c.q = s7 & 1
+
+	dd := c.d ^ c.d>>1
+	return dd>>1&2 | dd&1
+}
+
+// init initializes stream cipher using control word cw and first scrambled
+// block sb.
+func (c *csaStream) init(cw *[8]byte, sb []byte) {
+	*c = csaStream{}
+	for i := 0; i < 4; i++ {
+		c.a[1+2*i] = cw[i] >> 4
+		c.a[2+2*i] = cw[i] & 0x0f
+		c.b[1+2*i] = cw[4+i] >> 4
+		c.b[2+2*i] = cw[4+i] & 0x0f
+	}
+	for i := 0; i < 8; i++ {
+		in1, in2 := sb[i]>>4, sb[i]&0x0f
+		for j := 0; j < 4; j++ {
+			if j%2 == 0 {
+				c.clock(true, in1, in2)
+			} else {
+				c.clock(true, in2, in1)
+			}
+		}
+	}
+}
+
+// next generates next 8 bytes of keystream into ks.
+func (c *csaStream) next(ks *[8]byte) {
+	for i := range ks {
+		var op byte
+		for j := 0; j < 4; j++ {
+			op = op<<2 ^ c.clock(false, 0, 0)
+		}
+		ks[i] = op
+	}
+}
+
+func (k *csaKey) decrypt(buf []byte) {
+	n := len(buf) / 8
+	if n == 0 {
+		return // Payloads shorter than 8 bytes are not scrambled.
+	}
+	var (
+		s      csaStream
+		ib, ks [8]byte
+	)
+	copy(ib[:], buf)
+	s.init(&k.cw, ib[:])
+	for i := 0; i < n; i++ {
+		blk := ib
+		k.blockDecrypt(&blk)
+		if i+1 < n {
+			s.next(&ks)
+			for j := range ib {
+				ib[j] = buf[8*(i+1)+j] ^ ks[j]
+			}
+		} else {
+			ib = [8]byte{}
+		}
+		for j := range blk {
+			buf[8*i+j] = ib[j] ^ blk[j]
+		}
+	}
+	if res := buf[8*n:]; len(res) > 0 {
+		s.next(&ks)
+		for j := range res {
+			res[j] ^= ks[j]
+		}
+	}
+}
+
+func (k *csaKey) encrypt(buf []byte) {
+	n := len(buf) / 8
+	if n == 0 {
+		return
+	}
+	// Block layer: ib[i] = E(ib[i+1] ^ p[i]), ib[n] = 0.
+	var ib [8]byte
+	for i := n - 1; i >= 0; i-- {
+		for j := range ib {
+			ib[j] ^= buf[8*i+j]
+		}
+		k.blockEncrypt(&ib)
+		copy(buf[8*i:], ib[:])
+	}
+	// Stream layer.
+	var (
+		s  csaStream
+		ks [8]byte
+	)
+	s.init(&k.cw, buf[:8])
+	for i := 1; i < n; i++ {
+		s.next(&ks)
+		for j := range ks {
+			buf[8*i+j] ^= ks[j]
+		}
+	}
+	if res := buf[8*n:]; len(res) > 0 {
+		s.next(&ks)
+		for j := range res {
+			res[j] ^= ks[j]
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Package scrambling implements DVB-CSA1 and AES based (DVB-CISSA, ATIS IDSA)
+// scrambling and descrambling of MPEG-TS packets.
+package scrambling
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/ziutek/dvb/ts"
+)
+
+// Mode specifies scrambling algorithm.
+type Mode byte
+
+const (
+	CSA   Mode = iota // DVB-CSA1 (8 byte control words)
+	CISSA             // DVB-CISSA: AES-128 CBC (16 byte control words)
+	IDSA              // ATIS IDSA: AES-128 CBC (16 byte control words)
+)
+
+var modeNames = []string{"CSA", "CISSA", "IDSA"}
+
+func (m Mode) String() string {
+	if int(m) >= len(modeNames) {
+		return "unknown"
+	}
+	return modeNames[m]
+}
+
+// CWLen returns length of control word for mode m.
+func (m Mode) CWLen() int {
+	if m == CSA {
+		return 8
+	}
+	return 16
+}
+
+const (
+	Even = ts.PktScrambled2 // transport_scrambling_control for even key
+	Odd  = ts.PktScrambled3 // transport_scrambling_control for odd key
+
+	// AnyPid can be used to set default keys used for PIDs without own keys.
+	AnyPid int16 = -1
+)
+
+var (
+	ErrCWLen  = errors.New("scrambling: bad control word length")
+	ErrParity = errors.New("scrambling: parity should be Even or Odd")
+)
+
+type cipherKey interface {
+	decrypt(buf []byte)
+	encrypt(buf []byte)
+}
+
+func newKey(m Mode, cw []byte) (cipherKey, error) {
+	if len(cw) != m.CWLen() {
+		return nil, ErrCWLen
+	}
+	switch m {
+	case CSA:
+		return newCSAKey(cw), nil
+	case CISSA:
+		return newAESKey(cw, false)
+	case IDSA:
+		return newAESKey(cw, true)
+	}
+	return nil, errors.New("scrambling: unknown mode")
+}
+
+// keySet contains even and odd keys.
+type keySet [2]cipherKey
+
+func (ks *keySet) get(sc ts.PktScramblingCtrl) cipherKey {
+	return ks[sc&1]
+}
+
+type keyMap struct {
+	mode Mode
+	mu   sync.RWMutex
+	keys map[int16]*keySet
+}
+
+func (km *keyMap) setKey(pid int16, parity ts.PktScramblingCtrl, cw []byte) error {
+	if parity != Even && parity != Odd {
+		return ErrParity
+	}
+	k, err := newKey(km.mode, cw)
+	if err != nil {
+		return err
+	}
+	km.mu.Lock()
+	if km.keys == nil {
+		km.keys = make(map[int16]*keySet)
+	}
+	ks := km.keys[pid]
+	if ks == nil {
+		ks = new(keySet)
+		km.keys[pid] = ks
+	}
+	ks[parity&1] = k
+	km.mu.Unlock()
+	return nil
+}
+
+func (km *keyMap) delKeys(pid int16) {
+	km.mu.Lock()
+	delete(km.keys, pid)
+	km.mu.Unlock()
+}
+
+func (km *keyMap) key(pid int16, parity ts.PktScramblingCtrl) cipherKey {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	ks := km.keys[pid]
+	if ks == nil {
+		if ks = km.keys[AnyPid]; ks == nil {
+			return nil
+		}
+	}
+	return ks.get(parity)
+}
+
+// Descrambler descrambles packets using control words set by SetKey. Packets
+// without known key are passed unchanged. SetKey can be called concurrently
+// with Descramble and ReplacePkt.
+type Descrambler struct {
+	km keyMap
+	r  ts.PktReplacer
+}
+
+// NewDescrambler returns descrambler that uses mode m and reads packets from
+// r (r can be nil if you use only Descramble method).
+func NewDescrambler(r ts.PktReplacer, m Mode) *Descrambler {
+	return &Descrambler{km: keyMap{mode: m}, r: r}
+}
+
+// SetKey sets even or odd control word for pid. Use AnyPid to set default
+// keys.
+func (d *Descrambler) SetKey(pid int16, parity ts.PktScramblingCtrl, cw []byte) error {
+	return d.km.setKey(pid, parity, cw)
+}
+
+// DelKeys removes keys for pid.
+func (d *Descrambler) DelKeys(pid int16) {
+	d.km.delKeys(pid)
+}
+
+// Descramble descrambles p in place and clears its scrambling control bits.
+// It returns false if p is scrambled but there is no key to descramble it.
+func (d *Descrambler) Descramble(p *ts.ArrayPkt) bool {
+	sc := p.ScramblingCtrl()
+	if sc == ts.PktNotScrambled {
+		return true
+	}
+	if sc != Even && sc != Odd {
+		return false // Reserved value.
+	}
+	k := d.km.key(p.Pid(), sc)
+	if k == nil {
+		return false
+	}
+	if payload := p.Payload(); payload != nil {
+		k.decrypt(payload)
+	}
+	p.SetScramblingCtrl(ts.PktNotScrambled)
+	return true
+}
+
+// ReplacePkt reads next packet using underlying ts.PktReplacer and
+// descrambles it. Descrambler implements ts.PktReplacer interface.
+func (d *Descrambler) ReplacePkt(p *ts.ArrayPkt) (*ts.ArrayPkt, error) {
+	p, err := d.r.ReplacePkt(p)
+	if err != nil {
+		return p, err
+	}
+	d.Descramble(p)
+	return p, nil
+}
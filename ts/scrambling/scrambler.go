@@ -0,0 +1,116 @@
+package scrambling
+
+import (
+	"github.com/ziutek/dvb/ts"
+	"github.com/ziutek/dvb/ts/psi"
+)
+
+// Scrambler scrambles packets using control words set by SetKey. It switches
+// between even and odd keys every Period packets. The new parity is used for
+// given PID starting from the next packet with payload_unit_start_indicator
+// set, so every PES packet (or section) is scrambled using one key. SetKey can
+// be called concurrently with other methods (use it to set next key during
+// crypto-period) but Scramble, ReplacePkt and SetParity can't be called
+// concurrently.
+type Scrambler struct {
+	km     keyMap
+	w      ts.PktReplacer
+	n      int
+	parity ts.PktScramblingCtrl
+	pids   map[int16]ts.PktScramblingCtrl
+
+	// Period is a length of crypto-period in packets. Zero disables
+	// automatic key switching.
+	Period int
+}
+
+// NewScrambler returns scrambler that uses mode m and writes scrambled packets
+// to w (w can be nil if you use only Scramble method). Initial parity is
+// Even.
+func NewScrambler(w ts.PktReplacer, m Mode) *Scrambler {
+	return &Scrambler{
+		km:     keyMap{mode: m},
+		w:      w,
+		parity: Even,
+		pids:   make(map[int16]ts.PktScramblingCtrl),
+	}
+}
+
+// SetKey sets even or odd control word for pid. Use AnyPid to set default
+// keys (PIDs below 0x20 are never scrambled but you should set keys for all
+// elementary streams explicitly if PMT isn't carried on PID < 0x20).
+func (s *Scrambler) SetKey(pid int16, parity ts.PktScramblingCtrl, cw []byte) error {
+	return s.km.setKey(pid, parity, cw)
+}
+
+// DelKeys removes keys for pid.
+func (s *Scrambler) DelKeys(pid int16) {
+	s.km.delKeys(pid)
+}
+
+// Parity returns parity of current crypto-period.
+func (s *Scrambler) Parity() ts.PktScramblingCtrl {
+	return s.parity
+}
+
+// SetParity starts new crypto-period with parity.
+func (s *Scrambler) SetParity(parity ts.PktScramblingCtrl) error {
+	if parity != Even && parity != Odd {
+		return ErrParity
+	}
+	s.parity = parity
+	s.n = 0
+	return nil
+}
+
+// Scramble scrambles payload of p in place and sets its scrambling control
+// bits. It returns false if p wasn't scrambled (no key for its PID, PSI PID,
+// no payload or already scrambled).
+func (s *Scrambler) Scramble(p *ts.ArrayPkt) bool {
+	if s.Period > 0 {
+		if s.n++; s.n > s.Period {
+			s.n = 1
+			s.parity ^= 1 // Even <-> Odd
+		}
+	}
+	pid := p.Pid()
+	if pid < 0x20 || pid == ts.NullPid ||
+		p.ScramblingCtrl() != ts.PktNotScrambled {
+		return false
+	}
+	payload := p.Payload()
+	if len(payload) == 0 {
+		return false // Adaptation field only packets are never scrambled.
+	}
+	parity, ok := s.pids[pid]
+	if !ok || p.PayloadUnitStart() {
+		parity = s.parity
+		s.pids[pid] = parity
+	}
+	k := s.km.key(pid, parity)
+	if k == nil {
+		return false
+	}
+	k.encrypt(payload)
+	p.SetScramblingCtrl(parity)
+	return true
+}
+
+// ReplacePkt scrambles p and writes it using underlying ts.PktReplacer.
+// Scrambler implements ts.PktReplacer interface.
+func (s *Scrambler) ReplacePkt(p *ts.ArrayPkt) (*ts.ArrayPkt, error) {
+	s.Scramble(p)
+	return s.w.ReplacePkt(p)
+}
+
+// AddCADescriptor adds CA descriptor created from cad to program descriptors
+// of pmt, increments its version and recalculates CRC. Use psi.MakeCAT to
+// create corresponding CAT.
+func AddCADescriptor(pmt psi.PMT, cad psi.CADescriptor) error {
+	if err := pmt.AddProgramDescriptor(cad.MakeDescriptor()); err != nil {
+		return err
+	}
+	pmt.SetVersion((pmt.Version() + 1) & 0x1f)
+	pmt.MakeCRC()
+	return nil
+}
@@ -0,0 +1,193 @@
+package scrambling
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+
+	"github.com/ziutek/dvb/ts"
+	"github.com/ziutek/dvb/ts/psi"
+)
+
+func testPkt(pid int16, afLen int) *ts.ArrayPkt {
+	p := new(ts.ArrayPkt)
+	p.SetSync()
+	p.SetPid(pid)
+	p.SetContainsPayload(true)
+	if afLen > 0 {
+		p.SetContainsAF(true)
+		p[4] = byte(afLen - 1)
+		for i := 5; i < 4+afLen; i++ {
+			p[i] = 0xff
+		}
+	}
+	for i, pl := 0, p.Payload(); i < len(pl); i++ {
+		pl[i] = byte(i * 7)
+	}
+	return p
+}
+
+func TestCISSADescramble(t *testing.T) {
+	cw := []byte("0123456789abcdef")
+	d := NewDescrambler(nil, CISSA)
+	if err := d.SetKey(0x100, Odd, cw); err != nil {
+		t.Fatal(err)
+	}
+	clear := testPkt(0x100, 10) // 174 B payload: 10 blocks + 14 B residue
+	p := new(ts.ArrayPkt)
+	*p = *clear
+	p.SetScramblingCtrl(Odd)
+	b, _ := aes.NewCipher(cw)
+	pl := p.Payload()
+	n := len(pl) / 16 * 16
+	cipher.NewCBCEncrypter(b, cissaIV[:]).CryptBlocks(pl[:n], pl[:n])
+
+	if !d.Descramble(p) {
+		t.Fatal("no key")
+	}
+	if p.ScramblingCtrl() != ts.PktNotScrambled {
+		t.Fatal("scrambling bits not cleared")
+	}
+	if *p != *clear {
+		t.Fatalf("bad descrambled packet:\n%x\nexpected:\n%x", p[:], clear[:])
+	}
+
+	p = testPkt(0x101, 0)
+	p.SetScramblingCtrl(Even)
+	if d.Descramble(p) {
+		t.Fatal("descrambled without key")
+	}
+	if !bytes.Equal(p.Payload(), testPkt(0x101, 0).Payload()) {
+		t.Fatal("packet without key modified")
+	}
+}
+
+func TestScrambleRoundTrip(t *testing.T) {
+	cws := map[Mode][2][]byte{
+		CSA:   {[]byte("evenCW.."), []byte("oddCW...")},
+		CISSA: {[]byte("even key 16 byte"), []byte("odd key 16 bytes")},
+		IDSA:  {[]byte("even key 16 byte"), []byte("odd key 16 bytes")},
+	}
+	for m, cw := range cws {
+		s := NewScrambler(nil, m)
+		d := NewDescrambler(nil, m)
+		for i, parity := range []ts.PktScramblingCtrl{Even, Odd} {
+			if err := s.SetKey(0x100, parity, cw[i]); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.SetKey(AnyPid, parity, cw[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s.Period = 3
+		// Parity can change only at packets with PUSI set.
+		pusi := []bool{true, false, false, false, true, false, true}
+		expected := []ts.PktScramblingCtrl{Even, Even, Even, Even, Odd, Odd, Even}
+		for i, afLen := range []int{0, 10, 178, 183, 1, 2, 100} {
+			clear := testPkt(0x100, afLen)
+			clear.SetPayloadUnitStart(pusi[i])
+			p := new(ts.ArrayPkt)
+			*p = *clear
+			scrambled := s.Scramble(p)
+			if scrambled != (len(p.Payload()) > 0) {
+				t.Fatalf("%v: pkt %d: scrambled=%t", m, i, scrambled)
+			}
+			if scrambled {
+				if p.ScramblingCtrl() != expected[i] {
+					t.Fatalf("%v: pkt %d: bad parity", m, i)
+				}
+				if len(p.Payload()) >= m.CWLen() &&
+					bytes.Equal(p.Payload(), clear.Payload()) {
+					t.Fatalf("%v: pkt %d: payload not changed", m, i)
+				}
+			}
+			if !d.Descramble(p) {
+				t.Fatalf("%v: pkt %d: can't descramble", m, i)
+			}
+			if *p != *clear {
+				t.Fatalf("%v: pkt %d: round trip failed", m, i)
+			}
+		}
+	}
+}
+
+func TestAddCADescriptor(t *testing.T) {
+	s := psi.MakeEmptySection(psi.ISOSectionMaxLen, true)
+	s.SetTableId(2)
+	s.SetTableIdExt(1)
+	s.SetVersion(31)
+	s.SetCurrent(true)
+	s.SetNumber(0)
+	s.SetLastNumber(0)
+	copy(s.Alloc(9, 0), []byte{0xe1, 0x00, 0xf0, 0x00, 0x02, 0xe1, 0x01, 0xf0, 0x00})
+	s.MakeCRC()
+	pmt, err := psi.AsPMT(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cad := psi.CADescriptor{Sys: 0x4aea, Pid: 0x200, PrivateData: []byte{1}}
+	if err := AddCADescriptor(pmt, cad); err != nil {
+		t.Fatal(err)
+	}
+	if !s.CheckCRC() || pmt.Version() != 0 {
+		t.Fatal("bad PMT header")
+	}
+	d, _ := pmt.ProgramDescriptors().Pop()
+	pcad, ok := psi.ParseCADescriptor(d)
+	if !ok || pcad.Sys != cad.Sys || pcad.Pid != cad.Pid ||
+		!bytes.Equal(pcad.PrivateData, cad.PrivateData) {
+		t.Fatalf("bad CA descriptor: %+v", pcad)
+	}
+	es, _ := pmt.ESInfo().Pop()
+	if es == nil || es.Pid() != 0x101 {
+		t.Fatal("bad ES info")
+	}
+	cat := psi.MakeCAT(0, psi.DescriptorList(cad.MakeDescriptor()))
+	if cads := cat.CADescriptors(); len(cads) != 1 || cads[0].Pid != 0x200 {
+		t.Fatalf("bad CAT: %v", cads)
+	}
+}
+
+func TestIDSAKnownAnswer(t *testing.T) {
+	// FIPS-197 Appendix C.1 AES-128 vector. IDSA uses zero IV so the first
+	// cipher block is the plain AES encryption of the first payload block.
+	cw := []byte{
+		0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+		0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+	}
+	plain := []byte{
+		0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77,
+		0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff,
+	}
+	enc := []byte{
+		0x69, 0xc4, 0xe0, 0xd8, 0x6a, 0x7b, 0x04, 0x30,
+		0xd8, 0xcd, 0xb7, 0x80, 0x70, 0xb4, 0xc5, 0x5a,
+	}
+	s := NewScrambler(nil, IDSA)
+	if err := s.SetKey(0x100, Even, cw); err != nil {
+		t.Fatal(err)
+	}
+	p := testPkt(0x100, ts.PktLen-4-20) // one block + 4 B residue
+	p.SetPayloadUnitStart(true)
+	pl := p.Payload()
+	copy(pl, plain)
+	res := []byte{1, 2, 3, 4}
+	copy(pl[16:], res)
+	if !s.Scramble(p) {
+		t.Fatal("not scrambled")
+	}
+	if !bytes.Equal(pl[:16], enc) {
+		t.Fatalf("bad cipher block: %x", pl[:16])
+	}
+	// ANSI/SCTE 52 residue: XOR with encrypted last cipher block.
+	b, _ := aes.NewCipher(cw)
+	var ks [16]byte
+	b.Encrypt(ks[:], enc)
+	for i := range res {
+		res[i] ^= ks[i]
+	}
+	if !bytes.Equal(pl[16:], res) {
+		t.Fatalf("bad residue: %x, expected %x", pl[16:], res)
+	}
+}